@@ -0,0 +1,98 @@
+package alert
+
+import (
+	"time"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/common/utils"
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+)
+
+// GetAlertLog get the alert log, return ErrRecordNotFound if it's not exist.
+func GetAlertLog(id int64) (*db.AlertLog, error) {
+	alertLog, err := db.GetAlertLogById(id)
+	if err != nil {
+		return nil, err
+	}
+	if alertLog == nil || alertLog.ID == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return alertLog, nil
+}
+
+// getAlertConfig find the alert config of this alert log.
+func getAlertConfig(alertLog *db.AlertLog) *db.AlertConfig {
+	alert, err := db.GetAlertByName(alertLog.Name)
+	if err != nil {
+		return nil
+	}
+
+	return alert
+}
+
+/*
+* Raise
+* store the alert log and notify it by the notification of the alert
+* config, the alert config is found by name if it's nil. The identical
+* alerts in the dedup window are counted on the existing alert log, and
+* the notifications are withheld if the alert is silenced.
+ */
+func Raise(alert *db.AlertConfig, alertLog *db.AlertLog) error {
+	unlock := lockIdentity(alertLog)
+	defer unlock()
+
+	now := utils.GetNowTimeStamp()
+	if prev := findDuplicate(alertLog, now); prev != nil {
+		alertLog.ID = prev.ID
+		klog.V(4).Infof("alert %s on %s is repeated", alertLog.Name, alertLog.DeviceId)
+		return db.IncreaseAlertLogRepeat(prev.ID, alertLog.Record, now)
+	}
+
+	alertLog.Status = v1.AlertLogUnsolved
+	alertLog.RepeatCount = 1
+	alertLog.LastTimeStamp = now
+	alertLog.Silenced = IsSilenced(alertLog, time.Now())
+	if err := db.AddAlertLog(alertLog); err != nil {
+		return err
+	}
+	db.AddAlertHistory(newAlertHistory(alertLog, v1.AlertLogStatusNone, alertLog.Status, SystemOperator, "raised"))
+
+	if alertLog.Silenced {
+		klog.Infof("alert %s on %s is silenced", alertLog.Name, alertLog.DeviceId)
+		return nil
+	}
+
+	if alert == nil {
+		alert = getAlertConfig(alertLog)
+	}
+	Notify(alert, v1.AlertNotifyCreated, alertLog, nil)
+
+	return nil
+}
+
+// UpdateLevel change the level of the alert log and notify it.
+func UpdateLevel(id int64, level int64) error {
+	alertLog, err := GetAlertLog(id)
+	if err != nil {
+		return err
+	}
+	if alertLog.Level == level {
+		return nil
+	}
+
+	if err := db.SaveAlertLog(id, "", "", nil, &level, ""); err != nil {
+		return err
+	}
+
+	previous := alertLog.Level
+	alertLog.Level = level
+	if IsSilenced(alertLog, time.Now()) {
+		return nil
+	}
+	Notify(getAlertConfig(alertLog), v1.AlertNotifyLevelChanged, alertLog, &previous)
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package alert
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/edgehook/ithings/common/types/v1"
+)
+
+// buildMail build the mail message with the headers.
+func buildMail(spec *v1.NotificationEmail, subject, body string) []byte {
+	var sb strings.Builder
+
+	sb.WriteString("From: " + spec.From + "\r\n")
+	sb.WriteString("To: " + strings.Join(spec.To, ", ") + "\r\n")
+	sb.WriteString("Subject: " + subject + "\r\n")
+	sb.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	sb.WriteString("\r\n")
+	sb.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	sb.WriteString("\r\n")
+
+	return []byte(sb.String())
+}
+
+func sendEmail(spec *v1.NotificationEmail, data *v1.AlertNotifyData) error {
+	subject, err := render(spec.SubjectTemplate, defaultSubjectTemplate, data)
+	if err != nil {
+		return err
+	}
+	body, err := render(spec.BodyTemplate, defaultTextTemplate, data)
+	if err != nil {
+		return err
+	}
+	//the subject must be a single line.
+	subject = strings.Join(strings.Fields(subject), " ")
+
+	host, _, err := net.SplitHostPort(spec.Server)
+	if err != nil {
+		return err
+	}
+	tlsConfig := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: spec.InsecureSkipVerify,
+	}
+
+	var conn net.Conn
+	dialer := &net.Dialer{Timeout: defaultNotifyTimeout}
+	if spec.TLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", spec.Server, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", spec.Server)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(defaultNotifyTimeout))
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if !spec.TLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if spec.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("smtp server %s doesn't support AUTH", spec.Server)
+		}
+		if err := client.Auth(smtp.PlainAuth("", spec.Username, spec.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(spec.From); err != nil {
+		return err
+	}
+	for _, to := range spec.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildMail(spec, subject, body)); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
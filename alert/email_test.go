@@ -0,0 +1,186 @@
+package alert
+
+import (
+	"bufio"
+	"encoding/base64"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/edgehook/ithings/common/types/v1"
+)
+
+// smtpSession is what the stub server received in one session.
+type smtpSession struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+/*
+* newSMTPStub
+* serve one plain SMTP session, it advertises AUTH PLAIN if auth is true.
+ */
+func newSMTPStub(t *testing.T, auth bool) (string, chan *smtpSession) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	sessions := make(chan *smtpSession, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		session := &smtpSession{}
+		defer func() { sessions <- session }()
+
+		tp.PrintfLine("220 stub ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch cmd {
+			case "EHLO", "HELO":
+				if auth {
+					tp.PrintfLine("250-stub")
+					tp.PrintfLine("250 AUTH PLAIN")
+				} else {
+					tp.PrintfLine("250 stub")
+				}
+			case "AUTH":
+				fields := strings.Fields(line)
+				decoded, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+				session.auth = string(decoded)
+				tp.PrintfLine("235 authenticated")
+			case "MAIL":
+				session.from = line
+				tp.PrintfLine("250 ok")
+			case "RCPT":
+				session.to = append(session.to, line)
+				tp.PrintfLine("250 ok")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				lines, err := tp.ReadDotLines()
+				if err != nil {
+					return
+				}
+				session.data = strings.Join(lines, "\n")
+				tp.PrintfLine("250 queued")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				return
+			default:
+				tp.PrintfLine("502 unsupported")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), sessions
+}
+
+// mailHeader get the header of the mail data.
+func mailHeader(t *testing.T, data string) textproto.MIMEHeader {
+	header, err := textproto.NewReader(bufio.NewReader(strings.NewReader(data + "\n"))).ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("parse mail header %q: %v", data, err)
+	}
+	return header
+}
+
+func TestSendEmail(t *testing.T) {
+	addr, sessions := newSMTPStub(t, true)
+
+	spec := &v1.NotificationEmail{
+		Server:          addr,
+		Username:        "bot",
+		Password:        "pa55",
+		From:            "ithings@example.com",
+		To:              []string{"ops@example.com", "oncall@example.com"},
+		SubjectTemplate: "{{.LevelName}}\n{{.Name}}\r\nBcc: evil@example.com",
+	}
+	if err := sendEmail(spec, testNotifyData()); err != nil {
+		t.Fatalf("sendEmail() = %v", err)
+	}
+
+	session := <-sessions
+	if session.auth != "\x00bot\x00pa55" {
+		t.Errorf("auth = %q", session.auth)
+	}
+	if session.from != "MAIL FROM:<ithings@example.com>" {
+		t.Errorf("from = %s", session.from)
+	}
+	if len(session.to) != 2 || session.to[1] != "RCPT TO:<oncall@example.com>" {
+		t.Errorf("to = %v", session.to)
+	}
+
+	header := mailHeader(t, session.data)
+	if got := header.Get("Subject"); got != "error overheat Bcc: evil@example.com" {
+		t.Errorf("Subject = %q", got)
+	}
+	if got := header.Get("Bcc"); got != "" {
+		t.Errorf("the subject injects the header Bcc: %s", got)
+	}
+	if got := header.Get("To"); got != "ops@example.com, oncall@example.com" {
+		t.Errorf("To = %s", got)
+	}
+	if !strings.Contains(session.data, "device: boiler(device-1)") {
+		t.Errorf("data = %s", session.data)
+	}
+}
+
+func TestSendEmailWithoutAuth(t *testing.T) {
+	addr, sessions := newSMTPStub(t, false)
+
+	spec := &v1.NotificationEmail{
+		Server: addr,
+		From:   "ithings@example.com",
+		To:     []string{"ops@example.com"},
+	}
+	if err := sendEmail(spec, testNotifyData()); err != nil {
+		t.Fatalf("sendEmail() = %v", err)
+	}
+	if session := <-sessions; session.auth != "" || session.data == "" {
+		t.Errorf("session = %+v", session)
+	}
+}
+
+func TestSendEmailAuthUnsupported(t *testing.T) {
+	addr, sessions := newSMTPStub(t, false)
+
+	spec := &v1.NotificationEmail{
+		Server:   addr,
+		Username: "bot",
+		Password: "pa55",
+		From:     "ithings@example.com",
+		To:       []string{"ops@example.com"},
+	}
+	err := sendEmail(spec, testNotifyData())
+	if err == nil || !strings.Contains(err.Error(), "doesn't support AUTH") {
+		t.Fatalf("sendEmail() = %v", err)
+	}
+	if session := <-sessions; session.data != "" {
+		t.Errorf("the mail is sent without AUTH: %s", session.data)
+	}
+}
+
+func TestSendEmailInvalidServer(t *testing.T) {
+	spec := &v1.NotificationEmail{
+		Server: "no-port",
+		From:   "ithings@example.com",
+		To:     []string{"ops@example.com"},
+	}
+	if err := sendEmail(spec, testNotifyData()); err == nil {
+		t.Fatal("sendEmail() succeeds without port")
+	}
+}
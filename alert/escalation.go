@@ -0,0 +1,173 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/types/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	//evaluate the open alerts periodically.
+	defaultEscalationInterval = 30 * time.Second
+)
+
+type escalationStep struct {
+	*v1.AlertEscalationStep
+	after time.Duration
+}
+
+var escalationOnce sync.Once
+
+// parseEscalation parse the escalation policy of the alert config.
+func parseEscalation(escalation string) ([]*escalationStep, error) {
+	steps := make([]*escalationStep, 0)
+	if escalation == "" {
+		return steps, nil
+	}
+
+	var specs []*v1.AlertEscalationStep
+	if err := json.Unmarshal([]byte(escalation), &specs); err != nil {
+		return nil, err
+	}
+
+	var last time.Duration
+	for i, spec := range specs {
+		if spec == nil {
+			return nil, fmt.Errorf("step %d is empty", i+1)
+		}
+		after, err := time.ParseDuration(spec.After)
+		if err != nil || after <= 0 {
+			return nil, fmt.Errorf("step %d has invalid after %s", i+1, spec.After)
+		}
+		if after < last {
+			return nil, fmt.Errorf("step %d must be after step %d", i+1, i)
+		}
+		last = after
+
+		steps = append(steps, &escalationStep{
+			AlertEscalationStep: spec,
+			after:               after,
+		})
+	}
+
+	return steps, nil
+}
+
+// ValidateEscalation check the escalation policy before saving it.
+func ValidateEscalation(escalation string) error {
+	steps, err := parseEscalation(escalation)
+	if err != nil {
+		return err
+	}
+
+	for i, step := range steps {
+		if step.Notification == nil {
+			continue
+		}
+		b, _ := json.Marshal(step.Notification)
+		if err := ValidateNotification(string(b)); err != nil {
+			return fmt.Errorf("step %d: %v", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+/*
+* StartEscalation
+* start the scheduler to escalate the open alerts by the policies.
+ */
+func StartEscalation() {
+	escalationOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(defaultEscalationInterval)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				evaluateEscalations(time.Now())
+			}
+		}()
+	})
+}
+
+func evaluateEscalations(now time.Time) {
+	alerts, err := db.GetAlertWithEscalation()
+	if err != nil {
+		return
+	}
+
+	for _, alert := range alerts {
+		steps, err := parseEscalation(alert.Escalation)
+		if err != nil || len(steps) == 0 {
+			klog.V(4).Infof("ignore the escalation of alert %s: %v", alert.Name, err)
+			continue
+		}
+
+		alertLogs, err := db.GetAlertLogByNameAndStatus(alert.Name,
+			[]int32{v1.AlertLogUnsolved, v1.AlertLogSolving})
+		if err != nil {
+			continue
+		}
+		for _, alertLog := range alertLogs {
+			escalate(alert, steps, alertLog, now)
+		}
+	}
+}
+
+// escalate apply the due steps of the alert log in order.
+func escalate(alert *db.AlertConfig, steps []*escalationStep, alertLog *db.AlertLog, now time.Time) {
+	age := time.Duration(now.UnixNano()/1e6-alertLog.CreateTimeStamp) * time.Millisecond
+
+	for alertLog.EscalationStep < len(steps) {
+		step := steps[alertLog.EscalationStep]
+		if age < step.after {
+			return
+		}
+
+		status := []int32{v1.AlertLogUnsolved}
+		if step.IncludeSolving {
+			status = append(status, v1.AlertLogSolving)
+		}
+		if alertLog.Status == v1.AlertLogSolving && !step.IncludeSolving {
+			return
+		}
+
+		previous := alertLog.Level
+		level := alertLog.Level
+		//the escalation never lowers the level.
+		if step.Level != nil && *step.Level > level {
+			level = *step.Level
+		}
+
+		prevStep := alertLog.EscalationStep
+		comment := fmt.Sprintf("escalated by step %d after %s, level %s -> %s", prevStep+1,
+			step.After, v1.AlertLevelName(previous), v1.AlertLevelName(level))
+		history := newAlertHistory(alertLog, alertLog.Status, alertLog.Status, SystemOperator, comment)
+		history.Level = level
+
+		ok, err := db.EscalateAlertLog(alertLog.ID, prevStep, prevStep+1, level, status, history)
+		if err != nil || !ok {
+			return
+		}
+		alertLog.EscalationStep = prevStep + 1
+		alertLog.Level = level
+		klog.Infof("alert %d %s", alertLog.ID, comment)
+
+		if IsSilenced(alertLog, now) {
+			continue
+		}
+		spec := step.Notification
+		if spec == nil {
+			var err error
+			if spec, err = ParseNotification(alert.Notification); err != nil {
+				continue
+			}
+		}
+		notify(alert.Name, spec, newNotifyData(v1.AlertNotifyEscalated, alertLog, &previous))
+	}
+}
@@ -0,0 +1,168 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"text/template"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/grp"
+	"github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/common/utils"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultMaxGoRoutines = 32
+
+	defaultSubjectTemplate = `[ithings] {{.LevelName}} alert {{.Name}} on {{.DeviceName}}`
+	defaultTextTemplate    = `alert: {{.Name}}
+level: {{.LevelName}}
+edge: {{.EdgeName}}({{.EdgeId}})
+device: {{.DeviceName}}({{.DeviceId}})
+record: {{.Record}}`
+)
+
+var (
+	notifyPool     *grp.GoRoutinePool
+	notifyPoolOnce sync.Once
+
+	templateFuncs = template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}
+)
+
+func getNotifyPool() *grp.GoRoutinePool {
+	notifyPoolOnce.Do(func() {
+		notifyPool = grp.NewGoRoutinePool(defaultMaxGoRoutines)
+	})
+
+	return notifyPool
+}
+
+// render render the template with data, use the default if tmpl is empty.
+func render(tmpl, def string, data *v1.AlertNotifyData) (string, error) {
+	if tmpl == "" {
+		tmpl = def
+	}
+
+	t, err := template.New("notify").Funcs(templateFuncs).Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// ParseNotification parse the notification spec of the alert config.
+func ParseNotification(notification string) (*v1.AlertNotification, error) {
+	spec := &v1.AlertNotification{}
+	if notification == "" {
+		return spec, nil
+	}
+
+	if err := json.Unmarshal([]byte(notification), spec); err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+}
+
+func newNotifyData(event string, alertLog *db.AlertLog, previousLevel *int64) *v1.AlertNotifyData {
+	return &v1.AlertNotifyData{
+		Event:          event,
+		ID:             alertLog.ID,
+		Name:           alertLog.Name,
+		Description:    alertLog.Description,
+		Level:          alertLog.Level,
+		LevelName:      v1.AlertLevelName(alertLog.Level),
+		PreviousLevel:  previousLevel,
+		EdgeId:         alertLog.EdgeId,
+		EdgeName:       alertLog.EdgeName,
+		DeviceId:       alertLog.DeviceId,
+		DeviceName:     alertLog.DeviceName,
+		Record:         alertLog.Record,
+		Status:         alertLog.Status,
+		RepeatCount:    alertLog.RepeatCount,
+		EscalationStep: alertLog.EscalationStep,
+		Timestamp:      utils.GetNowTimeStamp(),
+	}
+}
+
+/*
+* Notify
+* send the notifications of the alert config to all channels asynchronously.
+ */
+func Notify(alert *db.AlertConfig, event string, alertLog *db.AlertLog, previousLevel *int64) {
+	if alert == nil || alert.Notification == "" {
+		return
+	}
+
+	spec, err := ParseNotification(alert.Notification)
+	if err != nil {
+		klog.Warningf("invalid notification of alert %s: %v", alert.Name, err)
+		return
+	}
+
+	notify(alert.Name, spec, newNotifyData(event, alertLog, previousLevel))
+}
+
+// notify send the notification to the channels of the spec asynchronously.
+func notify(name string, spec *v1.AlertNotification, data *v1.AlertNotifyData) {
+	send := func(channel string, fn func(*v1.AlertNotifyData) error) {
+		err := getNotifyPool().Run(func() {
+			if err := fn(data); err != nil {
+				klog.Warningf("notify alert %s by %s with err: %v", name, channel, err)
+			}
+		})
+		if err != nil {
+			klog.Errorf("notify alert %s by %s with err: %v", name, channel, err)
+		}
+	}
+
+	if spec.Webhook != nil {
+		send("webhook", func(data *v1.AlertNotifyData) error {
+			return sendWebhook(spec.Webhook, data)
+		})
+	}
+	if spec.Email != nil {
+		send("email", func(data *v1.AlertNotifyData) error {
+			return sendEmail(spec.Email, data)
+		})
+	}
+	if spec.Syslog != nil {
+		send("syslog", func(data *v1.AlertNotifyData) error {
+			return sendSyslog(spec.Syslog, data)
+		})
+	}
+}
+
+// ValidateNotification check the notification spec before saving it.
+func ValidateNotification(notification string) error {
+	spec, err := ParseNotification(notification)
+	if err != nil {
+		return err
+	}
+
+	if spec.Webhook != nil && spec.Webhook.Url == "" {
+		return fmt.Errorf("webhook url is required")
+	}
+	if spec.Email != nil && (spec.Email.Server == "" || spec.Email.From == "" || len(spec.Email.To) == 0) {
+		return fmt.Errorf("email server, from and to are required")
+	}
+	if spec.Syslog != nil && spec.Syslog.Address == "" {
+		return fmt.Errorf("syslog address is required")
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/common/utils"
+	"k8s.io/klog/v2"
+)
+
+const (
+	//the operator of the transitions made by ithings itself.
+	SystemOperator = "system"
+)
+
+var (
+	ErrIllegalTransition = errors.New("illegal alert status transition")
+	ErrStatusChanged     = errors.New("alert status is changed by others")
+)
+
+/*
+* the alert log lifecycle:
+* unsolved -> solving(acknowledged) -> resolved
+*    |            |
+*    +------------+-----> invalid
+* resolved and invalid are final.
+ */
+var transitions = map[int32][]int32{
+	v1.AlertLogUnsolved: {v1.AlertLogSolving, v1.AlertLogResolved, v1.AlertLogInvalid},
+	v1.AlertLogSolving:  {v1.AlertLogResolved, v1.AlertLogInvalid},
+}
+
+// CanTransit check whether the alert log can change from -> to.
+func CanTransit(from, to int32) bool {
+	for _, status := range transitions[from] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TriggerKey the key of the service/event which triggers the alert.
+func TriggerKey(serviceName, eventName string) string {
+	return serviceName + "/" + eventName
+}
+
+func newAlertHistory(alertLog *db.AlertLog, from, to int32, operator, comment string) *db.AlertHistory {
+	return &db.AlertHistory{
+		Name:        alertLog.Name,
+		Description: alertLog.Description,
+		Level:       alertLog.Level,
+		EdgeName:    alertLog.EdgeName,
+		EdgeId:      alertLog.EdgeId,
+		DeviceName:  alertLog.DeviceName,
+		DeviceId:    alertLog.DeviceId,
+		AlertLogId:  alertLog.ID,
+		FromStatus:  from,
+		ToStatus:    to,
+		Operator:    operator,
+		Comment:     comment,
+	}
+}
+
+/*
+* Transit
+* change the status of the alert log by the lifecycle, record who
+* acknowledged or resolved it, and copy the transition into history.
+ */
+func Transit(id int64, to int32, operator, comment string) (*db.AlertLog, error) {
+	alertLog, err := GetAlertLog(id)
+	if err != nil {
+		return nil, err
+	}
+
+	from := alertLog.Status
+	if !CanTransit(from, to) {
+		return nil, fmt.Errorf("%w: %d -> %d", ErrIllegalTransition, from, to)
+	}
+	if strings.TrimSpace(operator) == "" {
+		operator = SystemOperator
+	}
+
+	now := utils.GetNowTimeStamp()
+	vals := make(map[string]interface{})
+	switch to {
+	case v1.AlertLogSolving:
+		vals["acknowledged_by"] = operator
+		vals["acknowledged_time_stamp"] = now
+		alertLog.AcknowledgedBy = operator
+		alertLog.AcknowledgedTimeStamp = now
+	case v1.AlertLogResolved, v1.AlertLogInvalid:
+		vals["resolved_by"] = operator
+		vals["resolved_time_stamp"] = now
+		alertLog.ResolvedBy = operator
+		alertLog.ResolvedTimeStamp = now
+	}
+
+	ok, err := db.TransitAlertLogStatus(id, from, to, vals, newAlertHistory(alertLog, from, to, operator, comment))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrStatusChanged
+	}
+	alertLog.Status = to
+
+	return alertLog, nil
+}
+
+// Acknowledge the operator is handling the alert.
+func Acknowledge(id int64, operator, comment string) (*db.AlertLog, error) {
+	return Transit(id, v1.AlertLogSolving, operator, comment)
+}
+
+// Resolve the alert is resolved.
+func Resolve(id int64, operator, comment string) (*db.AlertLog, error) {
+	return Transit(id, v1.AlertLogResolved, operator, comment)
+}
+
+// Invalidate the alert is a false alarm.
+func Invalidate(id int64, operator, comment string) (*db.AlertLog, error) {
+	return Transit(id, v1.AlertLogInvalid, operator, comment)
+}
+
+/*
+* AutoResolve
+* resolve the unresolved monitor alerts of this device once the
+* condition of the trigger clears.
+ */
+func AutoResolve(deviceID, trigger string) {
+	alertLogs, err := db.GetUnresolvedAlertLogByTrigger(v1.AlertLogTypeMonitor, deviceID, trigger,
+		[]int32{v1.AlertLogUnsolved, v1.AlertLogSolving})
+	if err != nil {
+		return
+	}
+
+	for _, alertLog := range alertLogs {
+		_, err := Resolve(alertLog.ID, SystemOperator, fmt.Sprintf("%s is recovered", trigger))
+		if err != nil {
+			klog.Warningf("auto resolve alert %d with err: %v", alertLog.ID, err)
+		}
+	}
+}
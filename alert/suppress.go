@@ -0,0 +1,210 @@
+package alert
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/edgehook/ithings/common/config"
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/types/v1"
+)
+
+var (
+	alertingConfig     *config.AlertingConfig
+	alertingConfigOnce sync.Once
+
+	dailyTimeLayouts = []string{"15:04:05", "15:04"}
+
+	identityLocks = &identityLockMap{locks: make(map[string]*identityLock)}
+)
+
+type identityLock struct {
+	sync.Mutex
+	//the goroutines holding or waiting for this lock.
+	refs int
+}
+
+// identityLockMap hold the locks of the alert identities in use.
+type identityLockMap struct {
+	sync.Mutex
+	locks map[string]*identityLock
+}
+
+/*
+* lockIdentity
+* serialize the raising of the identical alerts, so that the dedup
+* check and the insert are not interleaved. It returns the unlock func.
+ */
+func lockIdentity(alertLog *db.AlertLog) func() {
+	key := alertLog.Name + "\x00" + alertLog.EdgeId + "\x00" + alertLog.DeviceId
+
+	identityLocks.Lock()
+	l, exist := identityLocks.locks[key]
+	if !exist {
+		l = &identityLock{}
+		identityLocks.locks[key] = l
+	}
+	l.refs++
+	identityLocks.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+
+		identityLocks.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(identityLocks.locks, key)
+		}
+		identityLocks.Unlock()
+	}
+}
+
+func getAlertingConfig() *config.AlertingConfig {
+	alertingConfigOnce.Do(func() {
+		alertingConfig = config.GetAlertingConfig()
+	})
+
+	return alertingConfig
+}
+
+/*
+* findDuplicate
+* find the unresolved identical alert log whose last occurrence is in
+* the dedup window, the level is not a part of the identity since it's
+* changed by the escalation.
+ */
+func findDuplicate(alertLog *db.AlertLog, now int64) *db.AlertLog {
+	window := getAlertingConfig().DedupWindow
+	if window <= 0 {
+		return nil
+	}
+
+	prev, err := db.GetLatestAlertLogByIdentity(alertLog.Name, alertLog.EdgeId, alertLog.DeviceId,
+		[]int32{v1.AlertLogUnsolved, v1.AlertLogSolving})
+	if err != nil {
+		return nil
+	}
+
+	last := prev.LastTimeStamp
+	if last == 0 {
+		last = prev.CreateTimeStamp
+	}
+	if now-last > window.Milliseconds() {
+		return nil
+	}
+
+	return prev
+}
+
+func parseDailyTime(s string) (time.Duration, bool) {
+	for _, layout := range dailyTimeLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+				time.Duration(t.Second())*time.Second, true
+		}
+	}
+
+	return 0, false
+}
+
+// isInWindow check whether now is in the maintenance window of this silence.
+func isInWindow(silence *db.AlertSilence, now time.Time) bool {
+	ts := now.UnixNano() / 1e6
+	if silence.StartTimeStamp > 0 && ts < silence.StartTimeStamp {
+		return false
+	}
+	if silence.EndTimeStamp > 0 && ts > silence.EndTimeStamp {
+		return false
+	}
+
+	if silence.DailyStartTime == "" || silence.DailyEndTime == "" {
+		return true
+	}
+	start, ok1 := parseDailyTime(silence.DailyStartTime)
+	end, ok2 := parseDailyTime(silence.DailyEndTime)
+	if !ok1 || !ok2 {
+		return false
+	}
+
+	y, m, d := now.Date()
+	offset := now.Sub(time.Date(y, m, d, 0, 0, 0, 0, now.Location()))
+	if start <= end {
+		return offset >= start && offset <= end
+	}
+	//the window crosses midnight.
+	return offset >= start || offset <= end
+}
+
+// matchSilence check whether the alert log is in the scope of this silence.
+func matchSilence(silence *db.AlertSilence, alertLog *db.AlertLog, modelID func() *int64) bool {
+	if silence.EdgeId != "" && silence.EdgeId != alertLog.EdgeId {
+		return false
+	}
+	if silence.DeviceId != "" && silence.DeviceId != alertLog.DeviceId {
+		return false
+	}
+	if silence.Level != nil && *silence.Level != alertLog.Level {
+		return false
+	}
+	if silence.DeviceModelId != nil {
+		id := modelID()
+		if id == nil || *id != *silence.DeviceModelId {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsSilenced check whether the notifications of the alert log are withheld now.
+func IsSilenced(alertLog *db.AlertLog, now time.Time) bool {
+	silences, err := db.GetEnabledAlertSilence()
+	if err != nil {
+		return false
+	}
+
+	var (
+		modelID     *int64
+		modelLoaded bool
+	)
+	getModelID := func() *int64 {
+		if !modelLoaded {
+			modelLoaded = true
+			if di, err := db.GetDeviceInstanceByDeviceId(alertLog.DeviceId); err == nil {
+				modelID = &di.DeviceModelId
+			}
+		}
+		return modelID
+	}
+
+	for _, silence := range silences {
+		if isInWindow(silence, now) && matchSilence(silence, alertLog, getModelID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateSilence check the windows of the silence.
+func ValidateSilence(silence *db.AlertSilence) error {
+	if silence.StartTimeStamp > 0 && silence.EndTimeStamp > 0 && silence.StartTimeStamp > silence.EndTimeStamp {
+		return fmt.Errorf("start time is after the end time")
+	}
+	if (silence.DailyStartTime == "") != (silence.DailyEndTime == "") {
+		return fmt.Errorf("daily start and end time are both required")
+	}
+	if silence.DailyStartTime != "" {
+		if _, ok := parseDailyTime(silence.DailyStartTime); !ok {
+			return fmt.Errorf("invalid daily start time %s", silence.DailyStartTime)
+		}
+		if _, ok := parseDailyTime(silence.DailyEndTime); !ok {
+			return fmt.Errorf("invalid daily end time %s", silence.DailyEndTime)
+		}
+	}
+
+	return nil
+}
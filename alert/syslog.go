@@ -0,0 +1,82 @@
+package alert
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/edgehook/ithings/common/types/v1"
+)
+
+const (
+	//local0
+	defaultSyslogFacility = 16
+	defaultSyslogTag      = "ithings"
+
+	defaultSyslogTemplate = `alert={{.Name}} level={{.LevelName}} edge={{.EdgeId}} device={{.DeviceId}} record={{.Record}}`
+)
+
+// syslogSeverity map the alert level into the syslog severity.
+func syslogSeverity(level int64) int {
+	switch level {
+	case v1.AlertErrorLevel:
+		return 3
+	case v1.AlertWarningLevel:
+		return 4
+	default:
+		return 6
+	}
+}
+
+/*
+* sendSyslog
+* send the RFC 5424 message to the syslog server, we don't use log/syslog
+* since it's not supported on windows.
+ */
+func sendSyslog(spec *v1.NotificationSyslog, data *v1.AlertNotifyData) error {
+	msg, err := render(spec.Template, defaultSyslogTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	network := strings.ToLower(spec.Network)
+	if network == "" {
+		network = "udp"
+	}
+	if network != "udp" && network != "tcp" {
+		return fmt.Errorf("unsupported syslog network %s", spec.Network)
+	}
+
+	facility := defaultSyslogFacility
+	if spec.Facility != nil {
+		facility = *spec.Facility
+	}
+	tag := spec.Tag
+	if tag == "" {
+		tag = defaultSyslogTag
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	line := fmt.Sprintf("<%d>1 %s %s %s %d - - %s", facility*8+syslogSeverity(data.Level),
+		time.Now().Format(time.RFC3339), hostname, tag, os.Getpid(), msg)
+
+	conn, err := net.DialTimeout(network, spec.Address, defaultNotifyTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(defaultNotifyTimeout))
+
+	if network == "tcp" {
+		//octet counting framing, RFC 6587.
+		line = fmt.Sprintf("%d %s", len(line), line)
+	}
+	_, err = conn.Write([]byte(line))
+
+	return err
+}
@@ -0,0 +1,115 @@
+package alert
+
+import (
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edgehook/ithings/common/types/v1"
+)
+
+// checkSyslogLine check the RFC 5424 header and the message of the line.
+func checkSyslogLine(t *testing.T, line, pri, tag, msg string) {
+	fields := strings.SplitN(line, " ", 8)
+	if len(fields) != 8 {
+		t.Fatalf("invalid syslog line %q", line)
+	}
+
+	if fields[0] != pri+"1" {
+		t.Errorf("priority and version = %s, want %s1", fields[0], pri)
+	}
+	if _, err := time.Parse(time.RFC3339, fields[1]); err != nil {
+		t.Errorf("timestamp %s: %v", fields[1], err)
+	}
+	if fields[3] != tag {
+		t.Errorf("tag = %s, want %s", fields[3], tag)
+	}
+	if fields[5] != "-" || fields[6] != "-" {
+		t.Errorf("msgid and structured data = %s %s", fields[5], fields[6])
+	}
+	if fields[7] != msg {
+		t.Errorf("message = %q, want %q", fields[7], msg)
+	}
+}
+
+func TestSendSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+
+	spec := &v1.NotificationSyslog{Address: conn.LocalAddr().String()}
+	if err := sendSyslog(spec, testNotifyData()); err != nil {
+		t.Fatalf("sendSyslog() = %v", err)
+	}
+
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	//local0.err
+	checkSyslogLine(t, string(buf[:n]), "<131>", defaultSyslogTag,
+		"alert=overheat level=error edge=edge-1 device=device-1 record=temperature 120 > 100")
+}
+
+func TestSendSyslogTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	frames := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		data, _ := ioutil.ReadAll(conn)
+		frames <- string(data)
+	}()
+
+	facility := 4
+	data := testNotifyData()
+	data.Level = v1.AlertWarningLevel
+	spec := &v1.NotificationSyslog{
+		Network:  "TCP",
+		Address:  ln.Addr().String(),
+		Facility: &facility,
+		Tag:      "plant",
+		Template: "{{.Name}} on {{.DeviceName}}",
+	}
+	if err := sendSyslog(spec, data); err != nil {
+		t.Fatalf("sendSyslog() = %v", err)
+	}
+
+	//octet counting framing.
+	frame := <-frames
+	parts := strings.SplitN(frame, " ", 2)
+	if len(parts) != 2 {
+		t.Fatalf("invalid frame %q", frame)
+	}
+	if n, err := strconv.Atoi(parts[0]); err != nil || n != len(parts[1]) {
+		t.Errorf("frame length %s, the line has %d bytes", parts[0], len(parts[1]))
+	}
+
+	//auth.warning
+	checkSyslogLine(t, parts[1], "<36>", "plant", "overheat on boiler")
+}
+
+func TestSendSyslogUnsupportedNetwork(t *testing.T) {
+	spec := &v1.NotificationSyslog{Network: "unix", Address: "/dev/log"}
+	if err := sendSyslog(spec, testNotifyData()); err == nil {
+		t.Fatal("sendSyslog() succeeds over unix")
+	}
+}
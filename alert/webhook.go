@@ -0,0 +1,59 @@
+package alert
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/edgehook/ithings/common/crypto/webhooksign"
+	"github.com/edgehook/ithings/common/types/v1"
+)
+
+const (
+	defaultNotifyTimeout = 10 * time.Second
+)
+
+var webhookClient = &http.Client{
+	Timeout: defaultNotifyTimeout,
+}
+
+func sendWebhook(spec *v1.NotificationWebhook, data *v1.AlertNotifyData) error {
+	body, err := render(spec.BodyTemplate, "{{json .}}", data)
+	if err != nil {
+		return err
+	}
+
+	method := strings.ToUpper(spec.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequest(method, spec.Url, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range spec.Headers {
+		req.Header.Set(key, value)
+	}
+	if spec.Secret != "" {
+		webhooksign.SetHeaders(req.Header, []byte(spec.Secret), data.Timestamp, []byte(body))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		detail, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook responds %d: %s", resp.StatusCode, string(bytes.TrimSpace(detail)))
+	}
+
+	return nil
+}
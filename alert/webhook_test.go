@@ -0,0 +1,141 @@
+package alert
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/edgehook/ithings/common/crypto/webhooksign"
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/types/v1"
+)
+
+// testNotifyData build the notify data of an error alert.
+func testNotifyData() *v1.AlertNotifyData {
+	data := newNotifyData("raised", &db.AlertLog{
+		ID:         7,
+		Name:       "overheat",
+		Level:      v1.AlertErrorLevel,
+		EdgeId:     "edge-1",
+		EdgeName:   "gateway",
+		DeviceId:   "device-1",
+		DeviceName: "boiler",
+		Record:     "temperature 120 > 100",
+	}, nil)
+	data.Timestamp = 1700000000000
+
+	return data
+}
+
+type webhookRequest struct {
+	method string
+	header http.Header
+	body   string
+}
+
+func newWebhookServer(t *testing.T, status int) (*httptest.Server, chan *webhookRequest) {
+	reqs := make(chan *webhookRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read webhook body: %v", err)
+		}
+		reqs <- &webhookRequest{method: r.Method, header: r.Header, body: string(body)}
+
+		w.WriteHeader(status)
+		w.Write([]byte(" rejected \n"))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, reqs
+}
+
+func TestSendWebhook(t *testing.T) {
+	srv, reqs := newWebhookServer(t, http.StatusOK)
+	data := testNotifyData()
+
+	spec := &v1.NotificationWebhook{
+		Url:     srv.URL,
+		Headers: map[string]string{"X-Token": "abc"},
+		Secret:  "s3cret",
+	}
+	if err := sendWebhook(spec, data); err != nil {
+		t.Fatalf("sendWebhook() = %v", err)
+	}
+
+	req := <-reqs
+	if req.method != http.MethodPost {
+		t.Errorf("method = %s, want POST", req.method)
+	}
+	if got := req.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %s", got)
+	}
+	if got := req.header.Get("X-Token"); got != "abc" {
+		t.Errorf("X-Token = %s", got)
+	}
+
+	got := &v1.AlertNotifyData{}
+	if err := json.Unmarshal([]byte(req.body), got); err != nil {
+		t.Fatalf("body %s is not json: %v", req.body, err)
+	}
+	if got.Name != data.Name || got.DeviceId != data.DeviceId || got.Level != data.Level {
+		t.Errorf("body = %s", req.body)
+	}
+
+	ts := req.header.Get(webhooksign.TimestampHeader)
+	if ts != "1700000000000" {
+		t.Errorf("%s = %s", webhooksign.TimestampHeader, ts)
+	}
+	mac := hmac.New(sha256.New, []byte(spec.Secret))
+	mac.Write([]byte(ts + "." + req.body))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if sig := req.header.Get(webhooksign.SignatureHeader); sig != want {
+		t.Errorf("%s = %s, want %s", webhooksign.SignatureHeader, sig, want)
+	}
+}
+
+func TestSendWebhookTemplate(t *testing.T) {
+	srv, reqs := newWebhookServer(t, http.StatusNoContent)
+
+	spec := &v1.NotificationWebhook{
+		Url:          srv.URL,
+		Method:       "put",
+		BodyTemplate: `{"text":"{{.LevelName}} {{.Name}} on {{.DeviceName}}"}`,
+	}
+	if err := sendWebhook(spec, testNotifyData()); err != nil {
+		t.Fatalf("sendWebhook() = %v", err)
+	}
+
+	req := <-reqs
+	if req.method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", req.method)
+	}
+	if want := `{"text":"error overheat on boiler"}`; req.body != want {
+		t.Errorf("body = %s, want %s", req.body, want)
+	}
+	if sig := req.header.Get(webhooksign.SignatureHeader); sig != "" {
+		t.Errorf("unexpected signature %s without secret", sig)
+	}
+}
+
+func TestSendWebhookError(t *testing.T) {
+	srv, _ := newWebhookServer(t, http.StatusBadGateway)
+
+	err := sendWebhook(&v1.NotificationWebhook{Url: srv.URL}, testNotifyData())
+	if err == nil {
+		t.Fatal("sendWebhook() succeeds on 502")
+	}
+	if !strings.Contains(err.Error(), "502") || !strings.Contains(err.Error(), ": rejected") {
+		t.Errorf("sendWebhook() = %v", err)
+	}
+
+	if err := sendWebhook(&v1.NotificationWebhook{Url: srv.URL, BodyTemplate: "{{.Nope"}, testNotifyData()); err == nil {
+		t.Error("sendWebhook() succeeds with invalid template")
+	}
+}
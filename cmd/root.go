@@ -6,8 +6,10 @@ import (
 	"strings"
 
 	_ "github.com/edgehook/ithings/common/dbm"
+	"github.com/edgehook/ithings/core"
+	"github.com/edgehook/ithings/transport"
 	"github.com/edgehook/ithings/webserver"
-	"github.com/jwzl/beehive/pkg/core"
+	beehive "github.com/jwzl/beehive/pkg/core"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"k8s.io/klog/v2"
@@ -22,7 +24,7 @@ var rootCmd = &cobra.Command{
 		klog.Infof("###########  Start the ithings...! ###########")
 		registerModules()
 		// start all modules
-		core.Run()
+		beehive.Run()
 	},
 }
 
@@ -50,5 +52,7 @@ func init() {
 
 // register all module into beehive.
 func registerModules() {
+	core.Register()
+	transport.Register()
 	webserver.Register()
 }
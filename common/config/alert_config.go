@@ -0,0 +1,21 @@
+package config
+
+import (
+	"time"
+)
+
+const (
+	defaultAlertDedupWindow = 5 * time.Minute
+)
+
+// alert config
+type AlertingConfig struct {
+	//the identical alerts in this window are counted on the same alert log.
+	DedupWindow time.Duration
+}
+
+func GetAlertingConfig() *AlertingConfig {
+	return &AlertingConfig{
+		DedupWindow: parseDuration("alert.dedup_window", defaultAlertDedupWindow),
+	}
+}
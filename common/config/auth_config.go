@@ -0,0 +1,99 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 7 * 24 * time.Hour
+	defaultTokenIssuer     = "ithings"
+	defaultJwtSecretFile   = "jwt.key"
+	defaultAdminUsername   = "admin"
+	defaultAdminPassFile   = "admin.password"
+	defaultLoginFailures   = 5
+	defaultLoginLockout    = 15 * time.Minute
+	defaultAPIKeyRateLimit = 10
+	defaultAPIKeyBurst     = 20
+
+	EnvJwtSecret     = "ITHINGS_JWT_SECRET"
+	EnvAdminPassword = "ITHINGS_ADMIN_PASSWORD"
+)
+
+// the authentication config of the web server.
+type AuthConfig struct {
+	//all the APIs are open if it's disabled.
+	Enable bool
+	//the HMAC key to sign the tokens, it's generated and kept
+	//in the SecretFile if it's not set.
+	JwtSecret  string
+	SecretFile string
+	Issuer     string
+	//lifetime of the access token and the refresh token.
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	//the first user created if there is no user, the generated
+	//password is written into the AdminPasswordFile.
+	AdminUsername     string
+	AdminPassword     string
+	AdminPasswordFile string
+	//the username or client ip is locked for LoginLockout after
+	//LoginMaxFailures failed logins.
+	LoginMaxFailures int
+	LoginLockout     time.Duration
+	//the default token bucket of the API keys, requests per second and burst.
+	APIKeyRateLimit float64
+	APIKeyBurst     int
+}
+
+func GetAuthConfig() *AuthConfig {
+	cfg := &AuthConfig{
+		Enable:            true,
+		JwtSecret:         ITHINGS_CONFIG.GetString("auth.jwt_secret"),
+		SecretFile:        ITHINGS_CONFIG.GetString("auth.jwt_secret_file"),
+		Issuer:            ITHINGS_CONFIG.GetString("auth.issuer"),
+		AccessTokenTTL:    parseDuration("auth.access_ttl", defaultAccessTokenTTL),
+		RefreshTokenTTL:   parseDuration("auth.refresh_ttl", defaultRefreshTokenTTL),
+		AdminUsername:     ITHINGS_CONFIG.GetString("auth.admin.username"),
+		AdminPassword:     ITHINGS_CONFIG.GetString("auth.admin.password"),
+		AdminPasswordFile: ITHINGS_CONFIG.GetString("auth.admin.password_file"),
+		LoginMaxFailures:  ITHINGS_CONFIG.GetInt("auth.login.max_failures"),
+		LoginLockout:      parseDuration("auth.login.lockout", defaultLoginLockout),
+		APIKeyRateLimit:   ITHINGS_CONFIG.GetFloat64("auth.apikey.rate_limit"),
+		APIKeyBurst:       ITHINGS_CONFIG.GetInt("auth.apikey.burst"),
+	}
+	if ITHINGS_CONFIG.Config.IsSet("auth.enable") {
+		cfg.Enable = ITHINGS_CONFIG.GetBool("auth.enable")
+	}
+
+	if secret := os.Getenv(EnvJwtSecret); secret != "" {
+		cfg.JwtSecret = secret
+	}
+	if cfg.SecretFile == "" {
+		cfg.SecretFile = ITHINGS_CONFIG.ConfigPath + "/" + defaultJwtSecretFile
+	}
+	if cfg.Issuer == "" {
+		cfg.Issuer = defaultTokenIssuer
+	}
+	if cfg.AdminUsername == "" {
+		cfg.AdminUsername = defaultAdminUsername
+	}
+	if password := os.Getenv(EnvAdminPassword); password != "" {
+		cfg.AdminPassword = password
+	}
+	if cfg.AdminPasswordFile == "" {
+		cfg.AdminPasswordFile = ITHINGS_CONFIG.ConfigPath + "/" + defaultAdminPassFile
+	}
+	if cfg.LoginMaxFailures <= 0 {
+		cfg.LoginMaxFailures = defaultLoginFailures
+	}
+	if cfg.APIKeyRateLimit <= 0 {
+		cfg.APIKeyRateLimit = defaultAPIKeyRateLimit
+	}
+	if cfg.APIKeyBurst <= 0 {
+		cfg.APIKeyBurst = defaultAPIKeyBurst
+	}
+
+	return cfg
+}
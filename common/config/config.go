@@ -43,6 +43,32 @@ func getConfigDirectory() string {
 	return GetCurrentDirectory()
 }
 
+/*
+* findConfigDirectory
+* search the conf/ with this file from the working directory up to the
+* root, it's for the binaries built into the temp directory, such as
+* go run and go test.
+ */
+func findConfigDirectory(fileName string) string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+
+	for {
+		confLocation := filepath.Join(dir, "conf")
+		if _, err := os.Stat(filepath.Join(confLocation, fileName)); err == nil {
+			return strings.Replace(confLocation, "\\", "/", -1)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
 // New yaml configuration for app.
 func NewYamlConfig(fileName string) *Config {
 	config := viper.New()
@@ -59,6 +85,13 @@ func NewYamlConfig(fileName string) *Config {
 	config.AddConfigPath(confLocation)
 
 	err = config.ReadInConfig()
+	if _, notFound := err.(viper.ConfigFileNotFoundError); notFound && os.Getenv(EnvironmentalConfigPath) == "" {
+		if location := findConfigDirectory(fileName); location != "" {
+			confLocation = location
+			config.AddConfigPath(confLocation)
+			err = config.ReadInConfig()
+		}
+	}
 	if err != nil {
 		klog.Errorf("err: %v", err)
 		panic(fmt.Errorf("Fatal error config file: %s \n", err))
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+)
+
+const (
+	defaultCryptoKeyFile = "crypto.key"
+
+	EnvCryptoKey = "ITHINGS_CRYPTO_KEY"
+)
+
+/*
+* CryptoConfig
+* the AES-256 keys to encrypt the secrets, every key is the base64 of 32
+* bytes with an id, the id is kept in the ciphertext to find the key.
+ */
+type CryptoConfig struct {
+	//the id of the key to encrypt the new data, it's searched in all the
+	//providers. The first key of the env, the only key of the config, or
+	//the last key of the keyfile is used if it's empty.
+	KeyID string
+	//the keys in the config file by id.
+	Keys map[string]string
+	//the keys in the env ITHINGS_CRYPTO_KEY, "id:key" separated by comma.
+	EnvKeys string
+	//the local keyfile with "id:key" per line, it's generated if there
+	//is no key in the env or the config.
+	KeyFile string
+}
+
+func GetCryptoConfig() *CryptoConfig {
+	cfg := &CryptoConfig{
+		KeyID:   ITHINGS_CONFIG.GetString("crypto.key_id"),
+		Keys:    ITHINGS_CONFIG.Config.GetStringMapString("crypto.keys"),
+		EnvKeys: os.Getenv(EnvCryptoKey),
+		KeyFile: ITHINGS_CONFIG.GetString("crypto.keyfile"),
+	}
+	if cfg.KeyFile == "" {
+		cfg.KeyFile = ITHINGS_CONFIG.ConfigPath + "/" + defaultCryptoKeyFile
+	}
+
+	return cfg
+}
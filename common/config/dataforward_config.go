@@ -0,0 +1,52 @@
+package config
+
+import (
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultForwardMaxAttempts    = 10
+	defaultForwardInitialBackoff = 2 * time.Second
+	defaultForwardMaxBackoff     = 5 * time.Minute
+)
+
+// data forward retry config
+type DataForwardConfig struct {
+	//move the message to dead letter after MaxAttempts
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+func parseDuration(key string, def time.Duration) time.Duration {
+	value := ITHINGS_CONFIG.GetString(key)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		klog.Warningf("invalid %s(%s), we use the default %v", key, value, def)
+		return def
+	}
+
+	return d
+}
+
+func GetDataForwardConfig() *DataForwardConfig {
+	cfg := &DataForwardConfig{}
+
+	cfg.MaxAttempts = ITHINGS_CONFIG.GetInt("dataforward.retry.max_attempts")
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultForwardMaxAttempts
+	}
+	cfg.InitialBackoff = parseDuration("dataforward.retry.initial_backoff", defaultForwardInitialBackoff)
+	cfg.MaxBackoff = parseDuration("dataforward.retry.max_backoff", defaultForwardMaxBackoff)
+	if cfg.MaxBackoff < cfg.InitialBackoff {
+		cfg.MaxBackoff = cfg.InitialBackoff
+	}
+
+	return cfg
+}
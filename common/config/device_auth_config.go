@@ -0,0 +1,28 @@
+package config
+
+import (
+	"time"
+)
+
+const (
+	defaultDeviceAuthMaxSkew       = 5 * time.Minute
+	defaultDeviceAuthRotationGrace = 10 * time.Minute
+)
+
+// the authentication of the register/report frames from the edges and devices.
+type DeviceAuthConfig struct {
+	//reject the unsigned frames of the edges and devices without secret.
+	Required bool
+	//the max difference between the frame timestamp and now.
+	MaxSkew time.Duration
+	//the previous secret is still accepted in this duration after rotation.
+	RotationGrace time.Duration
+}
+
+func GetDeviceAuthConfig() *DeviceAuthConfig {
+	return &DeviceAuthConfig{
+		Required:      ITHINGS_CONFIG.GetBool("transport.auth.required"),
+		MaxSkew:       parseDuration("transport.auth.max_skew", defaultDeviceAuthMaxSkew),
+		RotationGrace: parseDuration("transport.auth.rotation_grace", defaultDeviceAuthRotationGrace),
+	}
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"time"
+)
+
+const (
+	defaultHistoryRetention     = 7 * 24 * time.Hour
+	defaultHistoryPruneInterval = time.Hour
+)
+
+// the time series history in the main database when the influxdb is disabled.
+type HistoryConfig struct {
+	//the twins and events older than this are pruned.
+	Retention     time.Duration
+	PruneInterval time.Duration
+}
+
+func GetHistoryConfig() *HistoryConfig {
+	return &HistoryConfig{
+		Retention:     parseDuration("db.history.retention", defaultHistoryRetention),
+		PruneInterval: parseDuration("db.history.prune_interval", defaultHistoryPruneInterval),
+	}
+}
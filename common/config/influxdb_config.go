@@ -3,15 +3,59 @@ package config
 import (
 	"k8s.io/klog"
 	"os"
+	"strings"
+)
+
+const (
+	InfluxVersion1 = 1
+	InfluxVersion2 = 2
 )
 
 type InfluxDBConfig struct {
+	//1 or 2, default is 1.
+	Version  int
 	Address  string
 	DbName   string
 	Username string
 	Password string
 	Duration string
 	Enable   bool
+	//the settings of influxdb 2.x, the bucket is the db name by default.
+	Org    string
+	Bucket string
+	Token  string
+	//the downsampling tiers of the twins, such as 1m:30d.
+	Downsample []*DownsampleConfig
+}
+
+// IsVersion2 check whether it's influxdb 2.x.
+func (c *InfluxDBConfig) IsVersion2() bool {
+	return c.Version == InfluxVersion2
+}
+
+type DownsampleConfig struct {
+	//the time bucket, such as 1m
+	Interval string
+	//the retention duration, such as 30d
+	Duration string
+}
+
+// parseDownsample parse the tiers in the form of interval:duration.
+func parseDownsample(tiers []string) []*DownsampleConfig {
+	downsample := make([]*DownsampleConfig, 0)
+	for _, tier := range tiers {
+		parts := strings.SplitN(strings.TrimSpace(tier), ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			klog.Warningf("invalid downsample tier %s", tier)
+			continue
+		}
+		downsample = append(downsample, &DownsampleConfig{
+			Interval: parts[0],
+			Duration: parts[1],
+		})
+	}
+
+	return downsample
 }
 
 func GetInfluxDbConfig() *InfluxDBConfig {
@@ -22,6 +66,14 @@ func GetInfluxDbConfig() *InfluxDBConfig {
 	password := ITHINGS_CONFIG.GetString("db.influx.passwd")
 	duration := ITHINGS_CONFIG.GetString("db.influx.duration")
 	enable := ITHINGS_CONFIG.GetBool("db.influx.enable")
+	version := ITHINGS_CONFIG.GetInt("db.influx.version")
+	if version != InfluxVersion2 {
+		version = InfluxVersion1
+	}
+	org := ITHINGS_CONFIG.GetString("db.influx.org")
+	bucket := ITHINGS_CONFIG.GetString("db.influx.bucket")
+	token := ITHINGS_CONFIG.GetString("db.influx.token")
+	downsample := parseDownsample(ITHINGS_CONFIG.GetStringSlice("db.influx.downsample"))
 	klog.Infof("userName: %s,pwd: %s", username, password)
 	if os.Getenv("INFLUX_DB") != "" {
 		dbName = os.Getenv("INFLUX_DB")
@@ -32,15 +84,30 @@ func GetInfluxDbConfig() *InfluxDBConfig {
 	if os.Getenv("INFLUX_PASSWORD") != "" {
 		password = os.Getenv("INFLUX_PASSWORD")
 	}
-	if address == "" || dbName == "" || username == "" {
+	if os.Getenv("INFLUX_TOKEN") != "" {
+		token = os.Getenv("INFLUX_TOKEN")
+	}
+	if version == InfluxVersion2 {
+		if bucket == "" {
+			bucket = dbName
+		}
+		if address == "" || org == "" || bucket == "" || token == "" {
+			return nil
+		}
+	} else if address == "" || dbName == "" || username == "" {
 		return nil
 	}
 	return &InfluxDBConfig{
-		Address:  address,
-		DbName:   dbName,
-		Username: username,
-		Password: password,
-		Duration: duration,
-		Enable:   enable,
+		Version:    version,
+		Org:        org,
+		Bucket:     bucket,
+		Token:      token,
+		Address:    address,
+		DbName:     dbName,
+		Username:   username,
+		Password:   password,
+		Duration:   duration,
+		Enable:     enable,
+		Downsample: downsample,
 	}
 }
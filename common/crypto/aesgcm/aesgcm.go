@@ -0,0 +1,69 @@
+package aesgcm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	KeySize = 32
+)
+
+var (
+	ErrInvalidKeySize    = errors.New("the AES-256 key should be 32 bytes")
+	ErrInvalidCiphertext = errors.New("the ciphertext is too short")
+)
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKeySize
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+/*
+* AES-256-GCM encrypt, the random nonce is put before the ciphertext,
+* the additional data is authenticated but not encrypted.
+ */
+func Encrypt(data, key, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(data)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, data, additionalData), nil
+}
+
+/*
+* AES-256-GCM decrypt.
+ */
+func Decrypt(crypted, key, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(crypted) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, ErrInvalidCiphertext
+	}
+	nonce, crypted := crypted[:gcm.NonceSize()], crypted[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, crypted, additionalData)
+}
@@ -2,48 +2,140 @@ package crypto
 
 import (
 	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
 
+	"github.com/edgehook/ithings/common/config"
+	"github.com/edgehook/ithings/common/crypto/aesgcm"
 	"github.com/edgehook/ithings/common/crypto/descbc"
 	"github.com/edgehook/ithings/common/crypto/rsa"
 	"k8s.io/klog/v2"
 )
 
 const (
-	defaultKey = "ahc*5f/8"
+	//the DES key of the legacy data, it's only used to read the old data.
+	legacyDESKey = "ahc*5f/8"
+
+	//the ciphertext is "aesgcm:<key id>:<base64 of nonce and ciphertext>".
+	aesGCMPrefix = "aesgcm"
+)
+
+var (
+	ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+	keyProvider     KeyProvider
+	keyProviderLock sync.Mutex
 )
 
+// SetKeyProvider replace the default key provider built from the config.
+func SetKeyProvider(p KeyProvider) {
+	keyProviderLock.Lock()
+	keyProvider = p
+	keyProviderLock.Unlock()
+}
+
+func getKeyProvider() (KeyProvider, error) {
+	keyProviderLock.Lock()
+	defer keyProviderLock.Unlock()
+
+	if keyProvider == nil {
+		p, err := NewKeyProviderFromConfig(config.GetCryptoConfig())
+		if err != nil {
+			klog.Errorf("load the encryption keys with err: %v", err)
+			return nil, err
+		}
+		keyProvider = p
+	}
+
+	return keyProvider, nil
+}
+
+func additionalData(keyID string) []byte {
+	return []byte(aesGCMPrefix + ":" + keyID)
+}
+
+/*
+* parseCiphertext
+* return the key id and the encrypted data, legacy is true for the DES data.
+ */
+func parseCiphertext(s string) (string, string, bool, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) == 1 {
+		return "", s, true, nil
+	}
+	if len(parts) != 3 || parts[0] != aesGCMPrefix || parts[1] == "" {
+		return "", "", false, ErrInvalidCiphertext
+	}
+
+	return parts[1], parts[2], false, nil
+}
+
 /*
 * Encrypt:
-* 1. DES encrypt 2. Base64 encrypt.
+* 1. AES-256-GCM encrypt with the current key 2. Base64 encrypt
+* 3. prefix the key id.
  */
 func Encrypt(data []byte) (string, error) {
-	key := []byte(defaultKey)
+	p, err := getKeyProvider()
+	if err != nil {
+		return "", err
+	}
+	keyID, key, err := p.CurrentKey()
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return "", err
+	}
 
-	crypted, err := descbc.Encrypt(data, key)
+	crypted, err := aesgcm.Encrypt(data, key, additionalData(keyID))
 	if err != nil {
 		klog.Errorf("err: %v", err)
 		return "", err
 	}
 
-	sData := base64.StdEncoding.EncodeToString(crypted)
+	sData := aesGCMPrefix + ":" + keyID + ":" + base64.StdEncoding.EncodeToString(crypted)
 
 	return sData, nil
 }
 
 /*
 * Decrypt:
-* 1. base64 decrypt 2. des decrypt.
+* 1. find the key by the key id 2. base64 decrypt 3. AES-256-GCM decrypt.
+* The legacy data without key id is decrypted by DES.
  */
 func Decrypt(s string) ([]byte, error) {
-	key := []byte(defaultKey)
+	keyID, sData, legacy, err := parseCiphertext(s)
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
 
-	crypted, err := base64.StdEncoding.DecodeString(s)
+	crypted, err := base64.StdEncoding.DecodeString(sData)
 	if err != nil {
 		klog.Errorf("err: %v", err)
 		return nil, err
 	}
 
-	data, err := descbc.Decrypt(crypted, key)
+	if legacy {
+		data, err := descbc.Decrypt(crypted, []byte(legacyDESKey))
+		if err != nil {
+			klog.Errorf("err: %v", err)
+			return nil, err
+		}
+		return data, nil
+	}
+
+	p, err := getKeyProvider()
+	if err != nil {
+		return nil, err
+	}
+	key, err := p.Key(keyID)
+	if err != nil {
+		klog.Errorf("the key %s with err: %v", keyID, err)
+		return nil, err
+	}
+
+	data, err := aesgcm.Decrypt(crypted, key, additionalData(keyID))
 	if err != nil {
 		klog.Errorf("err: %v", err)
 		return nil, err
@@ -52,6 +144,38 @@ func Decrypt(s string) ([]byte, error) {
 	return data, nil
 }
 
+/*
+* NeedsReEncrypt
+* indicates whether the data is encrypted by DES or by a key which isn't
+* the current key.
+ */
+func NeedsReEncrypt(s string) bool {
+	keyID, _, legacy, err := parseCiphertext(s)
+	if err != nil {
+		return false
+	}
+	if legacy {
+		return true
+	}
+
+	p, err := getKeyProvider()
+	if err != nil {
+		return false
+	}
+	currentID, _, err := p.CurrentKey()
+	return err == nil && currentID != normalizeKeyID(keyID)
+}
+
+// ReEncrypt decrypt the data and encrypt it with the current key.
+func ReEncrypt(s string) (string, error) {
+	data, err := Decrypt(s)
+	if err != nil {
+		return "", err
+	}
+
+	return Encrypt(data)
+}
+
 /*
 * RSAEncrypt
 * 1. RSA encrypt 2. Base64 encrypt.
@@ -0,0 +1,147 @@
+package crypto
+
+import (
+	"bytes"
+	gocipher "crypto/cipher"
+	"crypto/des"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/edgehook/ithings/common/crypto/aesgcm"
+)
+
+// useKeys replace the key provider by the env keys in the test.
+func useKeys(t *testing.T, value string) {
+	p, err := NewEnvKeyProvider(value)
+	if err != nil {
+		t.Fatalf("NewEnvKeyProvider() = %v", err)
+	}
+	SetKeyProvider(p)
+	t.Cleanup(func() { SetKeyProvider(nil) })
+}
+
+// legacyEncrypt encrypt the data as the legacy DES data.
+func legacyEncrypt(t *testing.T, data []byte) string {
+	block, err := des.NewCipher([]byte(legacyDESKey))
+	if err != nil {
+		t.Fatalf("des: %v", err)
+	}
+
+	padding := des.BlockSize - len(data)%des.BlockSize
+	data = append(data, bytes.Repeat([]byte{byte(padding)}, padding)...)
+	crypted := make([]byte, len(data))
+	gocipher.NewCBCEncrypter(block, []byte(legacyDESKey)).CryptBlocks(crypted, data)
+
+	return base64.StdEncoding.EncodeToString(crypted)
+}
+
+func TestEncrypt(t *testing.T) {
+	useKeys(t, "K1:"+testKey('a')+",k2:"+testKey('b'))
+
+	s, err := Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt() = %v", err)
+	}
+	if !strings.HasPrefix(s, "aesgcm:k1:") {
+		t.Errorf("Encrypt() = %s, want the lowercase key id k1", s)
+	}
+	if again, _ := Encrypt([]byte("secret")); again == s {
+		t.Error("Encrypt() isn't randomized by the nonce")
+	}
+
+	data, err := Decrypt(s)
+	if err != nil || string(data) != "secret" {
+		t.Errorf("Decrypt() = %s, %v", data, err)
+	}
+	if NeedsReEncrypt(s) {
+		t.Errorf("NeedsReEncrypt(%s) = true with the current key", s)
+	}
+}
+
+func TestDecryptKeyIDBinding(t *testing.T) {
+	//the same key under two ids, only the additional data tells them apart.
+	useKeys(t, "k1:"+testKey('a')+",k2:"+testKey('a'))
+
+	s, err := Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt() = %v", err)
+	}
+	if _, err := Decrypt(strings.Replace(s, ":k1:", ":k2:", 1)); err == nil {
+		t.Error("Decrypt() succeeds with the key id replaced")
+	}
+
+	//the data encrypted before the key ids were lowercased.
+	crypted, err := aesgcm.Encrypt([]byte("old"), bytes.Repeat([]byte{'a'}, 32), additionalData("K1"))
+	if err != nil {
+		t.Fatalf("aesgcm.Encrypt() = %v", err)
+	}
+	old := "aesgcm:K1:" + base64.StdEncoding.EncodeToString(crypted)
+	if data, err := Decrypt(old); err != nil || string(data) != "old" {
+		t.Errorf("Decrypt(%s) = %s, %v", old, data, err)
+	}
+	if NeedsReEncrypt(old) {
+		t.Errorf("NeedsReEncrypt(%s) = true with the current key", old)
+	}
+
+	for _, s := range []string{
+		"aesgcm:k1",
+		"aesgcm::" + base64.StdEncoding.EncodeToString(crypted),
+		"des:k1:" + base64.StdEncoding.EncodeToString(crypted),
+		"aesgcm:k1:not base64",
+		"aesgcm:k3:" + base64.StdEncoding.EncodeToString(crypted),
+		"aesgcm:k1:" + base64.StdEncoding.EncodeToString(crypted[:8]),
+	} {
+		if _, err := Decrypt(s); err == nil {
+			t.Errorf("Decrypt(%s) succeeds", s)
+		}
+	}
+}
+
+func TestDecryptLegacy(t *testing.T) {
+	useKeys(t, "k1:"+testKey('a'))
+
+	for _, plain := range []string{"", "secret", "exactly8", "a longer legacy secret"} {
+		legacy := legacyEncrypt(t, []byte(plain))
+
+		data, err := Decrypt(legacy)
+		if err != nil || string(data) != plain {
+			t.Errorf("Decrypt(%s) = %q, %v, want %q", legacy, data, err, plain)
+		}
+		if !NeedsReEncrypt(legacy) {
+			t.Errorf("NeedsReEncrypt(%s) = false for the legacy data", legacy)
+		}
+
+		s, err := ReEncrypt(legacy)
+		if err != nil || !strings.HasPrefix(s, "aesgcm:k1:") {
+			t.Fatalf("ReEncrypt(%s) = %s, %v", legacy, s, err)
+		}
+		if data, err := Decrypt(s); err != nil || string(data) != plain {
+			t.Errorf("Decrypt(%s) = %q, %v, want %q", s, data, err, plain)
+		}
+	}
+
+	for _, s := range []string{"", "bm90IGJsb2Nr", legacyEncrypt(t, []byte("x"))[:8]} {
+		if _, err := Decrypt(s); err == nil {
+			t.Errorf("Decrypt(%q) succeeds", s)
+		}
+	}
+}
+
+func TestNeedsReEncrypt(t *testing.T) {
+	useKeys(t, "k1:"+testKey('a'))
+	s, _ := Encrypt([]byte("secret"))
+
+	//k2 becomes the current, k1 is still readable.
+	useKeys(t, "k2:"+testKey('b')+",k1:"+testKey('a'))
+	if !NeedsReEncrypt(s) {
+		t.Errorf("NeedsReEncrypt(%s) = false with the old key", s)
+	}
+	s, err := ReEncrypt(s)
+	if err != nil || !strings.HasPrefix(s, "aesgcm:k2:") || NeedsReEncrypt(s) {
+		t.Errorf("ReEncrypt() = %s, %v", s, err)
+	}
+	if NeedsReEncrypt("aesgcm:k1") {
+		t.Error("NeedsReEncrypt() = true for the invalid ciphertext")
+	}
+}
@@ -1,51 +1,34 @@
 package descbc
 
 import (
-	"bytes"
 	"crypto/cipher"
 	"crypto/des"
+	"errors"
 
 	"k8s.io/klog/v2"
 )
 
-func Pkcs5Padding(ciphertext []byte, blockSize int) []byte {
-	padding := blockSize - len(ciphertext)%blockSize
-	padtext := bytes.Repeat([]byte{byte(padding)}, padding)
-
-	return append(ciphertext, padtext...)
-}
-
-func Pkcs5UnPadding(data []byte) []byte {
-	length := len(data)
-	unpadding := int(data[length-1])
-
-	return data[:length-unpadding]
-}
-
 /*
-* DES CBC PKCS5padding ecrypt.
+* DES CBC is only kept to read the legacy data, it must not be used
+* to encrypt the new data.
  */
-func Encrypt(data, key []byte) ([]byte, error) {
 
-	block, err := des.NewCipher(key)
-	if err != nil {
-		klog.Errorf("err: %v", err)
-		return nil, err
-	}
-
-	blksz := block.BlockSize()
-
-	//blockmodes do encrypts/ decrypts a number of blocks
-	blockMode := cipher.NewCBCEncrypter(block, key[:blksz])
+var (
+	ErrInvalidPadding = errors.New("invalid DES padding")
+)
 
-	//padding data.
-	data = Pkcs5Padding(data, blksz)
-	crypted := make([]byte, len(data))
+func Pkcs5UnPadding(data []byte) ([]byte, error) {
+	length := len(data)
+	if length == 0 {
+		return nil, ErrInvalidPadding
+	}
 
-	//encrypt the padding data
-	blockMode.CryptBlocks(crypted, data)
+	unpadding := int(data[length-1])
+	if unpadding == 0 || unpadding > des.BlockSize || unpadding > length {
+		return nil, ErrInvalidPadding
+	}
 
-	return crypted, nil
+	return data[:length-unpadding], nil
 }
 
 /*
@@ -59,6 +42,9 @@ func Decrypt(crypted, key []byte) ([]byte, error) {
 	}
 
 	blksz := block.BlockSize()
+	if len(crypted) == 0 || len(crypted)%blksz != 0 {
+		return nil, ErrInvalidPadding
+	}
 
 	//blockmodes do decrypts a number of blocks
 	blockMode := cipher.NewCBCDecrypter(block, key[:blksz])
@@ -67,7 +53,5 @@ func Decrypt(crypted, key []byte) ([]byte, error) {
 	data := make([]byte, len(crypted))
 	blockMode.CryptBlocks(data, crypted)
 
-	data = Pkcs5UnPadding(data)
-
-	return data, nil
+	return Pkcs5UnPadding(data)
 }
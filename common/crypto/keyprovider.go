@@ -0,0 +1,308 @@
+package crypto
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/edgehook/ithings/common/config"
+	"github.com/edgehook/ithings/common/crypto/aesgcm"
+	"k8s.io/klog/v2"
+)
+
+var (
+	ErrNoKey = errors.New("no encryption key")
+
+	keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,32}$`)
+)
+
+// normalizeKeyID lowercase the key id, viper lowercases the keys of crypto.keys.
+func normalizeKeyID(id string) string {
+	return strings.ToLower(id)
+}
+
+/*
+* KeyProvider
+* provide the AES-256 keys by id, the current key encrypts the new data
+* and all the keys are kept to decrypt the old data.
+ */
+type KeyProvider interface {
+	//CurrentKey return the key to encrypt, ErrNoKey if there is none.
+	CurrentKey() (string, []byte, error)
+	//Key return the key of the id, ErrNoKey if it doesn't exist.
+	Key(id string) ([]byte, error)
+}
+
+// staticKeys is the keys loaded once, the current is the id of the key to encrypt.
+type staticKeys struct {
+	keys    map[string][]byte
+	current string
+}
+
+func (s *staticKeys) CurrentKey() (string, []byte, error) {
+	if s.current == "" {
+		return "", nil, ErrNoKey
+	}
+	return s.current, s.keys[s.current], nil
+}
+
+func (s *staticKeys) Key(id string) ([]byte, error) {
+	key, exist := s.keys[normalizeKeyID(id)]
+	if !exist {
+		return nil, ErrNoKey
+	}
+	return key, nil
+}
+
+func (s *staticKeys) add(id, value string) error {
+	if !keyIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid key id %q", id)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil || len(key) != aesgcm.KeySize {
+		return fmt.Errorf("the key %s should be the base64 of 32 bytes", id)
+	}
+
+	if s.keys == nil {
+		s.keys = make(map[string][]byte)
+	}
+	s.keys[normalizeKeyID(id)] = key
+	return nil
+}
+
+/*
+* parseKeyList
+* parse the "id:key" list separated by comma or newline, the blank lines
+* and the lines starting with # are ignored.
+ */
+func parseKeyList(list string, sep string) ([]string, []string, error) {
+	var ids, values []string
+
+	for _, item := range strings.Split(list, sep) {
+		item = strings.TrimSpace(item)
+		if item == "" || strings.HasPrefix(item, "#") {
+			continue
+		}
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 {
+			return nil, nil, fmt.Errorf("the key should be \"id:key\"")
+		}
+		ids = append(ids, strings.TrimSpace(parts[0]))
+		values = append(values, parts[1])
+	}
+
+	return ids, values, nil
+}
+
+/*
+* NewConfigKeyProvider
+* return the keys in the config file, the current is the key id of the
+* config, or the only key if there is one.
+ */
+func NewConfigKeyProvider(keys map[string]string, keyID string) (KeyProvider, error) {
+	s := &staticKeys{}
+	for id, value := range keys {
+		if err := s.add(id, value); err != nil {
+			return nil, err
+		}
+		if len(keys) == 1 {
+			s.current = normalizeKeyID(id)
+		}
+	}
+	if _, exist := s.keys[normalizeKeyID(keyID)]; exist {
+		s.current = normalizeKeyID(keyID)
+	}
+
+	return s, nil
+}
+
+// NewEnvKeyProvider return the keys in the env, the first key is the current.
+func NewEnvKeyProvider(value string) (KeyProvider, error) {
+	s := &staticKeys{}
+	ids, values, err := parseKeyList(value, ",")
+	if err != nil {
+		return nil, err
+	}
+	for i, id := range ids {
+		if err := s.add(id, values[i]); err != nil {
+			return nil, err
+		}
+	}
+	if len(ids) > 0 {
+		s.current = normalizeKeyID(ids[0])
+	}
+
+	return s, nil
+}
+
+/*
+* fileKeyProvider
+* the keys in the local keyfile, one "id:key" per line and the last key is
+* the current. A new key is generated into the keyfile if it's needed and
+* there is no key, append a new line to rotate the key.
+ */
+type fileKeyProvider struct {
+	sync.Mutex
+	path   string
+	keys   *staticKeys
+	loaded bool
+}
+
+func NewFileKeyProvider(path string) KeyProvider {
+	return &fileKeyProvider{path: path}
+}
+
+func (f *fileKeyProvider) load() (*staticKeys, error) {
+	if f.loaded {
+		return f.keys, nil
+	}
+
+	s := &staticKeys{}
+	data, err := os.ReadFile(f.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	ids, values, err := parseKeyList(string(data), "\n")
+	if err != nil {
+		return nil, fmt.Errorf("keyfile %s: %v", f.path, err)
+	}
+	for i, id := range ids {
+		if err := s.add(id, values[i]); err != nil {
+			return nil, fmt.Errorf("keyfile %s: %v", f.path, err)
+		}
+		s.current = normalizeKeyID(id)
+	}
+
+	f.keys, f.loaded = s, true
+	return s, nil
+}
+
+// generate append a random key to the keyfile and make it current.
+func (f *fileKeyProvider) generate() error {
+	buf := make([]byte, aesgcm.KeySize+4)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	id := "k" + hex.EncodeToString(buf[aesgcm.KeySize:])
+	value := base64.StdEncoding.EncodeToString(buf[:aesgcm.KeySize])
+
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	fmt.Fprintf(w, "%s:%s\n", id, value)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	klog.Infof("generate the encryption key %s into %s", id, f.path)
+	if err := f.keys.add(id, value); err != nil {
+		return err
+	}
+	f.keys.current = id
+	return nil
+}
+
+func (f *fileKeyProvider) CurrentKey() (string, []byte, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	s, err := f.load()
+	if err != nil {
+		return "", nil, err
+	}
+	if s.current == "" {
+		if err := f.generate(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return s.CurrentKey()
+}
+
+func (f *fileKeyProvider) Key(id string) ([]byte, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	s, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	return s.Key(id)
+}
+
+/*
+* chainKeyProvider
+* search the keys in the providers by order, the current key is the key
+* id if it's set, or the current key of the first provider having one.
+ */
+type chainKeyProvider struct {
+	keyID     string
+	providers []KeyProvider
+}
+
+func NewChainKeyProvider(keyID string, providers ...KeyProvider) KeyProvider {
+	return &chainKeyProvider{
+		keyID:     normalizeKeyID(keyID),
+		providers: providers,
+	}
+}
+
+func (c *chainKeyProvider) CurrentKey() (string, []byte, error) {
+	if c.keyID != "" {
+		key, err := c.Key(c.keyID)
+		if err != nil {
+			return "", nil, fmt.Errorf("the key %s: %v", c.keyID, err)
+		}
+		return c.keyID, key, nil
+	}
+
+	for _, p := range c.providers {
+		id, key, err := p.CurrentKey()
+		if errors.Is(err, ErrNoKey) {
+			continue
+		}
+		return id, key, err
+	}
+
+	return "", nil, ErrNoKey
+}
+
+func (c *chainKeyProvider) Key(id string) ([]byte, error) {
+	for _, p := range c.providers {
+		key, err := p.Key(id)
+		if errors.Is(err, ErrNoKey) {
+			continue
+		}
+		return key, err
+	}
+
+	return nil, ErrNoKey
+}
+
+/*
+* NewKeyProviderFromConfig
+* return the keys of the env, the config file and the keyfile by order.
+ */
+func NewKeyProviderFromConfig(cfg *config.CryptoConfig) (KeyProvider, error) {
+	envKeys, err := NewEnvKeyProvider(cfg.EnvKeys)
+	if err != nil {
+		return nil, fmt.Errorf("env %s: %v", config.EnvCryptoKey, err)
+	}
+	configKeys, err := NewConfigKeyProvider(cfg.Keys, cfg.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("crypto.keys: %v", err)
+	}
+
+	return NewChainKeyProvider(cfg.KeyID, envKeys, configKeys, NewFileKeyProvider(cfg.KeyFile)), nil
+}
@@ -0,0 +1,195 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/edgehook/ithings/common/config"
+)
+
+// testKey return the base64 of the 32 bytes key filled with c.
+func testKey(c byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{c}, 32))
+}
+
+func checkCurrentKey(t *testing.T, p KeyProvider, wantID string, c byte) {
+	t.Helper()
+
+	id, key, err := p.CurrentKey()
+	if err != nil {
+		t.Fatalf("CurrentKey() = %v", err)
+	}
+	if id != wantID || !bytes.Equal(key, bytes.Repeat([]byte{c}, 32)) {
+		t.Errorf("CurrentKey() = %s %x, want %s", id, key, wantID)
+	}
+}
+
+func checkKey(t *testing.T, p KeyProvider, id string, c byte) {
+	t.Helper()
+
+	key, err := p.Key(id)
+	if err != nil {
+		t.Fatalf("Key(%s) = %v", id, err)
+	}
+	if !bytes.Equal(key, bytes.Repeat([]byte{c}, 32)) {
+		t.Errorf("Key(%s) = %x", id, key)
+	}
+}
+
+func TestEnvKeyProvider(t *testing.T) {
+	p, err := NewEnvKeyProvider(" E1:" + testKey('a') + ", e2:" + testKey('b') + ",")
+	if err != nil {
+		t.Fatalf("NewEnvKeyProvider() = %v", err)
+	}
+	checkCurrentKey(t, p, "e1", 'a')
+	checkKey(t, p, "E2", 'b')
+	if _, err := p.Key("e3"); err != ErrNoKey {
+		t.Errorf("Key(e3) = %v, want %v", err, ErrNoKey)
+	}
+
+	empty, err := NewEnvKeyProvider("")
+	if err != nil {
+		t.Fatalf("NewEnvKeyProvider() = %v", err)
+	}
+	if _, _, err := empty.CurrentKey(); err != ErrNoKey {
+		t.Errorf("CurrentKey() = %v, want %v", err, ErrNoKey)
+	}
+
+	for _, value := range []string{
+		"e1",
+		"e1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		"e1:not base64",
+		"bad id:" + testKey('a'),
+		strings.Repeat("x", 33) + ":" + testKey('a'),
+	} {
+		if _, err := NewEnvKeyProvider(value); err == nil {
+			t.Errorf("NewEnvKeyProvider(%q) succeeds", value)
+		}
+	}
+}
+
+func TestConfigKeyProvider(t *testing.T) {
+	//viper lowercases the keys of crypto.keys, but not the key id.
+	keys := map[string]string{"c1": testKey('a'), "c2": testKey('b')}
+
+	p, err := NewConfigKeyProvider(keys, "C2")
+	if err != nil {
+		t.Fatalf("NewConfigKeyProvider() = %v", err)
+	}
+	checkCurrentKey(t, p, "c2", 'b')
+	checkKey(t, p, "C1", 'a')
+
+	p, err = NewConfigKeyProvider(keys, "")
+	if err != nil {
+		t.Fatalf("NewConfigKeyProvider() = %v", err)
+	}
+	if _, _, err := p.CurrentKey(); err != ErrNoKey {
+		t.Errorf("CurrentKey() of two keys without key id = %v, want %v", err, ErrNoKey)
+	}
+
+	p, err = NewConfigKeyProvider(map[string]string{"C1": testKey('a')}, "")
+	if err != nil {
+		t.Fatalf("NewConfigKeyProvider() = %v", err)
+	}
+	checkCurrentKey(t, p, "c1", 'a')
+}
+
+func TestFileKeyProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crypto.key")
+
+	//the key is generated on the first use.
+	p := NewFileKeyProvider(path)
+	if _, err := p.Key("k1"); err != ErrNoKey {
+		t.Fatalf("Key(k1) = %v, want %v", err, ErrNoKey)
+	}
+	id, key, err := p.CurrentKey()
+	if err != nil {
+		t.Fatalf("CurrentKey() = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read keyfile: %v", err)
+	}
+	if want := id + ":" + base64.StdEncoding.EncodeToString(key) + "\n"; string(data) != want {
+		t.Errorf("keyfile = %q, want %q", data, want)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("keyfile mode = %v, %v", info.Mode(), err)
+	}
+
+	//the appended key is the current, the old one is still found.
+	data = append(data, []byte("\n# rotate\nK2:"+testKey('b')+"\n")...)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("write keyfile: %v", err)
+	}
+	p = NewFileKeyProvider(path)
+	checkCurrentKey(t, p, "k2", 'b')
+	if old, err := p.Key(id); err != nil || !bytes.Equal(old, key) {
+		t.Errorf("Key(%s) = %x, %v", id, old, err)
+	}
+
+	if err := os.WriteFile(path, []byte("k1\n"), 0600); err != nil {
+		t.Fatalf("write keyfile: %v", err)
+	}
+	if _, _, err := NewFileKeyProvider(path).CurrentKey(); err == nil {
+		t.Error("CurrentKey() succeeds with invalid keyfile")
+	}
+}
+
+func TestChainKeyProvider(t *testing.T) {
+	env, _ := NewEnvKeyProvider("e1:" + testKey('a'))
+	cfg, _ := NewConfigKeyProvider(map[string]string{"c1": testKey('b')}, "")
+	file := NewFileKeyProvider(filepath.Join(t.TempDir(), "crypto.key"))
+
+	//the env comes first.
+	p := NewChainKeyProvider("", env, cfg, file)
+	checkCurrentKey(t, p, "e1", 'a')
+	checkKey(t, p, "c1", 'b')
+	if _, err := p.Key("x1"); err != ErrNoKey {
+		t.Errorf("Key(x1) = %v, want %v", err, ErrNoKey)
+	}
+
+	//the key id selects the key in any provider.
+	checkCurrentKey(t, NewChainKeyProvider("C1", env, cfg, file), "c1", 'b')
+	if _, _, err := NewChainKeyProvider("x1", env, cfg, file).CurrentKey(); err == nil {
+		t.Error("CurrentKey() succeeds with missing key id")
+	}
+
+	//the keyfile is generated without any other key.
+	empty, _ := NewEnvKeyProvider("")
+	id, _, err := NewChainKeyProvider("", empty, file).CurrentKey()
+	if err != nil || !strings.HasPrefix(id, "k") {
+		t.Errorf("CurrentKey() = %s, %v", id, err)
+	}
+}
+
+func TestNewKeyProviderFromConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crypto.key")
+	if err := os.WriteFile(path, []byte("f1:"+testKey('c')+"\n"), 0600); err != nil {
+		t.Fatalf("write keyfile: %v", err)
+	}
+
+	p, err := NewKeyProviderFromConfig(&config.CryptoConfig{
+		KeyID:   "C1",
+		Keys:    map[string]string{"c1": testKey('b')},
+		EnvKeys: "e1:" + testKey('a'),
+		KeyFile: path,
+	})
+	if err != nil {
+		t.Fatalf("NewKeyProviderFromConfig() = %v", err)
+	}
+	checkCurrentKey(t, p, "c1", 'b')
+	checkKey(t, p, "e1", 'a')
+	checkKey(t, p, "F1", 'c')
+
+	if _, err := NewKeyProviderFromConfig(&config.CryptoConfig{EnvKeys: "e1"}); err == nil {
+		t.Error("NewKeyProviderFromConfig() succeeds with invalid env")
+	}
+	if _, err := NewKeyProviderFromConfig(&config.CryptoConfig{Keys: map[string]string{"c1": "short"}}); err == nil {
+		t.Error("NewKeyProviderFromConfig() succeeds with invalid config keys")
+	}
+}
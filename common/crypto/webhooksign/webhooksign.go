@@ -0,0 +1,35 @@
+package webhooksign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+)
+
+const (
+	//the signature is "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body))
+	SignatureHeader = "X-IThings-Signature"
+	TimestampHeader = "X-IThings-Timestamp"
+
+	signaturePrefix = "sha256="
+)
+
+// Sign calculate the signature of the body sent at the timestamp.
+func Sign(secret []byte, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SetHeaders set the timestamp(in ms) and the signature headers of the request.
+func SetHeaders(header http.Header, secret []byte, ts int64, body []byte) {
+	timestamp := strconv.FormatInt(ts, 10)
+
+	header.Set(TimestampHeader, timestamp)
+	header.Set(SignatureHeader, Sign(secret, timestamp, body))
+}
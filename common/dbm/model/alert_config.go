@@ -12,6 +12,7 @@ type AlertConfig struct {
 	Description     string `gorm:"column:description; type:varchar(256);" json:"description"`
 	Level           int64  `gorm:"column:level;" json:"level"`
 	Notification    string `gorm:"column:notification; type:varchar(1024);" json:"notification"`
+	Escalation      string `gorm:"column:escalation; type:text;" json:"escalation"`
 	CreateTimeStamp int64  `gorm:"column:create_time_stamp;" json:"createTimeStamp"`
 	UpdateTimeStamp int64  `gorm:"column:update_time_stamp;autoUpdateTime:milli" json:"updateTimeStamp"`
 }
@@ -67,6 +68,18 @@ func GetAlertCountByKeywords(keywords string) (int64, error) {
 	}
 	return count, err
 }
+
+// get the alert configs which have escalation policies.
+func GetAlertWithEscalation() ([]*AlertConfig, error) {
+	var alerts []*AlertConfig
+	err := global.DBAccess.Where("escalation <> ''").Find(&alerts).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return alerts, err
+}
+
 func GetAlertByName(name string) (*AlertConfig, error) {
 	alert := &AlertConfig{}
 	err := global.DBAccess.Where("name = ?", name).First(alert).Error
@@ -101,6 +114,7 @@ func SaveAlert(id int64, alert *AlertConfig) error {
 		"Description":  alert.Description,
 		"Level":        alert.Level,
 		"Notification": alert.Notification,
+		"Escalation":   alert.Escalation,
 	}).Error
 	if err != nil {
 		klog.Errorf("err: %v", err)
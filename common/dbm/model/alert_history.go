@@ -7,14 +7,20 @@ import (
 )
 
 type AlertHistory struct {
-	ID              int64  `gorm:"primary_key; auto_increment" json:"id"`
-	Name            string `gorm:"column:name; not null; type:varchar(256);" json:"name"`
-	Description     string `gorm:"column:description; type:varchar(256);" json:"description"`
-	Level           int64  `gorm:"column:level;" json:"level"`
-	EdgeName        string `gorm:"column:edge_name; type:varchar(256);" json:"edgeName"`
-	EdgeId          string `gorm:"column:edge_id; type:varchar(256);" json:"edgeId"`
-	DeviceName      string `gorm:"column:device_name; type:varchar(256);" json:"deviceName"`
-	DeviceId        string `gorm:"column:device_id; type:varchar(256);" json:"deviceId"`
+	ID          int64  `gorm:"primary_key; auto_increment" json:"id"`
+	Name        string `gorm:"column:name; not null; type:varchar(256);" json:"name"`
+	Description string `gorm:"column:description; type:varchar(256);" json:"description"`
+	Level       int64  `gorm:"column:level;" json:"level"`
+	EdgeName    string `gorm:"column:edge_name; type:varchar(256);" json:"edgeName"`
+	EdgeId      string `gorm:"column:edge_id; type:varchar(256);" json:"edgeId"`
+	DeviceName  string `gorm:"column:device_name; type:varchar(256);" json:"deviceName"`
+	DeviceId    string `gorm:"column:device_id; type:varchar(256);" json:"deviceId"`
+	//the status transition of the alert log.
+	AlertLogId      int64  `gorm:"column:alert_log_id; index" json:"alertLogId"`
+	FromStatus      int32  `gorm:"column:from_status;" json:"fromStatus"`
+	ToStatus        int32  `gorm:"column:to_status;" json:"toStatus"`
+	Operator        string `gorm:"column:operator; type:varchar(256);" json:"operator"`
+	Comment         string `gorm:"column:comment; type:text;" json:"comment"`
 	CreateTimeStamp int64  `gorm:"column:create_time_stamp;" json:"createTimeStamp"`
 	UpdateTimeStamp int64  `gorm:"column:update_time_stamp;autoUpdateTime:milli" json:"updateTimeStamp"`
 }
@@ -183,6 +189,16 @@ func GetAlertHistoryByName(name string) ([]*AlertHistory, error) {
 	return alertHistorys, err
 }
 
+func GetAlertHistoryByAlertLogId(alertLogId int64) ([]*AlertHistory, error) {
+	var alertHistorys []*AlertHistory
+	err := global.DBAccess.Where("alert_log_id = ?", alertLogId).Order("create_time_stamp asc, id asc").Find(&alertHistorys).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return alertHistorys, err
+}
+
 func AddAlertHistory(alertHistory *AlertHistory) error {
 	alertHistory.CreateTimeStamp = time.Now().UnixNano() / 1e6
 	err := global.DBAccess.Create(&alertHistory).Error
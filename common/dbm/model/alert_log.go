@@ -4,24 +4,38 @@ import (
 	"time"
 
 	"github.com/edgehook/ithings/common/global"
+	"gorm.io/gorm"
 	"k8s.io/klog/v2"
 )
 
 type AlertLog struct {
-	ID              int64  `gorm:"primary_key; auto_increment" json:"id"`
-	Name            string `gorm:"column:name; not null; type:varchar(256);" json:"name"`
-	LogType         string `gorm:"column:log_type; type:varchar(256);" json:"logType"`
-	Description     string `gorm:"column:description; type:varchar(256);" json:"description"`
-	Level           int64  `gorm:"column:level;" json:"level"`
-	EdgeName        string `gorm:"column:edge_name; type:varchar(256);" json:"edgeName"`
-	EdgeId          string `gorm:"column:edge_id; type:varchar(256);" json:"edgeId"`
-	DeviceName      string `gorm:"column:device_name; type:varchar(256);" json:"deviceName"`
-	DeviceId        string `gorm:"column:device_id; type:varchar(256);" json:"deviceId"`
-	HandleStatus    int32  `gorm:"column:handleStatus;" json:"handleStatus"`
-	Status          int32  `gorm:"column:status;" json:"status"`
-	Record          string `gorm:"column:record; type:text;" json:"record"`
-	CreateTimeStamp int64  `gorm:"column:create_time_stamp;" json:"createTimeStamp"`
-	UpdateTimeStamp int64  `gorm:"column:update_time_stamp;autoUpdateTime:milli" json:"updateTimeStamp"`
+	ID           int64  `gorm:"primary_key; auto_increment" json:"id"`
+	Name         string `gorm:"column:name; not null; type:varchar(256);" json:"name"`
+	LogType      string `gorm:"column:log_type; type:varchar(256);" json:"logType"`
+	Description  string `gorm:"column:description; type:varchar(256);" json:"description"`
+	Level        int64  `gorm:"column:level;" json:"level"`
+	EdgeName     string `gorm:"column:edge_name; type:varchar(256);" json:"edgeName"`
+	EdgeId       string `gorm:"column:edge_id; type:varchar(256);" json:"edgeId"`
+	DeviceName   string `gorm:"column:device_name; type:varchar(256);" json:"deviceName"`
+	DeviceId     string `gorm:"column:device_id; type:varchar(256);" json:"deviceId"`
+	HandleStatus int32  `gorm:"column:handleStatus;" json:"handleStatus"`
+	Status       int32  `gorm:"column:status;" json:"status"`
+	Record       string `gorm:"column:record; type:text;" json:"record"`
+	//the service/event which triggers this alert.
+	Trigger               string `gorm:"column:trigger_key; type:varchar(512);" json:"trigger"`
+	AcknowledgedBy        string `gorm:"column:acknowledged_by; type:varchar(256);" json:"acknowledgedBy"`
+	AcknowledgedTimeStamp int64  `gorm:"column:acknowledged_time_stamp;" json:"acknowledgedTimeStamp"`
+	ResolvedBy            string `gorm:"column:resolved_by; type:varchar(256);" json:"resolvedBy"`
+	ResolvedTimeStamp     int64  `gorm:"column:resolved_time_stamp;" json:"resolvedTimeStamp"`
+	//the count and the last time of the identical alerts.
+	RepeatCount   int64 `gorm:"column:repeat_count; default:1" json:"repeatCount"`
+	LastTimeStamp int64 `gorm:"column:last_time_stamp;" json:"lastTimeStamp"`
+	//the notifications are withheld by the silence rules.
+	Silenced bool `gorm:"column:silenced;" json:"silenced"`
+	//the count of the applied escalation steps.
+	EscalationStep  int   `gorm:"column:escalation_step;" json:"escalationStep"`
+	CreateTimeStamp int64 `gorm:"column:create_time_stamp;" json:"createTimeStamp"`
+	UpdateTimeStamp int64 `gorm:"column:update_time_stamp;autoUpdateTime:milli" json:"updateTimeStamp"`
 }
 
 func (AlertLog) TableName() string {
@@ -58,6 +72,90 @@ func GetUnresolvedAlertLogByTypeAndDeviceId(logType, deviceId string, status []i
 	return alertLogs, err
 }
 
+func GetUnresolvedAlertLogByTrigger(logType, deviceId, trigger string, status []int32) ([]*AlertLog, error) {
+	var alertLogs []*AlertLog
+	err := global.DBAccess.Where("log_type = ? and device_id = ? and trigger_key = ? and status in ?", logType, deviceId, trigger, status).Find(&alertLogs).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return alertLogs, err
+}
+
+// get the latest unresolved alert log which is identical with this alert.
+func GetLatestAlertLogByIdentity(name, edgeId, deviceId string, status []int32) (*AlertLog, error) {
+	alertLog := &AlertLog{}
+	err := global.DBAccess.Where("name = ? and edge_id = ? and device_id = ? and status in ?",
+		name, edgeId, deviceId, status).Order("create_time_stamp desc").First(alertLog).Error
+	if err != nil {
+		return nil, err
+	}
+	return alertLog, err
+}
+
+func GetAlertLogByNameAndStatus(name string, status []int32) ([]*AlertLog, error) {
+	var alertLogs []*AlertLog
+	err := global.DBAccess.Where("name = ? and status in ?", name, status).Find(&alertLogs).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return alertLogs, err
+}
+
+/*
+* EscalateAlertLog
+* apply the escalation step if the alert log is still at the previous
+* step and open, and record it into the history in a transaction.
+ */
+func EscalateAlertLog(id int64, prevStep, step int, level int64, status []int32, history *AlertHistory) (bool, error) {
+	tx := global.DBAccess.Begin()
+	result := tx.Model(&AlertLog{}).Where("id = ? and escalation_step = ? and status in ?", id, prevStep, status).Updates(map[string]interface{}{
+		"escalation_step": step,
+		"level":           level,
+	})
+	if result.Error != nil {
+		tx.Rollback()
+		klog.Errorf("err: %v", result.Error)
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	history.CreateTimeStamp = time.Now().UnixNano() / 1e6
+	if err := tx.Create(history).Error; err != nil {
+		tx.Rollback()
+		klog.Errorf("err: %v", err)
+		return false, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		klog.Errorf("err: %v", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// count the repeated alert on the alert log.
+func IncreaseAlertLogRepeat(id int64, record string, ts int64) error {
+	vals := map[string]interface{}{
+		"repeat_count":    gorm.Expr("COALESCE(repeat_count, 1) + 1"),
+		"last_time_stamp": ts,
+	}
+	if record != "" {
+		vals["record"] = record
+	}
+
+	err := global.DBAccess.Model(&AlertLog{}).Where("id = ?", id).Updates(vals).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
 func GetAlertLogById(id int64) (*AlertLog, error) {
 	var alertLog *AlertLog
 	err := global.DBAccess.Where(&AlertLog{ID: id}).Find(&alertLog).Error
@@ -106,9 +204,9 @@ func GetAlertLogCountByType(logType string) (int64, error) {
 	}
 	return count, err
 }
-func GetAlertLogByPageAndCondition(page int, limit int, name, edgeId string, status *int32, level *int64, beginTs *int64, endTs *int64, logType string) ([]*AlertLog, error) {
+func GetAlertLogByPageAndCondition(page int, limit int, name, edgeId string, status *int32, level *int64, beginTs *int64, endTs *int64, logType string, scope *DeviceScope) ([]*AlertLog, error) {
 	var alertLogs []*AlertLog
-	tx := global.DBAccess.Model(&AlertLog{})
+	tx := scope.applyByDevice(global.DBAccess.Model(&AlertLog{}))
 
 	if name != "" {
 		tx = tx.Where("name = ?", name)
@@ -166,9 +264,9 @@ func GetAlertLogByCondition(name, edgeId string, status *int32, level *int64, be
 	return alertLogs, err
 }
 
-func GetAlertLogCountByCondition(name, edgeId string, status *int32, level *int64, beginTs *int64, endTs *int64, logType string) (int64, error) {
+func GetAlertLogCountByCondition(name, edgeId string, status *int32, level *int64, beginTs *int64, endTs *int64, logType string, scope *DeviceScope) (int64, error) {
 	var count int64
-	tx := global.DBAccess.Model(&AlertLog{})
+	tx := scope.applyByDevice(global.DBAccess.Model(&AlertLog{}))
 
 	if name != "" {
 		tx = tx.Where("name = ?", name)
@@ -327,6 +425,44 @@ func SaveAlertLog(id int64, edgeName string, record string, status *int32, level
 	return nil
 }
 
+/*
+* TransitAlertLogStatus
+* change the status from -> to with the other values, and record the
+* transition into the history in a transaction. return false if the
+* status is not from any more.
+ */
+func TransitAlertLogStatus(id int64, from, to int32, vals map[string]interface{}, history *AlertHistory) (bool, error) {
+	if vals == nil {
+		vals = make(map[string]interface{})
+	}
+	vals["status"] = to
+
+	tx := global.DBAccess.Begin()
+	result := tx.Model(&AlertLog{}).Where("id = ? and status = ?", id, from).Updates(vals)
+	if result.Error != nil {
+		tx.Rollback()
+		klog.Errorf("err: %v", result.Error)
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	history.CreateTimeStamp = time.Now().UnixNano() / 1e6
+	if err := tx.Create(history).Error; err != nil {
+		tx.Rollback()
+		klog.Errorf("err: %v", err)
+		return false, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		klog.Errorf("err: %v", err)
+		return false, err
+	}
+	return true, nil
+}
+
 func DeleteAlertLog(id int64) error {
 	if err := global.DBAccess.Where("id = ?", id).Delete(&AlertLog{}).Error; err != nil {
 		klog.Errorf("err: %v", err)
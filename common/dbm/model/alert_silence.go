@@ -0,0 +1,125 @@
+package model
+
+import (
+	"time"
+
+	"github.com/edgehook/ithings/common/global"
+	"k8s.io/klog/v2"
+)
+
+/*
+* AlertSilence
+* withhold the notifications of the matched alerts, the empty scope
+* matches all. It's a maintenance window if the time range is set.
+ */
+type AlertSilence struct {
+	ID          int64  `gorm:"primary_key; auto_increment" json:"id"`
+	Name        string `gorm:"column:name; not null; type:varchar(256); unique" json:"name"`
+	Description string `gorm:"column:description; type:varchar(256);" json:"description"`
+	Enable      bool   `gorm:"column:enable;" json:"enable"`
+	//scope
+	EdgeId        string `gorm:"column:edge_id; type:varchar(256);" json:"edgeId"`
+	DeviceId      string `gorm:"column:device_id; type:varchar(256);" json:"deviceId"`
+	DeviceModelId *int64 `gorm:"column:device_model_id;" json:"deviceModelId"`
+	Level         *int64 `gorm:"column:level;" json:"level"`
+	//maintenance window, the timestamps in ms, 0 is unlimited.
+	StartTimeStamp int64 `gorm:"column:start_time_stamp;" json:"startTimeStamp"`
+	EndTimeStamp   int64 `gorm:"column:end_time_stamp;" json:"endTimeStamp"`
+	//daily window, HH:MM or HH:MM:SS
+	DailyStartTime  string `gorm:"column:daily_start_time; type:varchar(16);" json:"dailyStartTime"`
+	DailyEndTime    string `gorm:"column:daily_end_time; type:varchar(16);" json:"dailyEndTime"`
+	CreateTimeStamp int64  `gorm:"column:create_time_stamp;" json:"createTimeStamp"`
+	UpdateTimeStamp int64  `gorm:"column:update_time_stamp;autoUpdateTime:milli" json:"updateTimeStamp"`
+}
+
+func (AlertSilence) TableName() string {
+	return "alert_silence"
+}
+
+func GetEnabledAlertSilence() ([]*AlertSilence, error) {
+	var silences []*AlertSilence
+	err := global.DBAccess.Where("enable = ?", true).Find(&silences).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return silences, err
+}
+
+func GetAlertSilenceByPageAndKeywords(page int, limit int, keywords string) ([]*AlertSilence, error) {
+	var silences []*AlertSilence
+	err := global.DBAccess.Where("name LIKE ?", "%"+keywords+"%").Offset((page - 1) * limit).Limit(limit).Order("update_time_stamp desc").Find(&silences).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return silences, err
+}
+
+func GetAlertSilenceCountByKeywords(keywords string) (int64, error) {
+	var count int64
+	err := global.DBAccess.Model(&AlertSilence{}).Where("name LIKE ?", "%"+keywords+"%").Count(&count).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return -1, err
+	}
+	return count, err
+}
+
+func GetAlertSilenceById(id int64) (*AlertSilence, error) {
+	silence := &AlertSilence{}
+	err := global.DBAccess.First(silence, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return silence, err
+}
+
+func GetAlertSilenceByName(name string) (*AlertSilence, error) {
+	silence := &AlertSilence{}
+	err := global.DBAccess.Where("name = ?", name).First(silence).Error
+	if err != nil {
+		return nil, err
+	}
+	return silence, err
+}
+
+func AddAlertSilence(silence *AlertSilence) error {
+	silence.CreateTimeStamp = time.Now().UnixNano() / 1e6
+	err := global.DBAccess.Create(&silence).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func SaveAlertSilence(id int64, silence *AlertSilence) error {
+	err := global.DBAccess.Model(&AlertSilence{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"Name":           silence.Name,
+		"Description":    silence.Description,
+		"Enable":         silence.Enable,
+		"EdgeId":         silence.EdgeId,
+		"DeviceId":       silence.DeviceId,
+		"DeviceModelId":  silence.DeviceModelId,
+		"Level":          silence.Level,
+		"StartTimeStamp": silence.StartTimeStamp,
+		"EndTimeStamp":   silence.EndTimeStamp,
+		"DailyStartTime": silence.DailyStartTime,
+		"DailyEndTime":   silence.DailyEndTime,
+	}).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func DeleteAlertSilence(id int64) error {
+	err := global.DBAccess.Delete(&AlertSilence{}, id).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
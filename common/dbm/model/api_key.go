@@ -0,0 +1,142 @@
+package model
+
+import (
+	"time"
+
+	"github.com/edgehook/ithings/common/global"
+	"k8s.io/klog/v2"
+)
+
+/*
+* APIKey
+* the key of the scripts, only the sha256 of the key is kept and the prefix
+* is used to find it. The scopes are a json list of "resource:action", they
+* are restricted by the role and the device scope of the owner.
+ */
+type APIKey struct {
+	ID          int64  `gorm:"primary_key; auto_increment" json:"id"`
+	Name        string `gorm:"column:name; not null; type:varchar(128); unique" json:"name"`
+	Description string `gorm:"column:description; type:varchar(256);" json:"description"`
+	Prefix      string `gorm:"column:prefix; not null; type:varchar(32); unique" json:"prefix"`
+	KeyHash     string `gorm:"column:key_hash; not null; type:varchar(128);" json:"-"`
+	Scopes      string `gorm:"column:scopes; type:text;" json:"scopes"`
+	OwnerId     int64  `gorm:"column:owner_id; index" json:"ownerId"`
+	Disabled    bool   `gorm:"column:disabled;" json:"disabled"`
+	//requests per second and burst of the token bucket, 0 is the default.
+	RateLimit float64 `gorm:"column:rate_limit;" json:"rateLimit"`
+	Burst     int     `gorm:"column:burst;" json:"burst"`
+	//timestamps in ms, 0 never expires.
+	ExpireTimeStamp   int64  `gorm:"column:expire_time_stamp;" json:"expireTimeStamp"`
+	LastUsedTimeStamp int64  `gorm:"column:last_used_time_stamp;" json:"lastUsedTimeStamp"`
+	LastUsedAddress   string `gorm:"column:last_used_address; type:varchar(64);" json:"lastUsedAddress"`
+	CreateTimeStamp   int64  `gorm:"column:create_time_stamp;" json:"createTimeStamp"`
+	UpdateTimeStamp   int64  `gorm:"column:update_time_stamp;autoUpdateTime:milli" json:"updateTimeStamp"`
+}
+
+func (APIKey) TableName() string {
+	return "api_key"
+}
+
+func GetAPIKeyByPageAndKeywords(page int, limit int, keywords string) ([]*APIKey, error) {
+	var keys []*APIKey
+	err := global.DBAccess.Where("name LIKE ?", "%"+keywords+"%").Offset((page - 1) * limit).Limit(limit).Order("id").Find(&keys).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return keys, err
+}
+
+func GetAPIKeyCountByKeywords(keywords string) (int64, error) {
+	var count int64
+	err := global.DBAccess.Model(&APIKey{}).Where("name LIKE ?", "%"+keywords+"%").Count(&count).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return -1, err
+	}
+	return count, err
+}
+
+func GetAPIKeyById(id int64) (*APIKey, error) {
+	key := &APIKey{}
+	err := global.DBAccess.First(key, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return key, err
+}
+
+func GetAPIKeyByName(name string) (*APIKey, error) {
+	key := &APIKey{}
+	err := global.DBAccess.Where("name = ?", name).First(key).Error
+	if err != nil {
+		return nil, err
+	}
+	return key, err
+}
+
+func GetAPIKeyByPrefix(prefix string) (*APIKey, error) {
+	key := &APIKey{}
+	err := global.DBAccess.Where("prefix = ?", prefix).First(key).Error
+	if err != nil {
+		return nil, err
+	}
+	return key, err
+}
+
+func AddAPIKey(key *APIKey) error {
+	key.CreateTimeStamp = time.Now().UnixNano() / 1e6
+	err := global.DBAccess.Create(&key).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func SaveAPIKey(id int64, key *APIKey) error {
+	err := global.DBAccess.Model(&APIKey{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"Description":     key.Description,
+		"Scopes":          key.Scopes,
+		"Disabled":        key.Disabled,
+		"RateLimit":       key.RateLimit,
+		"Burst":           key.Burst,
+		"ExpireTimeStamp": key.ExpireTimeStamp,
+	}).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+// SaveAPIKeyLastUsed record the last use without changing the update time.
+func SaveAPIKeyLastUsed(id int64, ts int64, address string) error {
+	err := global.DBAccess.Model(&APIKey{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"last_used_time_stamp": ts,
+		"last_used_address":    address,
+	}).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func DeleteAPIKey(id int64) error {
+	err := global.DBAccess.Delete(&APIKey{}, id).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func DeleteAPIKeyByOwnerId(ownerId int64) error {
+	err := global.DBAccess.Where("owner_id = ?", ownerId).Delete(&APIKey{}).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
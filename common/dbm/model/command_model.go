@@ -28,6 +28,17 @@ func GetCommandModelByServiceModelId(serviceId int64) ([]*CommandModel, error) {
 	}
 	return commandModel, err
 }
+
+func GetCommandModelByCommandId(commandId int64) (*CommandModel, error) {
+	var commandModel *CommandModel
+	err := global.DBAccess.First(&commandModel, commandId).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return commandModel, err
+	}
+	return commandModel, err
+}
+
 func IsExistCommandModel(serviceModelId int64, commandName string) bool {
 	var count int64
 	err := global.DBAccess.Model(&CommandModel{}).Where(CommandModel{ServiceModelId: serviceModelId, Name: commandName}).Count(&count).Error
@@ -0,0 +1,223 @@
+package model
+
+import (
+	"time"
+
+	"github.com/edgehook/ithings/common/global"
+	"github.com/edgehook/ithings/common/utils"
+	"k8s.io/klog/v2"
+)
+
+// DataForwardOutbox hold the failed forward messages for retrying.
+type DataForwardOutbox struct {
+	ID            string `gorm:"column:id; type:varchar(36); primary_key;" json:"id"`
+	DataForwardId string `gorm:"column:data_forward_id; type:varchar(36); index" json:"dataForwardId"`
+	Name          string `gorm:"column:name; type:varchar(256);" json:"name"`
+	DeviceId      string `gorm:"column:device_id; type:varchar(256);" json:"deviceId"`
+	Payload       string `gorm:"column:payload; type:text;" json:"payload"`
+	Attempts      int    `gorm:"column:attempts;" json:"attempts"`
+	Error         string `gorm:"column:error; type:text;" json:"error"`
+	//the data forward log of this message.
+	LogId           string `gorm:"column:log_id; type:varchar(36);" json:"logId"`
+	CreateTimeStamp int64  `gorm:"column:create_time_stamp;" json:"createTimeStamp"`
+	UpdateTimeStamp int64  `gorm:"column:update_time_stamp;autoUpdateTime:milli" json:"updateTimeStamp"`
+}
+
+func (DataForwardOutbox) TableName() string {
+	return "data_forward_outbox"
+}
+
+// DataForwardDeadLetter hold the messages which are failed after all attempts.
+type DataForwardDeadLetter struct {
+	ID            string `gorm:"column:id; type:varchar(36); primary_key;" json:"id"`
+	DataForwardId string `gorm:"column:data_forward_id; type:varchar(36); index" json:"dataForwardId"`
+	Name          string `gorm:"column:name; type:varchar(256);" json:"name"`
+	DeviceId      string `gorm:"column:device_id; type:varchar(256);" json:"deviceId"`
+	Payload       string `gorm:"column:payload; type:text;" json:"payload"`
+	Attempts      int    `gorm:"column:attempts;" json:"attempts"`
+	Error         string `gorm:"column:error; type:text;" json:"error"`
+	//the outbox message which is moved into dead letter.
+	OutboxId        string `gorm:"column:outbox_id; type:varchar(36); index" json:"outboxId"`
+	LogId           string `gorm:"column:log_id; type:varchar(36);" json:"logId"`
+	CreateTimeStamp int64  `gorm:"column:create_time_stamp;" json:"createTimeStamp"`
+	DeadTimeStamp   int64  `gorm:"column:dead_time_stamp;" json:"deadTimeStamp"`
+}
+
+func (DataForwardDeadLetter) TableName() string {
+	return "data_forward_dead_letter"
+}
+
+func AddDataForwardOutbox(outbox *DataForwardOutbox) error {
+	outbox.CreateTimeStamp = time.Now().UnixNano() / 1e6
+	err := global.DBAccess.Create(&outbox).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+// get the oldest messages of this data forward.
+func GetDataForwardOutboxByDataForwardId(dataForwardId string, limit int) ([]*DataForwardOutbox, error) {
+	var outboxes []*DataForwardOutbox
+	err := global.DBAccess.Where("data_forward_id = ?", dataForwardId).Order("create_time_stamp asc").Limit(limit).Find(&outboxes).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return outboxes, err
+}
+
+// get the data forward ids which have pending messages, except the data forwards
+// whose status is not the given status. The ids of the deleted data forwards are kept.
+func GetDataForwardOutboxForwardIds(status string) ([]string, error) {
+	var ids []string
+	others := global.DBAccess.Model(&DataForward{}).Select("id").Where("status <> ?", status)
+	err := global.DBAccess.Model(&DataForwardOutbox{}).Where("data_forward_id NOT IN (?)", others).
+		Distinct("data_forward_id").Pluck("data_forward_id", &ids).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return ids, err
+}
+
+func GetDataForwardOutboxCountByDataForwardId(dataForwardId string) (int64, error) {
+	var count int64
+	err := global.DBAccess.Model(&DataForwardOutbox{}).Where("data_forward_id = ?", dataForwardId).Count(&count).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return -1, err
+	}
+	return count, err
+}
+
+func SaveDataForwardOutboxAttempts(id string, attempts int, error string) error {
+	err := global.DBAccess.Model(&DataForwardOutbox{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"Attempts": attempts,
+		"Error":    error,
+	}).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func DeleteDataForwardOutbox(id string) error {
+	if err := global.DBAccess.Where("id = ?", id).Delete(&DataForwardOutbox{}).Error; err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+// move the message from outbox into dead letter.
+func MoveDataForwardOutboxToDeadLetter(outbox *DataForwardOutbox) error {
+	tx := global.DBAccess.Begin()
+	if err := tx.Create(&DataForwardDeadLetter{
+		ID:              utils.NewUUID(),
+		OutboxId:        outbox.ID,
+		DataForwardId:   outbox.DataForwardId,
+		Name:            outbox.Name,
+		DeviceId:        outbox.DeviceId,
+		Payload:         outbox.Payload,
+		Attempts:        outbox.Attempts,
+		Error:           outbox.Error,
+		LogId:           outbox.LogId,
+		CreateTimeStamp: outbox.CreateTimeStamp,
+		DeadTimeStamp:   time.Now().UnixNano() / 1e6,
+	}).Error; err != nil {
+		tx.Rollback()
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	if err := tx.Where("id = ?", outbox.ID).Delete(&DataForwardOutbox{}).Error; err != nil {
+		tx.Rollback()
+		klog.Errorf("err: %v", err)
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+func GetDataForwardDeadLetterById(id string) (*DataForwardDeadLetter, error) {
+	var deadLetter *DataForwardDeadLetter
+	err := global.DBAccess.Where("id = ?", id).First(&deadLetter).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return deadLetter, err
+}
+
+func GetDataForwardDeadLetterByPageAndCondition(page int, limit int, dataForwardId, deviceId string) ([]*DataForwardDeadLetter, error) {
+	var deadLetters []*DataForwardDeadLetter
+	tx := global.DBAccess.Model(&DataForwardDeadLetter{})
+
+	if dataForwardId != "" {
+		tx = tx.Where("data_forward_id = ?", dataForwardId)
+	}
+	if deviceId != "" {
+		tx = tx.Where("device_id = ?", deviceId)
+	}
+	err := tx.Offset((page - 1) * limit).Limit(limit).Order("dead_time_stamp desc").Find(&deadLetters).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return deadLetters, err
+}
+
+func GetDataForwardDeadLetterCountByCondition(dataForwardId, deviceId string) (int64, error) {
+	var count int64
+	tx := global.DBAccess.Model(&DataForwardDeadLetter{})
+
+	if dataForwardId != "" {
+		tx = tx.Where("data_forward_id = ?", dataForwardId)
+	}
+	if deviceId != "" {
+		tx = tx.Where("device_id = ?", deviceId)
+	}
+	err := tx.Count(&count).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return -1, err
+	}
+	return count, err
+}
+
+func DeleteDataForwardDeadLetter(id string) error {
+	if err := global.DBAccess.Where("id = ?", id).Delete(&DataForwardDeadLetter{}).Error; err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+// delete the dead letters of this data forward, all if the dataForwardId is empty.
+func PurgeDataForwardDeadLetter(dataForwardId string) (int64, error) {
+	tx := global.DBAccess.Where("1 = 1")
+	if dataForwardId != "" {
+		tx = global.DBAccess.Where("data_forward_id = ?", dataForwardId)
+	}
+
+	result := tx.Delete(&DataForwardDeadLetter{})
+	if result.Error != nil {
+		klog.Errorf("err: %v", result.Error)
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+func SaveDataForwardDeadLetterAttempts(id string, attempts int, error string) error {
+	err := global.DBAccess.Model(&DataForwardDeadLetter{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"Attempts": attempts,
+		"Error":    error,
+	}).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"github.com/edgehook/ithings/common/global"
+	"gorm.io/gorm/clause"
+	"k8s.io/klog/v2"
+)
+
+/*
+* DesiredTwin
+* the desired value of the device property until it's converged, the
+* expired one is kept with the error message for a while.
+ */
+type DesiredTwin struct {
+	DeviceID     string `gorm:"column:device_id; type:varchar(36); primary_key;" json:"deviceId"`
+	Service      string `gorm:"column:service; type:varchar(128); primary_key;" json:"service"`
+	PropertyName string `gorm:"column:property_name; type:varchar(128); primary_key;" json:"propertyName"`
+	//the json of the value.
+	Value        string `gorm:"column:value; type:text;" json:"value"`
+	ErrorMessage string `gorm:"column:error_message; type:varchar(256);" json:"errorMessage"`
+	//timestamps in ms.
+	SetTimeStamp    int64 `gorm:"column:set_time_stamp;" json:"setTimeStamp"`
+	ExpireTimeStamp int64 `gorm:"column:expire_time_stamp; index" json:"expireTimeStamp"`
+}
+
+func (DesiredTwin) TableName() string {
+	return "desired_twin"
+}
+
+func GetDesiredTwins() ([]*DesiredTwin, error) {
+	var twins []*DesiredTwin
+	err := global.DBAccess.Find(&twins).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return twins, err
+}
+
+// SaveDesiredTwin create or replace the desired value of the property.
+func SaveDesiredTwin(twin *DesiredTwin) error {
+	err := global.DBAccess.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "device_id"}, {Name: "service"}, {Name: "property_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "error_message", "set_time_stamp", "expire_time_stamp"}),
+	}).Create(twin).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+// SaveDesiredTwinError record the error of the desired value, it's skipped if the value is set again.
+func SaveDesiredTwinError(twin *DesiredTwin, errMsg string) error {
+	err := global.DBAccess.Model(&DesiredTwin{}).
+		Where("device_id = ? AND service = ? AND property_name = ? AND set_time_stamp = ?",
+			twin.DeviceID, twin.Service, twin.PropertyName, twin.SetTimeStamp).
+		Update("error_message", errMsg).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteDesiredTwin delete the desired value, it's skipped if the value is set again.
+func DeleteDesiredTwin(twin *DesiredTwin) error {
+	err := global.DBAccess.Where("device_id = ? AND service = ? AND property_name = ? AND set_time_stamp = ?",
+		twin.DeviceID, twin.Service, twin.PropertyName, twin.SetTimeStamp).Delete(&DesiredTwin{}).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func DeleteDesiredTwinByDeviceId(deviceID string) error {
+	err := global.DBAccess.Where("device_id = ?", deviceID).Delete(&DesiredTwin{}).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
@@ -19,11 +19,14 @@ type DeviceInstance struct {
 	GroupID                  string  `gorm:"column:group_id; type:varchar(64)" json:"groupId,omitempty"`
 	Creator                  string  `gorm:"column:creator; type:varchar(64)" json:"creator,omitempty"`
 	DeviceAuthType           string  `gorm:"column:device_auth_type; type:varchar(64)" json:"deviceAuthType,omitempty"`
-	Secret                   string  `gorm:"column:secret; type:varchar(64)" json:"secret,omitempty"`
-	DeviceType               string  `gorm:"column:device_type; type:varchar(64)" json:"deviceType,omitempty"`
-	GatewayID                string  `gorm:"column:gateway_id; type:varchar(64)" json:"gatewayId,omitempty"`
-	GatewayName              string  `gorm:"column:gateway_name; type:varchar(64)" json:"gatewayName,omitempty"`
-	Tags                     string  `gorm:"column:tags; type:text" json:"tags,omitempty"`
+	//the encrypted secret, the previous one is still accepted until it expires after rotation.
+	Secret                        string `gorm:"column:secret; type:varchar(256)" json:"-"`
+	PreviousSecret                string `gorm:"column:previous_secret; type:varchar(256)" json:"-"`
+	PreviousSecretExpireTimeStamp int64  `gorm:"column:previous_secret_expire_time_stamp" json:"-"`
+	DeviceType                    string `gorm:"column:device_type; type:varchar(64)" json:"deviceType,omitempty"`
+	GatewayID                     string `gorm:"column:gateway_id; type:varchar(64)" json:"gatewayId,omitempty"`
+	GatewayName                   string `gorm:"column:gateway_name; type:varchar(64)" json:"gatewayName,omitempty"`
+	Tags                          string `gorm:"column:tags; type:text" json:"tags,omitempty"`
 	//0: normal, 1: warning, 2: error
 	Health                 int64   `gorm:"column:health;" json:"health,omitempty"`
 	LifeTimeOfDesiredValue int64   `form:"column:ltodv" json:"ltodv,omitempty"`
@@ -318,9 +321,9 @@ func GetDeviceInstanceByPageAndEdgeId(page int, limit int, edgeId string) ([]*De
 	return deviceInstances, err
 }
 
-func GetDeviceInstanceByPageAndCondition(page int, limit int, keywords, protocolType, edgeId string, modelId *int64, deviceStatus string) ([]*DeviceInstance, error) {
+func GetDeviceInstanceByPageAndCondition(page int, limit int, keywords, protocolType, edgeId string, modelId *int64, deviceStatus string, scope *DeviceScope) ([]*DeviceInstance, error) {
 	var deviceInstances []*DeviceInstance
-	tx := global.DBAccess.Model(&DeviceInstance{})
+	tx := scope.apply(global.DBAccess.Model(&DeviceInstance{}))
 
 	if keywords != "" {
 		tx = tx.Where("name LIKE ?", "%"+keywords+"%")
@@ -346,9 +349,9 @@ func GetDeviceInstanceByPageAndCondition(page int, limit int, keywords, protocol
 	return deviceInstances, err
 }
 
-func GetDeviceInstanceCountByCondition(keywords, protocolType, edgeId string, modelId *int64, deviceStatus string) (int64, error) {
+func GetDeviceInstanceCountByCondition(keywords, protocolType, edgeId string, modelId *int64, deviceStatus string, scope *DeviceScope) (int64, error) {
 	var count int64
-	tx := global.DBAccess.Model(&DeviceInstance{})
+	tx := scope.apply(global.DBAccess.Model(&DeviceInstance{}))
 
 	if keywords != "" {
 		tx = tx.Where("name LIKE ?", "%"+keywords+"%")
@@ -456,6 +459,57 @@ func SaveDeviceInstance(deviceId string, deviceInstance *DeviceInstance) error {
 	return nil
 }
 
+// GetDeviceInstanceCredentials get the edge and the credential of the devices.
+func GetDeviceInstanceCredentials(deviceIDs []string) ([]*DeviceInstance, error) {
+	var deviceInstances []*DeviceInstance
+	err := global.DBAccess.Select("device_id", "edge_id", "device_auth_type", "secret",
+		"previous_secret", "previous_secret_expire_time_stamp").Where("device_id IN ?", deviceIDs).Find(&deviceInstances).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return deviceInstances, err
+}
+
+func SaveDeviceInstanceSecret(deviceId string, authType, secret, previousSecret string, previousSecretExpire int64) error {
+	err := global.DBAccess.Model(&DeviceInstance{}).Where("device_id = ?", deviceId).Updates(map[string]interface{}{
+		"device_auth_type":                  authType,
+		"secret":                            secret,
+		"previous_secret":                   previousSecret,
+		"previous_secret_expire_time_stamp": previousSecretExpire,
+	}).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetDeviceInstancesWithSecret get the secrets of the devices having one.
+func GetDeviceInstancesWithSecret() ([]*DeviceInstance, error) {
+	var deviceInstances []*DeviceInstance
+	err := global.DBAccess.Select("device_id", "secret", "previous_secret").
+		Where("secret <> '' OR previous_secret <> ''").Find(&deviceInstances).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return deviceInstances, err
+}
+
+// UpdateDeviceInstanceEncryptedSecret replace the encrypted secrets without changing the update time.
+func UpdateDeviceInstanceEncryptedSecret(deviceId string, secret, previousSecret string) error {
+	err := global.DBAccess.Model(&DeviceInstance{}).Where("device_id = ?", deviceId).UpdateColumns(map[string]interface{}{
+		"secret":          secret,
+		"previous_secret": previousSecret,
+	}).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
 // update
 func UpdateDeviceInstanceProtocol(deviceId string, protocol string) error {
 	err := global.DBAccess.Model(&DeviceInstance{}).Where(" device_id = ?", deviceId).Update("protocol", protocol).Error
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+/*
+* DeviceScope
+* the device groups and edges a user is restricted to, the device is in
+* the scope if its group or edge matches. The nil scope is unlimited and
+* it's only for the admin, the empty scope denies all devices.
+ */
+type DeviceScope struct {
+	GroupIDs []string
+	EdgeIDs  []string
+}
+
+// SplitIDs split the comma separated ids and drop the empty ones.
+func SplitIDs(ids string) []string {
+	list := make([]string, 0)
+	for _, id := range strings.Split(ids, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			list = append(list, id)
+		}
+	}
+	return list
+}
+
+// NewDeviceScope return the empty scope if there is neither group nor edge, it denies all devices.
+func NewDeviceScope(groupIDs, edgeIDs string) *DeviceScope {
+	return &DeviceScope{
+		GroupIDs: SplitIDs(groupIDs),
+		EdgeIDs:  SplitIDs(edgeIDs),
+	}
+}
+
+// IsEmpty indicates whether the scope denies all devices.
+func (s *DeviceScope) IsEmpty() bool {
+	return s != nil && len(s.GroupIDs) == 0 && len(s.EdgeIDs) == 0
+}
+
+func containsID(ids []string, id string) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains check whether the device is in this scope.
+func (s *DeviceScope) Contains(di *DeviceInstance) bool {
+	if s == nil {
+		return true
+	}
+	if di == nil {
+		return false
+	}
+
+	return (di.GroupID != "" && containsID(s.GroupIDs, di.GroupID)) || containsID(s.EdgeIDs, di.EdgeID)
+}
+
+// apply restrict the query of device_instance to this scope.
+func (s *DeviceScope) apply(tx *gorm.DB) *gorm.DB {
+	if s == nil {
+		return tx
+	}
+
+	switch {
+	case s.IsEmpty():
+		return tx.Where("1 = 0")
+	case len(s.GroupIDs) > 0 && len(s.EdgeIDs) > 0:
+		return tx.Where("(group_id IN ? OR edge_id IN ?)", s.GroupIDs, s.EdgeIDs)
+	case len(s.GroupIDs) > 0:
+		return tx.Where("group_id IN ?", s.GroupIDs)
+	default:
+		return tx.Where("edge_id IN ?", s.EdgeIDs)
+	}
+}
+
+// applyByDevice restrict the query of the table with device_id and edge_id to this scope.
+func (s *DeviceScope) applyByDevice(tx *gorm.DB) *gorm.DB {
+	if s == nil {
+		return tx
+	}
+
+	switch {
+	case s.IsEmpty():
+		return tx.Where("1 = 0")
+	case len(s.GroupIDs) > 0 && len(s.EdgeIDs) > 0:
+		return tx.Where("(edge_id IN ? OR device_id IN (SELECT device_id FROM device_instance WHERE group_id IN ?))",
+			s.EdgeIDs, s.GroupIDs)
+	case len(s.GroupIDs) > 0:
+		return tx.Where("device_id IN (SELECT device_id FROM device_instance WHERE group_id IN ?)", s.GroupIDs)
+	default:
+		return tx.Where("edge_id IN ?", s.EdgeIDs)
+	}
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"time"
+
+	"github.com/edgehook/ithings/common/global"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"k8s.io/klog/v2"
+)
+
+/*
+* EdgeCredential
+* the secret of the edge to sign the frames, it's encrypted and the previous
+* secret is still accepted until it expires after rotation.
+ */
+type EdgeCredential struct {
+	EdgeID                        string `gorm:"column:edge_id; type:varchar(36); primary_key;" json:"edgeId"`
+	AuthType                      string `gorm:"column:auth_type; type:varchar(64);" json:"authType"`
+	Secret                        string `gorm:"column:secret; type:varchar(256);" json:"-"`
+	PreviousSecret                string `gorm:"column:previous_secret; type:varchar(256);" json:"-"`
+	PreviousSecretExpireTimeStamp int64  `gorm:"column:previous_secret_expire_time_stamp;" json:"-"`
+	CreateTimeStamp               int64  `gorm:"column:create_time_stamp;" json:"createTimeStamp"`
+	UpdateTimeStamp               int64  `gorm:"column:update_time_stamp;autoUpdateTime:milli" json:"updateTimeStamp"`
+}
+
+func (EdgeCredential) TableName() string {
+	return "edge_credential"
+}
+
+// GetEdgeCredentialByEdgeId is called on every frame, the missing credential isn't logged.
+func GetEdgeCredentialByEdgeId(edgeId string) (*EdgeCredential, error) {
+	cred := &EdgeCredential{}
+	result := global.DBAccess.Where("edge_id = ?", edgeId).Limit(1).Find(cred)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return cred, nil
+}
+
+func GetEdgeCredentials() ([]*EdgeCredential, error) {
+	var creds []*EdgeCredential
+	err := global.DBAccess.Find(&creds).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return creds, err
+}
+
+// UpdateEdgeCredentialEncryptedSecret replace the encrypted secrets without changing the update time.
+func UpdateEdgeCredentialEncryptedSecret(edgeId string, secret, previousSecret string) error {
+	err := global.DBAccess.Model(&EdgeCredential{}).Where("edge_id = ?", edgeId).UpdateColumns(map[string]interface{}{
+		"secret":          secret,
+		"previous_secret": previousSecret,
+	}).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+// SaveEdgeCredential create or update the credential of the edge.
+func SaveEdgeCredential(cred *EdgeCredential) error {
+	if cred.CreateTimeStamp == 0 {
+		cred.CreateTimeStamp = time.Now().UnixNano() / 1e6
+	}
+	cred.UpdateTimeStamp = time.Now().UnixNano() / 1e6
+	err := global.DBAccess.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "edge_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"auth_type", "secret", "previous_secret",
+			"previous_secret_expire_time_stamp", "update_time_stamp"}),
+	}).Create(cred).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
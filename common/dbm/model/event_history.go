@@ -0,0 +1,115 @@
+package model
+
+import (
+	"github.com/edgehook/ithings/common/global"
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+)
+
+// EventHistory hold the reported events when the influxdb is disabled.
+type EventHistory struct {
+	ID       int64  `gorm:"primary_key; auto_increment" json:"id"`
+	DeviceId string `gorm:"column:device_id; type:varchar(256); index:idx_event_history_event" json:"deviceId"`
+	Service  string `gorm:"column:service; type:varchar(256); index:idx_event_history_event" json:"service"`
+	Event    string `gorm:"column:event; type:varchar(256); index:idx_event_history_event" json:"event"`
+	Details  string `gorm:"column:details; type:text;" json:"details"`
+	ErrMsg   string `gorm:"column:err_msg; type:text;" json:"errMsg"`
+	//the timestamp of the event.
+	Ts              int64 `gorm:"column:ts; index" json:"ts"`
+	CreateTimeStamp int64 `gorm:"column:create_time_stamp; index" json:"createTimeStamp"`
+}
+
+func (EventHistory) TableName() string {
+	return "event_history"
+}
+
+// EventHistoryCondition filter the event history, the empty condition is ignored.
+type EventHistoryCondition struct {
+	DeviceId string
+	Service  string
+	Event    string
+	//the range of the ts.
+	StartTs *int64
+	EndTs   *int64
+	//the range of the create time stamp.
+	StartTime *int64
+	EndTime   *int64
+	Limit     *int64
+}
+
+func (cond *EventHistoryCondition) apply(tx *gorm.DB) *gorm.DB {
+	if cond.DeviceId != "" {
+		tx = tx.Where("device_id = ?", cond.DeviceId)
+	}
+	if cond.Service != "" {
+		tx = tx.Where("service = ?", cond.Service)
+	}
+	if cond.Event != "" {
+		tx = tx.Where("event = ?", cond.Event)
+	}
+	if cond.StartTs != nil {
+		tx = tx.Where("ts >= ?", *cond.StartTs)
+	}
+	if cond.EndTs != nil {
+		tx = tx.Where("ts <= ?", *cond.EndTs)
+	}
+	if cond.StartTime != nil {
+		tx = tx.Where("create_time_stamp >= ?", *cond.StartTime)
+	}
+	if cond.EndTime != nil {
+		tx = tx.Where("create_time_stamp <= ?", *cond.EndTime)
+	}
+	if cond.Limit != nil && *cond.Limit > 0 {
+		tx = tx.Limit(int(*cond.Limit))
+	}
+
+	return tx
+}
+
+func AddEventHistory(history *EventHistory) error {
+	err := global.DBAccess.Create(&history).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func GetEventHistoryByCondition(cond *EventHistoryCondition) ([]*EventHistory, error) {
+	var histories []*EventHistory
+	err := cond.apply(global.DBAccess).Order("create_time_stamp asc").Find(&histories).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return histories, err
+}
+
+// delete the events of the device, all services or events if they are empty.
+func DeleteEventHistory(deviceId, service, event string) error {
+	if deviceId == "" {
+		return ErrEmptyDeviceId
+	}
+
+	cond := &EventHistoryCondition{
+		DeviceId: deviceId,
+		Service:  service,
+		Event:    event,
+	}
+	err := cond.apply(global.DBAccess).Delete(&EventHistory{}).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+// delete the events created before the time stamp.
+func PruneEventHistory(before int64) (int64, error) {
+	result := global.DBAccess.Where("create_time_stamp < ?", before).Delete(&EventHistory{})
+	if result.Error != nil {
+		klog.Errorf("err: %v", result.Error)
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
@@ -26,10 +26,21 @@ func RegisterTables(db *gorm.DB) error {
 		&AlertConfig{},
 		&AlertLog{},
 		&AlertHistory{},
+		&AlertSilence{},
 		&EventRuleRelation{},
 		&DataForward{},
 		&DataForwardLog{},
-		&DeviceDataForwardRelation{})
+		&DeviceDataForwardRelation{},
+		&DataForwardOutbox{},
+		&DataForwardDeadLetter{},
+		&TwinHistory{},
+		&EventHistory{},
+		&User{},
+		&RevokedToken{},
+		&Role{},
+		&APIKey{},
+		&EdgeCredential{},
+		&DesiredTwin{})
 
 	if err != nil {
 		return err
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"github.com/edgehook/ithings/common/global"
+	"gorm.io/gorm/clause"
+	"k8s.io/klog/v2"
+)
+
+/*
+* RevokedToken
+* the revoked token by its id(jti), it's kept until the token expires.
+ */
+type RevokedToken struct {
+	TokenId string `gorm:"primary_key; column:token_id; type:varchar(64);" json:"tokenId"`
+	UserId  int64  `gorm:"column:user_id; index" json:"userId"`
+	//timestamps in ms
+	ExpireTimeStamp int64 `gorm:"column:expire_time_stamp; index" json:"expireTimeStamp"`
+	CreateTimeStamp int64 `gorm:"column:create_time_stamp;" json:"createTimeStamp"`
+}
+
+func (RevokedToken) TableName() string {
+	return "revoked_token"
+}
+
+// add the revoked token, return false if it's revoked before.
+func AddRevokedToken(token *RevokedToken) (bool, error) {
+	result := global.DBAccess.Clauses(clause.OnConflict{DoNothing: true}).Create(token)
+	if result.Error != nil {
+		klog.Errorf("err: %v", result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func IsTokenRevoked(tokenId string) (bool, error) {
+	var count int64
+	err := global.DBAccess.Model(&RevokedToken{}).Where("token_id = ?", tokenId).Count(&count).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// PruneRevokedToken delete the revoked tokens which expired before ts.
+func PruneRevokedToken(ts int64) (int64, error) {
+	result := global.DBAccess.Where("expire_time_stamp < ?", ts).Delete(&RevokedToken{})
+	if result.Error != nil {
+		klog.Errorf("err: %v", result.Error)
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"time"
+
+	"github.com/edgehook/ithings/common/global"
+	"k8s.io/klog/v2"
+)
+
+/*
+* Role
+* the permissions of the users, it's a json list of "resource:action",
+* the built-in roles can't be deleted.
+ */
+type Role struct {
+	ID              int64  `gorm:"primary_key; auto_increment" json:"id"`
+	Name            string `gorm:"column:name; not null; type:varchar(64); unique" json:"name"`
+	Description     string `gorm:"column:description; type:varchar(256);" json:"description"`
+	Permissions     string `gorm:"column:permissions; type:text;" json:"permissions"`
+	BuiltIn         bool   `gorm:"column:built_in;" json:"builtIn"`
+	CreateTimeStamp int64  `gorm:"column:create_time_stamp;" json:"createTimeStamp"`
+	UpdateTimeStamp int64  `gorm:"column:update_time_stamp;autoUpdateTime:milli" json:"updateTimeStamp"`
+}
+
+func (Role) TableName() string {
+	return "auth_role"
+}
+
+func GetRoles() ([]*Role, error) {
+	var roles []*Role
+	err := global.DBAccess.Order("id").Find(&roles).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return roles, err
+}
+
+func GetRoleById(id int64) (*Role, error) {
+	role := &Role{}
+	err := global.DBAccess.First(role, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return role, err
+}
+
+func GetRoleByName(name string) (*Role, error) {
+	role := &Role{}
+	err := global.DBAccess.Where("name = ?", name).First(role).Error
+	if err != nil {
+		return nil, err
+	}
+	return role, err
+}
+
+func AddRole(role *Role) error {
+	role.CreateTimeStamp = time.Now().UnixNano() / 1e6
+	err := global.DBAccess.Create(&role).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func SaveRole(id int64, role *Role) error {
+	err := global.DBAccess.Model(&Role{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"Description": role.Description,
+		"Permissions": role.Permissions,
+	}).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func DeleteRole(id int64) error {
+	err := global.DBAccess.Delete(&Role{}, id).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
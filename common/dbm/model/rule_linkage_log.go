@@ -169,6 +169,19 @@ func SaveRuleLinkageLogStatus(id, error string, status int32) error {
 	return nil
 }
 
+func SaveRuleLinkageLogResult(id, actionDetails, error string, status int32) error {
+	err := global.DBAccess.Model(&RuleLinkageLog{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"Status":        status,
+		"Error":         error,
+		"ActionDetails": actionDetails,
+	}).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
 func DeleteRuleLinkageLog(id string) error {
 	if err := global.DBAccess.Where("id = ?", id).Delete(&RuleLinkageLog{}).Error; err != nil {
 		klog.Errorf("err: %v", err)
@@ -83,3 +83,30 @@ func DeleteServiceModel(id int64) error {
 	//global.DBAccess.Commit()
 	return nil
 }
+
+// delete the service model and all properties, events and commands in it.
+func DeleteServiceModelAll(id int64) error {
+	tx := global.DBAccess.Begin()
+	if err := tx.Where("service_model_id = ?", id).Delete(&PropertyModel{}).Error; err != nil {
+		tx.Rollback()
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	if err := tx.Where("service_model_id = ?", id).Delete(&EventModel{}).Error; err != nil {
+		tx.Rollback()
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	if err := tx.Where("service_model_id = ?", id).Delete(&CommandModel{}).Error; err != nil {
+		tx.Rollback()
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	if err := tx.Delete(&ServiceModel{}, id).Error; err != nil {
+		tx.Rollback()
+		klog.Errorf("err: %v", err)
+		return err
+	}
+
+	return tx.Commit().Error
+}
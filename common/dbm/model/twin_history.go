@@ -0,0 +1,153 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/edgehook/ithings/common/global"
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+)
+
+// TwinHistory hold the reported twins when the influxdb is disabled.
+type TwinHistory struct {
+	ID       int64  `gorm:"primary_key; auto_increment" json:"id"`
+	DeviceId string `gorm:"column:device_id; type:varchar(256); index:idx_twin_history_property" json:"deviceId"`
+	Service  string `gorm:"column:service; type:varchar(256); index:idx_twin_history_property" json:"service"`
+	Property string `gorm:"column:property; type:varchar(256); index:idx_twin_history_property" json:"property"`
+	Value    string `gorm:"column:value; type:text;" json:"value"`
+	//the numeric value for the aggregates, nil if the value is not a number.
+	NumValue *float64 `gorm:"column:num_value;" json:"numValue"`
+	ErrMsg   string   `gorm:"column:err_msg; type:text;" json:"errMsg"`
+	//the timestamp of collecting this value.
+	Ts              int64 `gorm:"column:ts; index" json:"ts"`
+	CreateTimeStamp int64 `gorm:"column:create_time_stamp; index" json:"createTimeStamp"`
+}
+
+func (TwinHistory) TableName() string {
+	return "twin_history"
+}
+
+// TwinHistoryCondition filter the twin history, the empty condition is ignored.
+type TwinHistoryCondition struct {
+	DeviceId string
+	Service  string
+	Property string
+	//the range of the ts.
+	StartTs *int64
+	EndTs   *int64
+	//the range of the create time stamp.
+	StartTime *int64
+	EndTime   *int64
+	Limit     *int64
+}
+
+func (cond *TwinHistoryCondition) apply(tx *gorm.DB) *gorm.DB {
+	if cond.DeviceId != "" {
+		tx = tx.Where("device_id = ?", cond.DeviceId)
+	}
+	if cond.Service != "" {
+		tx = tx.Where("service = ?", cond.Service)
+	}
+	if cond.Property != "" {
+		tx = tx.Where("property = ?", cond.Property)
+	}
+	if cond.StartTs != nil {
+		tx = tx.Where("ts >= ?", *cond.StartTs)
+	}
+	if cond.EndTs != nil {
+		tx = tx.Where("ts <= ?", *cond.EndTs)
+	}
+	if cond.StartTime != nil {
+		tx = tx.Where("create_time_stamp >= ?", *cond.StartTime)
+	}
+	if cond.EndTime != nil {
+		tx = tx.Where("create_time_stamp <= ?", *cond.EndTime)
+	}
+	if cond.Limit != nil && *cond.Limit > 0 {
+		tx = tx.Limit(int(*cond.Limit))
+	}
+
+	return tx
+}
+
+func AddTwinHistories(histories []*TwinHistory) error {
+	if len(histories) == 0 {
+		return nil
+	}
+
+	err := global.DBAccess.Create(&histories).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func GetTwinHistoryByCondition(cond *TwinHistoryCondition) ([]*TwinHistory, error) {
+	var histories []*TwinHistory
+	err := cond.apply(global.DBAccess).Order("create_time_stamp asc").Find(&histories).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return histories, err
+}
+
+// get the numeric values in the ts range ordered by ts for the aggregates.
+func GetTwinHistoryNumValues(deviceId, service, property string, startTs, endTs int64) ([]*TwinHistory, error) {
+	var histories []*TwinHistory
+	err := global.DBAccess.Select("ts", "num_value").
+		Where("device_id = ? AND service = ? AND property = ?", deviceId, service, property).
+		Where("ts >= ? AND ts <= ? AND num_value IS NOT NULL", startTs, endTs).
+		Order("ts asc").Find(&histories).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return histories, err
+}
+
+// get the latest twin of each property of this device.
+func GetLatestTwinHistoryByDeviceId(deviceId string) ([]*TwinHistory, error) {
+	var histories []*TwinHistory
+	latest := global.DBAccess.Model(&TwinHistory{}).Select("MAX(id)").
+		Where("device_id = ?", deviceId).Group("service, property")
+	err := global.DBAccess.Where("id IN (?)", latest).Find(&histories).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return histories, err
+}
+
+// ErrEmptyDeviceId is returned when deleting the history without a device.
+var ErrEmptyDeviceId = errors.New("device id is required")
+
+// delete the twins of the device, all services or properties if they are empty.
+func DeleteTwinHistory(deviceId, service, property string) error {
+	if deviceId == "" {
+		return ErrEmptyDeviceId
+	}
+
+	cond := &TwinHistoryCondition{
+		DeviceId: deviceId,
+		Service:  service,
+		Property: property,
+	}
+	err := cond.apply(global.DBAccess).Delete(&TwinHistory{}).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+// delete the twins created before the time stamp.
+func PruneTwinHistory(before int64) (int64, error) {
+	result := global.DBAccess.Where("create_time_stamp < ?", before).Delete(&TwinHistory{})
+	if result.Error != nil {
+		klog.Errorf("err: %v", result.Error)
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
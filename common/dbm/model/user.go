@@ -0,0 +1,172 @@
+package model
+
+import (
+	"time"
+
+	"github.com/edgehook/ithings/common/global"
+	"k8s.io/klog/v2"
+)
+
+/*
+* User
+* the user of the web server, the password is kept as a bcrypt hash.
+* The tokens issued before TokensValidAfter are revoked.
+ */
+type User struct {
+	ID           int64  `gorm:"primary_key; auto_increment" json:"id"`
+	Username     string `gorm:"column:username; not null; type:varchar(128); unique" json:"username"`
+	PasswordHash string `gorm:"column:password_hash; not null; type:varchar(256);" json:"-"`
+	Description  string `gorm:"column:description; type:varchar(256);" json:"description"`
+	Disabled     bool   `gorm:"column:disabled;" json:"disabled"`
+	Role         string `gorm:"column:role; type:varchar(64);" json:"role"`
+	//the comma separated device groups and edges the user is restricted to,
+	//empty allows no device unless the role is admin.
+	GroupIDs string `gorm:"column:group_ids; type:varchar(1024);" json:"groupIds"`
+	EdgeIDs  string `gorm:"column:edge_ids; type:varchar(1024);" json:"edgeIds"`
+	//timestamps in ms
+	TokensValidAfter   int64 `gorm:"column:tokens_valid_after;" json:"-"`
+	LastLoginTimeStamp int64 `gorm:"column:last_login_time_stamp;" json:"lastLoginTimeStamp"`
+	CreateTimeStamp    int64 `gorm:"column:create_time_stamp;" json:"createTimeStamp"`
+	UpdateTimeStamp    int64 `gorm:"column:update_time_stamp;autoUpdateTime:milli" json:"updateTimeStamp"`
+}
+
+func (User) TableName() string {
+	return "auth_user"
+}
+
+func GetUserByPageAndKeywords(page int, limit int, keywords string) ([]*User, error) {
+	var users []*User
+	err := global.DBAccess.Where("username LIKE ?", "%"+keywords+"%").Offset((page - 1) * limit).Limit(limit).Order("id").Find(&users).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return nil, err
+	}
+	return users, err
+}
+
+func GetUserCountByKeywords(keywords string) (int64, error) {
+	var count int64
+	err := global.DBAccess.Model(&User{}).Where("username LIKE ?", "%"+keywords+"%").Count(&count).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return -1, err
+	}
+	return count, err
+}
+
+func GetUserCount() (int64, error) {
+	var count int64
+	err := global.DBAccess.Model(&User{}).Count(&count).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return -1, err
+	}
+	return count, err
+}
+
+func GetUserCountByRole(role string) (int64, error) {
+	var count int64
+	err := global.DBAccess.Model(&User{}).Where("role = ?", role).Count(&count).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return -1, err
+	}
+	return count, err
+}
+
+// SaveUserRoleIfEmpty set the role of the user who has no role.
+func SaveUserRoleIfEmpty(username, role string) error {
+	err := global.DBAccess.Model(&User{}).Where("username = ? AND (role = ? OR role IS NULL)", username, "").
+		UpdateColumn("role", role).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func GetUserById(id int64) (*User, error) {
+	user := &User{}
+	err := global.DBAccess.First(user, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return user, err
+}
+
+func GetUserByUsername(username string) (*User, error) {
+	user := &User{}
+	err := global.DBAccess.Where("username = ?", username).First(user).Error
+	if err != nil {
+		return nil, err
+	}
+	return user, err
+}
+
+func AddUser(user *User) error {
+	user.CreateTimeStamp = time.Now().UnixNano() / 1e6
+	err := global.DBAccess.Create(&user).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func SaveUser(id int64, user *User) error {
+	err := global.DBAccess.Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"Description": user.Description,
+		"Disabled":    user.Disabled,
+		"Role":        user.Role,
+		"GroupIDs":    user.GroupIDs,
+		"EdgeIDs":     user.EdgeIDs,
+	}).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+// SaveUserPassword update the password hash and revoke all tokens issued before.
+func SaveUserPassword(id int64, passwordHash string) error {
+	err := global.DBAccess.Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"PasswordHash":     passwordHash,
+		"TokensValidAfter": time.Now().UnixNano() / 1e6,
+	}).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+// RevokeUserTokens revoke all tokens of this user issued before now.
+func RevokeUserTokens(id int64) error {
+	err := global.DBAccess.Model(&User{}).Where("id = ?", id).
+		Update("TokensValidAfter", time.Now().UnixNano()/1e6).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func SaveUserLastLogin(id int64, ts int64) error {
+	err := global.DBAccess.Model(&User{}).Where("id = ?", id).
+		UpdateColumn("last_login_time_stamp", ts).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func DeleteUser(id int64) error {
+	err := global.DBAccess.Delete(&User{}, id).Error
+	if err != nil {
+		klog.Errorf("err: %v", err)
+		return err
+	}
+	return nil
+}
@@ -39,13 +39,18 @@ const (
 	DeviceStateStopped = "stopped"
 
 	//Ithings response error code
-	IRespCodeOk            = "200"
-	IRespOkString          = "sucess"
-	IRespCodeInvalidMsg    = "201"
-	IRespInvalidMsgString  = "invalid message format"
-	IRespCodeInternalError = "202"
-	IRespInternalErrString = "server internal error"
-	IRespCodeError         = "205"
+	IRespCodeOk             = "200"
+	IRespOkString           = "sucess"
+	IRespCodeInvalidMsg     = "201"
+	IRespInvalidMsgString   = "invalid message format"
+	IRespCodeInternalError  = "202"
+	IRespInternalErrString  = "server internal error"
+	IRespCodeNoSuchDevice   = "203"
+	IRespNoSuchDeviceString = "no such device"
+	IRespCodeDeviceOffline  = "204"
+	IRespCodeError          = "205"
+	IRespCodeDevNotActive   = "206"
+	IRespCodeUnauthorized   = "207"
 )
 
 var (
@@ -0,0 +1,104 @@
+package influx2_store
+
+import (
+	"github.com/edgehook/ithings/common/types/v1"
+	"k8s.io/klog/v2"
+)
+
+var (
+	eventMeasurement = "report_event"
+)
+
+func StoreEvent(eventMsg *v1.ReportEventMsg) error {
+	xClient := GetInflux2Client()
+	if xClient == nil {
+		return nil
+	}
+
+	tags := map[string]string{"service": eventMsg.ServiceName, "event": eventMsg.EventName, "deviceId": eventMsg.DeviceID}
+	fields := map[string]interface{}{"details": eventMsg.Details, "errMsg": eventMsg.ErrorMessage, "ts": eventMsg.Timestamp}
+	return xClient.WritesPoints(eventMeasurement, []*Point{{
+		Tags:   tags,
+		Fields: fields,
+	}})
+}
+
+func rowToEvent(row map[string]string) v1.ReportEventMsg {
+	return v1.ReportEventMsg{
+		ServiceName:  row["service"],
+		EventName:    row["event"],
+		DeviceID:     row["deviceId"],
+		Timestamp:    rowInt64(row, "ts"),
+		Details:      row["details"],
+		ErrorMessage: row["errMsg"],
+	}
+}
+
+// dashboard simpleJson
+func QueryTableEvent(deviceId, service, event, startTs, endTs string, count *int64) []*v1.InfluxEventData {
+	xClient := GetInflux2Client()
+	if xClient == nil {
+		return nil
+	}
+	start, stop, err := parseTimeRange(startTs, endTs)
+	if err != nil {
+		klog.Errorf("query events with err: %v", err)
+		return nil
+	}
+
+	q := newFluxQuery(xClient.Bucket, eventMeasurement).
+		Range(start, stop).
+		WhereEqual("deviceId", deviceId).
+		WhereEqual("service", service).
+		WhereEqual("event", event).
+		Pivot().
+		Pipe("group()").
+		Pipe(`sort(columns: ["_time"])`).
+		Limit(count)
+
+	var eventTables []*v1.InfluxEventData
+	for _, row := range queryRows(q) {
+		eventTables = append(eventTables, &v1.InfluxEventData{
+			ReportEventMsg: rowToEvent(row),
+			Time:           row["_time"],
+		})
+	}
+	return eventTables
+}
+
+func QueryEvent(deviceId, service, event string, startTs, endTs, count *int64) []*v1.ReportEventMsg {
+	xClient := GetInflux2Client()
+	if xClient == nil {
+		return nil
+	}
+
+	q := newFluxQuery(xClient.Bucket, eventMeasurement).
+		WhereEqual("deviceId", deviceId).
+		WhereEqual("service", service).
+		WhereEqual("event", event).
+		Pivot().
+		WhereIntRange("ts", startTs, endTs).
+		Pipe("group()").
+		Pipe(`sort(columns: ["_time"])`).
+		Limit(count)
+
+	var eventTables []*v1.ReportEventMsg
+	for _, row := range queryRows(q) {
+		eventMsg := rowToEvent(row)
+		eventTables = append(eventTables, &eventMsg)
+	}
+	return eventTables
+}
+
+func DeleteEvent(deviceId, service, event string) error {
+	xClient := GetInflux2Client()
+	if xClient == nil {
+		return ErrInflux2ClientNil
+	}
+
+	return xClient.Delete(predicate(eventMeasurement, [][2]string{
+		{"deviceId", deviceId},
+		{"service", service},
+		{"event", event},
+	}))
+}
@@ -0,0 +1,215 @@
+package influx2_store
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	//measurement escaping of the line protocol.
+	measurementEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, " ", `\ `, "\n", `\ `)
+	//tag key, tag value and field key escaping of the line protocol, the
+	//backslash is escaped first so that a trailing one can't escape the separator.
+	keyEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, " ", `\ `, "\n", `\ `)
+	//string field value escaping of the line protocol.
+	stringFieldEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	//string literal escaping of flux, ${ is the interpolation.
+	fluxStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "${", `\${`)
+	//string escaping of the delete predicate.
+	predicateEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+func formatField(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return `"` + stringFieldEscaper.Replace(v) + `"`, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.FormatInt(int64(v), 10) + "i", nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10) + "i", nil
+	case int64:
+		return strconv.FormatInt(v, 10) + "i", nil
+	case float32:
+		return formatFloat(float64(v))
+	case float64:
+		return formatFloat(v)
+	}
+
+	return "", fmt.Errorf("unsupported field type %T", value)
+}
+
+func formatFloat(v float64) (string, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "", fmt.Errorf("invalid float %v", v)
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64), nil
+}
+
+/*
+* lineProtocol
+* encode the point as the line protocol, the tags and fields are sorted
+* so that the line is stable.
+ */
+func lineProtocol(measurement string, tags map[string]string, fields map[string]interface{}, ts int64) (string, error) {
+	var sb strings.Builder
+	sb.WriteString(measurementEscaper.Replace(measurement))
+
+	tagKeys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		//the empty tag is not allowed.
+		if v != "" {
+			tagKeys = append(tagKeys, k)
+		}
+	}
+	sort.Strings(tagKeys)
+	for _, k := range tagKeys {
+		sb.WriteString("," + keyEscaper.Replace(k) + "=" + keyEscaper.Replace(tags[k]))
+	}
+
+	fieldKeys := make([]string, 0, len(fields))
+	for k := range fields {
+		fieldKeys = append(fieldKeys, k)
+	}
+	sort.Strings(fieldKeys)
+	for i, k := range fieldKeys {
+		value, err := formatField(fields[k])
+		if err != nil {
+			return "", err
+		}
+		if i == 0 {
+			sb.WriteString(" ")
+		} else {
+			sb.WriteString(",")
+		}
+		sb.WriteString(keyEscaper.Replace(k) + "=" + value)
+	}
+	if len(fieldKeys) == 0 {
+		return "", fmt.Errorf("fields are required")
+	}
+
+	sb.WriteString(" " + strconv.FormatInt(ts, 10))
+	return sb.String(), nil
+}
+
+// fluxString quote the flux string literal.
+func fluxString(s string) string {
+	return `"` + fluxStringEscaper.Replace(s) + `"`
+}
+
+// fluxTime format the time literal of flux.
+func fluxTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// fluxDuration format the duration literal of flux.
+func fluxDuration(d time.Duration) string {
+	if d%time.Second == 0 {
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+	return fmt.Sprintf("%dms", d/time.Millisecond)
+}
+
+/*
+* fluxQuery
+* build the flux over the measurement in the bucket, the user input is
+* only passed as the string literals.
+ */
+type fluxQuery struct {
+	bucket      string
+	measurement string
+	start       time.Time
+	stop        time.Time
+	filters     []string
+	pipes       []string
+}
+
+func newFluxQuery(bucket, measurement string) *fluxQuery {
+	return &fluxQuery{
+		bucket:      bucket,
+		measurement: measurement,
+		start:       time.Unix(0, 0),
+	}
+}
+
+// Range set the time range, the zero time is ignored.
+func (q *fluxQuery) Range(start, stop time.Time) *fluxQuery {
+	if !start.IsZero() {
+		q.start = start
+	}
+	q.stop = stop
+	return q
+}
+
+// WhereEqual filter the column by value if the value is not empty.
+func (q *fluxQuery) WhereEqual(column, value string) *fluxQuery {
+	if value != "" {
+		q.filters = append(q.filters, fmt.Sprintf("r[%s] == %s", fluxString(column), fluxString(value)))
+	}
+	return q
+}
+
+// Pipe append the function after the filters.
+func (q *fluxQuery) Pipe(fn string, args ...interface{}) *fluxQuery {
+	q.pipes = append(q.pipes, fmt.Sprintf(fn, args...))
+	return q
+}
+
+// Pivot turn the fields of the row into the columns.
+func (q *fluxQuery) Pivot() *fluxQuery {
+	return q.Pipe(`pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")`)
+}
+
+// WhereIntRange filter the column in [start, end] after the pivot, the nil bound is ignored.
+func (q *fluxQuery) WhereIntRange(column string, start, end *int64) *fluxQuery {
+	if start != nil {
+		q.Pipe("filter(fn: (r) => r[%s] >= %d)", fluxString(column), *start)
+	}
+	if end != nil {
+		q.Pipe("filter(fn: (r) => r[%s] <= %d)", fluxString(column), *end)
+	}
+	return q
+}
+
+// Limit limit the count of rows, the nil or non-positive limit is ignored.
+func (q *fluxQuery) Limit(limit *int64) *fluxQuery {
+	if limit != nil && *limit > 0 {
+		q.Pipe("limit(n: %d)", *limit)
+	}
+	return q
+}
+
+func (q *fluxQuery) Build() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("from(bucket: %s)\n", fluxString(q.bucket)))
+	if q.stop.IsZero() {
+		sb.WriteString(fmt.Sprintf("  |> range(start: %s)\n", fluxTime(q.start)))
+	} else {
+		sb.WriteString(fmt.Sprintf("  |> range(start: %s, stop: %s)\n", fluxTime(q.start), fluxTime(q.stop)))
+	}
+
+	filters := append([]string{fmt.Sprintf("r._measurement == %s", fluxString(q.measurement))}, q.filters...)
+	sb.WriteString(fmt.Sprintf("  |> filter(fn: (r) => %s)\n", strings.Join(filters, " and ")))
+	for _, pipe := range q.pipes {
+		sb.WriteString("  |> " + pipe + "\n")
+	}
+
+	return sb.String()
+}
+
+// predicate build the delete predicate which matches the measurement and tags.
+func predicate(measurement string, tags [][2]string) string {
+	conditions := []string{fmt.Sprintf(`_measurement="%s"`, predicateEscaper.Replace(measurement))}
+	for _, tag := range tags {
+		if tag[1] != "" {
+			conditions = append(conditions, fmt.Sprintf(`%s="%s"`, tag[0], predicateEscaper.Replace(tag[1])))
+		}
+	}
+
+	return strings.Join(conditions, " AND ")
+}
@@ -0,0 +1,106 @@
+package influx2_store
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLineProtocol(t *testing.T) {
+	tests := []struct {
+		name        string
+		measurement string
+		tags        map[string]string
+		fields      map[string]interface{}
+		want        string
+	}{
+		{"plain", "twin", map[string]string{"deviceId": "d1", "edgeId": "e1"},
+			map[string]interface{}{"value": 1.5, "count": 2},
+			`twin,deviceId=d1,edgeId=e1 count=2i,value=1.5 1000`},
+		{"empty tag", "twin", map[string]string{"deviceId": "d1", "edgeId": ""},
+			map[string]interface{}{"ok": true},
+			`twin,deviceId=d1 ok=true 1000`},
+		{"measurement", `a b,c\`, nil,
+			map[string]interface{}{"v": 1},
+			`a\ b\,c\\ v=1i 1000`},
+		{"measurement newline", "tw\nin", nil,
+			map[string]interface{}{"v": 1},
+			`tw\ in v=1i 1000`},
+		{"tag separators", "twin", map[string]string{"device id": "a,b=c d"},
+			map[string]interface{}{"v": 1},
+			`twin,device\ id=a\,b\=c\ d v=1i 1000`},
+		{"tag trailing backslash", "twin", map[string]string{"deviceId": `d1\`, "edgeId": "e1"},
+			map[string]interface{}{"v": 1},
+			`twin,deviceId=d1\\,edgeId=e1 v=1i 1000`},
+		{"tag escaped separator", "twin", map[string]string{"deviceId": `d1\,x`},
+			map[string]interface{}{"v": 1},
+			`twin,deviceId=d1\\\,x v=1i 1000`},
+		{"tag newline", "twin", map[string]string{"deviceId": "d1\nx"},
+			map[string]interface{}{"v": 1},
+			`twin,deviceId=d1\ x v=1i 1000`},
+		{"field key", "twin", nil,
+			map[string]interface{}{`a=b\`: 1},
+			`twin a\=b\\=1i 1000`},
+		{"string field", "twin", nil,
+			map[string]interface{}{"s": "say \"hi\"\n\\"},
+			`twin s="say \"hi\"\n\\" 1000`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lineProtocol(tt.measurement, tt.tags, tt.fields, 1000)
+			if err != nil {
+				t.Fatalf("lineProtocol() = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("lineProtocol() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineProtocolInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]interface{}
+	}{
+		{"no fields", nil},
+		{"NaN", map[string]interface{}{"v": math.NaN()}},
+		{"Inf", map[string]interface{}{"v": math.Inf(1)}},
+		{"unsupported type", map[string]interface{}{"v": []int{1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if line, err := lineProtocol("twin", nil, tt.fields, 1000); err == nil {
+				t.Errorf("lineProtocol() = %s, want error", line)
+			}
+		})
+	}
+}
+
+func TestFluxString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "abc", `"abc"`},
+		{"empty", "", `""`},
+		{"double quote", `a"b`, `"a\"b"`},
+		{"break out", `x") or r.deviceId != ("`, `"x\") or r.deviceId != (\""`},
+		{"backslash", `a\b`, `"a\\b"`},
+		{"escaped quote", `a\"b`, `"a\\\"b"`},
+		{"trailing backslash", `a\`, `"a\\"`},
+		{"interpolation", "${r._value}", `"\${r._value}"`},
+		{"dollar", "a$b", `"a$b"`},
+		{"single quote", "a'b", `"a'b"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fluxString(tt.in); got != tt.want {
+				t.Errorf("fluxString(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
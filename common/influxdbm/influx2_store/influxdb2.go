@@ -0,0 +1,298 @@
+package influx2_store
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultHttpTimeout = 10 * time.Second
+)
+
+var (
+	ErrInflux2ClientNil = errors.New("influx2 client is nil")
+
+	xClient *influx2Client
+)
+
+/*
+* influx2Client
+* access the influxdb 2.x by the http api, the points are written by
+* the line protocol and queried by flux.
+ */
+type influx2Client struct {
+	Address string
+	Org     string
+	Bucket  string
+	Token   string
+	//the retention of the bucket, 0 means infinite.
+	Duration time.Duration
+	client   *http.Client
+}
+
+type Point struct {
+	Tags   map[string]string
+	Fields map[string]interface{}
+}
+
+func NewInflux2Client(address, org, bucket, token string) *influx2Client {
+	return &influx2Client{
+		Address: strings.TrimRight(address, "/"),
+		Org:     org,
+		Bucket:  bucket,
+		Token:   token,
+		client: &http.Client{
+			Timeout: defaultHttpTimeout,
+		},
+	}
+}
+
+func RegisterInflux2Db(influxClient *influx2Client) *influx2Client {
+	if xClient == nil {
+		xClient = influxClient
+	}
+	return xClient
+}
+
+func GetInflux2Client() *influx2Client {
+	return xClient
+}
+
+// do send the request to the api and return the response body.
+func (influxClient *influx2Client) do(method, path string, query url.Values, contentType string, body []byte) ([]byte, error) {
+	u := influxClient.Address + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Token "+influxClient.Token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := influxClient.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}{}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Message != "" {
+			return nil, fmt.Errorf("%s %s: %s", method, path, apiErr.Message)
+		}
+		return nil, fmt.Errorf("%s %s: status %d", method, path, resp.StatusCode)
+	}
+
+	return data, nil
+}
+
+// WritesPoints write the points of the measurement by the line protocol.
+func (influxClient *influx2Client) WritesPoints(measurement string, points []*Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	ts := time.Now().UnixNano() / 1e6
+	var sb strings.Builder
+	for _, point := range points {
+		line, err := lineProtocol(measurement, point.Tags, point.Fields, ts)
+		if err != nil {
+			klog.Errorf("encode point with err: %v", err)
+			continue
+		}
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+
+	query := url.Values{}
+	query.Set("org", influxClient.Org)
+	query.Set("bucket", influxClient.Bucket)
+	query.Set("precision", "ms")
+	_, err := influxClient.do(http.MethodPost, "/api/v2/write", query, "text/plain; charset=utf-8", []byte(sb.String()))
+	if err != nil {
+		klog.Errorf("write points with err: %v", err)
+	}
+	return err
+}
+
+/*
+* Query
+* run the flux and return the rows keyed by the columns, the tables
+* with different columns are separated by their own header.
+ */
+func (influxClient *influx2Client) Query(flux string) ([]map[string]string, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"query": flux,
+		"type":  "flux",
+		"dialect": map[string]interface{}{
+			"header":      true,
+			"annotations": []string{},
+		},
+	})
+
+	klog.V(4).Infof("query flux: %s", flux)
+	query := url.Values{}
+	query.Set("org", influxClient.Org)
+	data, err := influxClient.do(http.MethodPost, "/api/v2/query", query, "application/json", body)
+	if err != nil {
+		klog.Errorf("query %s error: %v", flux, err)
+		return nil, err
+	}
+
+	return parseCSV(data)
+}
+
+func parseCSV(data []byte) ([]map[string]string, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	rows := make([]map[string]string, 0)
+	var header []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		//the header of each table begins with ",result,table".
+		if len(record) > 2 && record[1] == "result" && record[2] == "table" {
+			header = record
+			continue
+		}
+		if header == nil {
+			continue
+		}
+
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(record) && column != "" {
+				row[column] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// Delete delete the points of the measurement which match the predicate.
+func (influxClient *influx2Client) Delete(predicate string) error {
+	body, _ := json.Marshal(map[string]string{
+		"start":     time.Unix(0, 0).UTC().Format(time.RFC3339),
+		"stop":      time.Now().UTC().Format(time.RFC3339),
+		"predicate": predicate,
+	})
+
+	query := url.Values{}
+	query.Set("org", influxClient.Org)
+	query.Set("bucket", influxClient.Bucket)
+	_, err := influxClient.do(http.MethodPost, "/api/v2/delete", query, "application/json", body)
+	return err
+}
+
+/*
+* CreateBucket
+* create the bucket with the retention duration, the retention of the
+* existing bucket is updated.
+ */
+func (influxClient *influx2Client) CreateBucket(retention time.Duration) error {
+	influxClient.Duration = retention
+	rules := []map[string]interface{}{}
+	if retention > 0 {
+		rules = append(rules, map[string]interface{}{
+			"type":         "expire",
+			"everySeconds": int64(retention / time.Second),
+		})
+	}
+
+	query := url.Values{}
+	query.Set("org", influxClient.Org)
+	query.Set("name", influxClient.Bucket)
+	data, err := influxClient.do(http.MethodGet, "/api/v2/buckets", query, "", nil)
+	if err != nil {
+		return err
+	}
+	buckets := struct {
+		Buckets []struct {
+			ID string `json:"id"`
+		} `json:"buckets"`
+	}{}
+	if err := json.Unmarshal(data, &buckets); err != nil {
+		return err
+	}
+
+	if len(buckets.Buckets) > 0 {
+		body, _ := json.Marshal(map[string]interface{}{
+			"retentionRules": rules,
+		})
+		_, err = influxClient.do(http.MethodPatch, "/api/v2/buckets/"+url.PathEscape(buckets.Buckets[0].ID), nil, "application/json", body)
+		return err
+	}
+
+	orgID, err := influxClient.orgID()
+	if err != nil {
+		return err
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"orgID":          orgID,
+		"name":           influxClient.Bucket,
+		"retentionRules": rules,
+	})
+	_, err = influxClient.do(http.MethodPost, "/api/v2/buckets", nil, "application/json", body)
+	return err
+}
+
+func (influxClient *influx2Client) orgID() (string, error) {
+	query := url.Values{}
+	query.Set("org", influxClient.Org)
+	data, err := influxClient.do(http.MethodGet, "/api/v2/orgs", query, "", nil)
+	if err != nil {
+		return "", err
+	}
+
+	orgs := struct {
+		Orgs []struct {
+			ID string `json:"id"`
+		} `json:"orgs"`
+	}{}
+	if err := json.Unmarshal(data, &orgs); err != nil {
+		return "", err
+	}
+	if len(orgs.Orgs) == 0 {
+		return "", fmt.Errorf("org %s not found", influxClient.Org)
+	}
+
+	return orgs.Orgs[0].ID, nil
+}
+
+// Ping check whether the influxdb is ready.
+func (influxClient *influx2Client) Ping() error {
+	_, err := influxClient.do(http.MethodGet, "/health", nil, "", nil)
+	return err
+}
@@ -0,0 +1,273 @@
+package influx2_store
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/edgehook/ithings/common/influxdbm/influx_store"
+	"github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/common/utils"
+	"k8s.io/klog/v2"
+)
+
+var (
+	twinMeasurement = "report_twin"
+	numValueField   = "numValue"
+)
+
+func StoreTwin(deviceID string, twinProperties []*v1.TwinProperty) error {
+	xClient := GetInflux2Client()
+	if xClient == nil {
+		return nil
+	}
+
+	var points []*Point
+	for _, twinProperty := range twinProperties {
+		if twinProperty.Value == nil {
+			klog.Errorf("value is nil: %s", twinProperty.PropertyName)
+			continue
+		}
+		tags := map[string]string{"service": twinProperty.Service, "property": twinProperty.PropertyName, "deviceId": deviceID}
+		fields := map[string]interface{}{"value": utils.ToString(twinProperty.Value), "ts": twinProperty.Timestamp}
+		if twinProperty.ErrorMessage != "" {
+			fields["errMsg"] = twinProperty.ErrorMessage
+		}
+		if num, ok := utils.ToNumber(twinProperty.Value); ok {
+			fields[numValueField] = num
+		}
+		points = append(points, &Point{
+			Tags:   tags,
+			Fields: fields,
+		})
+	}
+
+	return xClient.WritesPoints(twinMeasurement, points)
+}
+
+// parseTimeRange parse the RFC3339 time range, the stop is exclusive in flux.
+func parseTimeRange(startTs, endTs string) (time.Time, time.Time, error) {
+	var start, stop time.Time
+	var err error
+	if startTs != "" {
+		if start, err = time.Parse(time.RFC3339Nano, startTs); err != nil {
+			return start, stop, fmt.Errorf("invalid time %s", startTs)
+		}
+	}
+	if endTs != "" {
+		if stop, err = time.Parse(time.RFC3339Nano, endTs); err != nil {
+			return start, stop, fmt.Errorf("invalid time %s", endTs)
+		}
+		stop = stop.Add(time.Nanosecond)
+	}
+
+	return start, stop, nil
+}
+
+func rowInt64(row map[string]string, column string) int64 {
+	n, err := strconv.ParseInt(row[column], 10, 64)
+	if err != nil {
+		f, _ := strconv.ParseFloat(row[column], 64)
+		n = int64(f)
+	}
+	return n
+}
+
+func rowToTwin(row map[string]string) v1.TwinProperty {
+	return v1.TwinProperty{
+		Service:      row["service"],
+		PropertyName: row["property"],
+		Value:        row["value"],
+		Timestamp:    rowInt64(row, "ts"),
+		ErrorMessage: row["errMsg"],
+	}
+}
+
+func queryRows(q *fluxQuery) []map[string]string {
+	xClient := GetInflux2Client()
+	if xClient == nil {
+		return nil
+	}
+
+	rows, err := xClient.Query(q.Build())
+	if err != nil {
+		return nil
+	}
+	return rows
+}
+
+// dashboard simpleJson
+func QueryTableTwin(deviceId, serviceName, propertyName, startTs, endTs string, count *int64) []*v1.InfluxTwinData {
+	xClient := GetInflux2Client()
+	if xClient == nil {
+		return nil
+	}
+	start, stop, err := parseTimeRange(startTs, endTs)
+	if err != nil {
+		klog.Errorf("query twins with err: %v", err)
+		return nil
+	}
+
+	q := newFluxQuery(xClient.Bucket, twinMeasurement).
+		Range(start, stop).
+		WhereEqual("deviceId", deviceId).
+		WhereEqual("service", serviceName).
+		WhereEqual("property", propertyName).
+		Pivot().
+		Pipe("group()").
+		Pipe(`sort(columns: ["_time"])`).
+		Limit(count)
+
+	var twinPropertys []*v1.InfluxTwinData
+	for _, row := range queryRows(q) {
+		twinPropertys = append(twinPropertys, &v1.InfluxTwinData{
+			TwinProperty: rowToTwin(row),
+			Time:         row["_time"],
+			DeviceId:     row["deviceId"],
+		})
+	}
+	return twinPropertys
+}
+
+func QueryTwin(deviceId, serviceName, propertyName string, startTs, endTs, count *int64) []*v1.TwinProperty {
+	xClient := GetInflux2Client()
+	if xClient == nil {
+		return nil
+	}
+
+	q := newFluxQuery(xClient.Bucket, twinMeasurement).
+		WhereEqual("deviceId", deviceId).
+		WhereEqual("service", serviceName).
+		WhereEqual("property", propertyName).
+		Pivot().
+		WhereIntRange("ts", startTs, endTs).
+		Pipe("group()").
+		Pipe(`sort(columns: ["_time"])`).
+		Limit(count)
+
+	var twinPropertys []*v1.TwinProperty
+	for _, row := range queryRows(q) {
+		twinProperty := rowToTwin(row)
+		twinPropertys = append(twinPropertys, &twinProperty)
+	}
+	return twinPropertys
+}
+
+/*
+* QueryTwinSeries
+* aggregate the numeric values over the time windows, the empty windows
+* are kept with nil value.
+ */
+func QueryTwinSeries(deviceId, serviceName, propertyName, agg string, interval time.Duration, startTs, endTs int64) ([]*v1.TwinSeriesPoint, error) {
+	xClient := GetInflux2Client()
+	if xClient == nil {
+		return nil, ErrInflux2ClientNil
+	}
+	if !influx_store.IsSeriesAggregate(agg) {
+		return nil, fmt.Errorf("unsupported aggregate %s", agg)
+	}
+	step := interval.Milliseconds()
+	if step <= 0 || endTs < startTs {
+		return nil, fmt.Errorf("invalid time range")
+	}
+	if (endTs-startTs)/step > influx_store.MaxSeriesBuckets {
+		return nil, fmt.Errorf("too many time buckets, the max is %d", influx_store.MaxSeriesBuckets)
+	}
+
+	//align the start to the window so that the first window is not truncated.
+	start := time.Unix(0, (startTs-startTs%step)*int64(time.Millisecond))
+	stop := time.Unix(0, (endTs+1)*int64(time.Millisecond))
+	q := newFluxQuery(xClient.Bucket, twinMeasurement).
+		Range(start, stop).
+		WhereEqual("deviceId", deviceId).
+		WhereEqual("service", serviceName).
+		WhereEqual("property", propertyName).
+		WhereEqual("_field", numValueField).
+		Pipe(`aggregateWindow(every: %s, fn: %s, createEmpty: true, timeSrc: "_start")`, fluxDuration(interval), agg).
+		Pipe("group()").
+		Pipe(`sort(columns: ["_time"])`)
+
+	rows, err := xClient.Query(q.Build())
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]*v1.TwinSeriesPoint, 0)
+	for _, row := range rows {
+		t, err := time.Parse(time.RFC3339Nano, row["_time"])
+		if err != nil {
+			klog.Errorf("parse time of %s with err: %v", row["_time"], err)
+			continue
+		}
+
+		point := &v1.TwinSeriesPoint{
+			Timestamp: t.UnixNano() / 1e6,
+		}
+		if value, err := strconv.ParseFloat(row["_value"], 64); err == nil {
+			point.Value = &value
+		}
+		points = append(points, point)
+	}
+
+	return points, nil
+}
+
+/*
+* QueryLatestTwins
+* query the latest twin of each property of this device, the last
+* fields of the same property are merged by the latest time.
+ */
+func QueryLatestTwins(deviceId string) []*v1.TwinProperty {
+	xClient := GetInflux2Client()
+	if xClient == nil {
+		return nil
+	}
+
+	q := newFluxQuery(xClient.Bucket, twinMeasurement).
+		WhereEqual("deviceId", deviceId).
+		Pipe("last()").
+		Pivot().
+		Pipe("group()")
+
+	type latest struct {
+		time time.Time
+		twin v1.TwinProperty
+	}
+	latests := make(map[string]*latest)
+	keys := make([]string, 0)
+	for _, row := range queryRows(q) {
+		if _, exist := row["value"]; !exist {
+			continue
+		}
+		t, _ := time.Parse(time.RFC3339Nano, row["_time"])
+
+		key := row["service"] + "/" + row["property"]
+		if l, exist := latests[key]; exist {
+			if t.After(l.time) {
+				l.time, l.twin = t, rowToTwin(row)
+			}
+			continue
+		}
+		latests[key] = &latest{time: t, twin: rowToTwin(row)}
+		keys = append(keys, key)
+	}
+
+	twinPropertys := make([]*v1.TwinProperty, 0, len(keys))
+	for _, key := range keys {
+		twinPropertys = append(twinPropertys, &latests[key].twin)
+	}
+	return twinPropertys
+}
+
+func DeleteTwin(deviceId, serviceName, propertyName string) error {
+	xClient := GetInflux2Client()
+	if xClient == nil {
+		return ErrInflux2ClientNil
+	}
+
+	return xClient.Delete(predicate(twinMeasurement, [][2]string{
+		{"deviceId", deviceId},
+		{"service", serviceName},
+		{"property", propertyName},
+	}))
+}
@@ -0,0 +1,216 @@
+package influx_store
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/edgehook/ithings/common/types/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	//the numeric value of the twin, it's the field of the aggregates.
+	numValueField = "numValue"
+	//the max count of the time buckets in one series query.
+	MaxSeriesBuckets = 10000
+)
+
+var (
+	seriesAggregates = []string{AggMean, AggMin, AggMax, AggSum, AggCount, AggFirst, AggLast}
+)
+
+/*
+* DownsampleTier
+* the twins are downsampled into the retention policy of this tier by
+* the continuous query, every aggregate is stored as a field.
+ */
+type DownsampleTier struct {
+	Policy   string
+	Interval time.Duration
+	//the retention of this tier, 0 means infinite.
+	Duration time.Duration
+}
+
+// IsSeriesAggregate check whether the aggregate is supported by the series query.
+func IsSeriesAggregate(agg string) bool {
+	for _, a := range seriesAggregates {
+		if a == agg {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+* CreateDownsample
+* create the retention policy and the continuous query of the tier
+* beside the twin policy. The continuous query is recreated so that the
+* changed interval takes effect.
+ */
+func (influxClient *influxDbClient) CreateDownsample(interval, duration string) error {
+	every, err := ParseDuration(interval)
+	if err != nil || every <= 0 {
+		return fmt.Errorf("invalid downsample interval %s", interval)
+	}
+	retention, err := ParseDuration(duration)
+	if err != nil {
+		return fmt.Errorf("invalid downsample duration %s", duration)
+	}
+
+	tier := &DownsampleTier{
+		Policy:   fmt.Sprintf("%s_%s", influxClient.RetentionPolicy, formatDuration(every)),
+		Interval: every,
+		Duration: retention,
+	}
+	policy, dbName := QuoteIdent(tier.Policy), QuoteIdent(influxClient.DbName)
+
+	createPolicySql := fmt.Sprintf("create retention policy %s on %s duration %s replication 1", policy, dbName, duration)
+	if _, err := influxClient.QueryDB(createPolicySql); err != nil {
+		alterPolicySql := fmt.Sprintf("alter retention policy %s on %s duration %s replication 1", policy, dbName, duration)
+		if _, err := influxClient.QueryDB(alterPolicySql); err != nil {
+			klog.Errorf("alter downsample policy failed: %v", err)
+			return err
+		}
+	}
+
+	qb := Select()
+	for _, agg := range seriesAggregates {
+		qb.Aggregate(agg, numValueField, agg)
+	}
+	sql, err := qb.Into(influxClient.DbName, tier.Policy, twinMeasurement).
+		FromPolicy(influxClient.RetentionPolicy, twinMeasurement).
+		GroupByTime(every).
+		GroupByAllTags().
+		Build()
+	if err != nil {
+		return err
+	}
+
+	cq := QuoteIdent("cq_" + tier.Policy)
+	influxClient.QueryDB(fmt.Sprintf("drop continuous query %s on %s", cq, dbName))
+	createCQSql := fmt.Sprintf("create continuous query %s on %s begin %s end", cq, dbName, sql)
+	if _, err := influxClient.QueryDB(createCQSql); err != nil {
+		klog.Errorf("create continuous query failed: %v", err)
+		return err
+	}
+
+	influxClient.Tiers = append(influxClient.Tiers, tier)
+	return nil
+}
+
+/*
+* seriesSource
+* choose the raw twins if they cover the start time, otherwise the
+* coarsest tier whose interval divides the requested interval and whose
+* retention covers the start time.
+ */
+func (influxClient *influxDbClient) seriesSource(interval time.Duration, start time.Time) *DownsampleTier {
+	now := time.Now()
+	covers := func(retention time.Duration) bool {
+		return retention == 0 || !start.Before(now.Add(-retention))
+	}
+	if covers(influxClient.Duration) {
+		return nil
+	}
+
+	var source *DownsampleTier
+	for _, tier := range influxClient.Tiers {
+		if interval%tier.Interval != 0 || !covers(tier.Duration) {
+			continue
+		}
+		if source == nil || tier.Interval > source.Interval {
+			source = tier
+		}
+	}
+
+	return source
+}
+
+/*
+* IsSeriesFill
+* check whether the fill is null, none, previous, linear or a number,
+* it's written into the query so the whole string must be a number.
+ */
+func IsSeriesFill(fill string) bool {
+	switch fill {
+	case "null", "none", "previous", "linear":
+		return true
+	}
+
+	v, err := strconv.ParseFloat(fill, 64)
+	return err == nil && !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+/*
+* QueryTwinSeries
+* aggregate the numeric values of the property over the time buckets in
+* [startTs, endTs] ms. The downsampled tiers aggregate the stored
+* aggregates, so the mean of them is the mean of the bucket means.
+ */
+func QueryTwinSeries(deviceId, serviceName, propertyName, agg string, interval time.Duration, startTs, endTs int64, fill string) ([]*v1.TwinSeriesPoint, error) {
+	if !IsSeriesAggregate(agg) {
+		return nil, fmt.Errorf("unsupported aggregate %s", agg)
+	}
+	//the buckets are counted in ms.
+	if interval < time.Millisecond {
+		return nil, fmt.Errorf("invalid interval %v, the min is 1ms", interval)
+	}
+	if endTs < startTs {
+		return nil, fmt.Errorf("invalid time range")
+	}
+	if (endTs-startTs)/interval.Milliseconds() > MaxSeriesBuckets {
+		return nil, fmt.Errorf("too many time buckets, the max is %d", MaxSeriesBuckets)
+	}
+
+	xClient := GetInfluxClient()
+	if xClient == nil {
+		return nil, ErrInfluxClientNil
+	}
+
+	qb := Select()
+	if tier := xClient.seriesSource(interval, time.Unix(0, startTs*int64(time.Millisecond))); tier != nil {
+		fn := agg
+		//the count of the buckets are summed up.
+		if agg == AggCount {
+			fn = AggSum
+		}
+		qb.Aggregate(fn, agg, "value").FromPolicy(tier.Policy, twinMeasurement)
+	} else {
+		qb.Aggregate(agg, numValueField, "value").From(twinMeasurement)
+	}
+	qb.WhereEqual("deviceId", deviceId).
+		WhereEqual("service", serviceName).
+		WhereEqual("property", propertyName).
+		WhereTimeBetween(&startTs, &endTs).
+		GroupByTime(interval)
+	if fill != "" {
+		qb.Fill(fill)
+	}
+
+	sql, err := qb.Build()
+	if err != nil {
+		return nil, err
+	}
+	responces, err := xClient.QueryDB(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]*v1.TwinSeriesPoint, 0)
+	for _, row := range seriesRows(responces) {
+		t, err := time.Parse(time.RFC3339Nano, rowString(row, "time"))
+		if err != nil {
+			klog.Errorf("parse time of %v with err: %v", row["time"], err)
+			continue
+		}
+		points = append(points, &v1.TwinSeriesPoint{
+			Timestamp: t.UnixNano() / 1e6,
+			Value:     rowFloat(row, "value"),
+		})
+	}
+
+	return points, nil
+}
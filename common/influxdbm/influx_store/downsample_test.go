@@ -0,0 +1,31 @@
+package influx_store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryTwinSeriesInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		agg      string
+		interval time.Duration
+		start    int64
+		end      int64
+	}{
+		{"unsupported aggregate", "stddev", time.Minute, 0, 1000},
+		{"zero interval", AggMean, 0, 0, 1000},
+		{"negative interval", AggMean, -time.Second, 0, 1000},
+		{"interval under 1ms", AggMean, time.Microsecond, 0, 1000},
+		{"reversed range", AggMean, time.Minute, 1000, 0},
+		{"too many buckets", AggMean, time.Millisecond, 0, MaxSeriesBuckets + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := QueryTwinSeries("d1", "s1", "p1", tt.agg, tt.interval, tt.start, tt.end, ""); err == nil || err == ErrInfluxClientNil {
+				t.Errorf("QueryTwinSeries() = %v, want the invalid argument", err)
+			}
+		})
+	}
+}
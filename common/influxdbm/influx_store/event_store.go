@@ -1,15 +1,15 @@
 package influx_store
 
 import (
-	"encoding/json"
-	"fmt"
+	"errors"
+
 	"github.com/edgehook/ithings/common/types/v1"
 	"k8s.io/klog/v2"
-	"strings"
 )
 
 var (
 	eventMeasurement = "report_event"
+	eventColumns     = []string{"time", "deviceId", "service", "event", "details", "ts", "errMsg"}
 )
 
 func StoreEvent(eventMsg *v1.ReportEventMsg) error {
@@ -33,199 +33,90 @@ func StoreEvent(eventMsg *v1.ReportEventMsg) error {
 	return nil
 }
 
-// dashboard simpleJson
-func QueryTableEvent(deviceId, service, event, startTs, endTs string, count *int64) []*v1.InfluxEventData {
-
-	var eventTables []*v1.InfluxEventData
-	sql := fmt.Sprintf("%s %s", "select time, deviceId, service, event, details, ts, errMsg from", eventMeasurement)
-	if deviceId != "" || service != "" || event != "" || startTs != "" || endTs != "" {
-		sql = fmt.Sprintf("%s where", sql)
-		var sqlList []string
-		if deviceId != "" {
-			sqlList = append(sqlList, fmt.Sprintf("deviceId = '%s'", deviceId))
-		}
-		if service != "" {
-			sqlList = append(sqlList, fmt.Sprintf("service = '%s'", service))
-		}
-
-		if event != "" {
-			sqlList = append(sqlList, fmt.Sprintf("event = '%s'", service))
-		}
-		//utc
-		if startTs != "" {
-			sqlList = append(sqlList, fmt.Sprintf("time >= '%v'", startTs))
-		}
-		//utc
-		if endTs != "" {
-			sqlList = append(sqlList, fmt.Sprintf("time <= '%v'", endTs))
-		}
-
-		whereSql := strings.Join(sqlList, " AND ")
-		sql = fmt.Sprintf("%s %s", sql, whereSql)
-
-	}
-	if count != nil {
-		sql = fmt.Sprintf("%s limit %d", sql, *count)
-	}
-
-	klog.Infof("query sql: %s", sql)
+// queryEventRows query the events and return the rows keyed by the columns.
+func queryEventRows(qb *QueryBuilder) []map[string]interface{} {
 	xClient := GetInfluxClient()
 	if xClient == nil {
 		return nil
 	}
+
+	sql, err := qb.Build()
+	if err != nil {
+		klog.Errorf("build event query with err: %v", err)
+		return nil
+	}
 	responces, err := xClient.QueryDB(sql)
 	if err != nil {
 		klog.Errorf("Query db error: %v", err)
 		return nil
 	}
-	klog.Infof("reponce: %v", responces)
-	if len(responces) > 0 && len(responces[0].Series) > 0 {
-		for _, row := range responces[0].Series[0].Values {
-			for j, value := range row {
-				klog.Infof("j:%d value:%v", j, value)
-
-			}
-			time, ok := row[0].(string)
-			if !ok {
-				klog.Errorf("convert time to string error: %v", row[0])
-			}
-			deviceId, ok := row[1].(string)
-			if !ok {
-				klog.Errorf("convert deviceId to string error: %v", row[1])
-			}
-			service, ok := row[2].(string)
-			if !ok {
-				klog.Errorf("convert service to string error: %v", row[2])
-			}
-			propertyName, ok := row[3].(string)
-			if !ok {
-				klog.Errorf("convert eventName to string error: %v", row[2])
-			}
-			details, ok := row[4].(string)
-			if !ok {
-				klog.Errorf("convert details to string error: %v", row[2])
-			}
-			ts, ok := row[5].(json.Number)
-			if !ok {
-				klog.Errorf("convert ts to json.Number error: %v", row[5])
-			}
-			temp, err := ts.Int64()
-			if err != nil {
-				klog.Errorf("convert ts to int64 error: %v", row[5])
-			}
-
-			errorMsg, ok := row[6].(string)
-			if !ok {
-				klog.Errorf("convert errorMsg to string error: %v", row[6])
-			}
-			eventMsg := v1.ReportEventMsg{
-				ServiceName:  service,
-				EventName:    propertyName,
-				DeviceID:     deviceId,
-				Timestamp:    temp,
-				Details:      details,
-				ErrorMessage: errorMsg,
-			}
-			influxEvent := &v1.InfluxEventData{
-				ReportEventMsg: eventMsg,
-				Time:           time,
-			}
-			eventTables = append(eventTables, influxEvent)
+
+	return seriesRows(responces)
+}
+
+func rowToEvent(row map[string]interface{}) v1.ReportEventMsg {
+	return v1.ReportEventMsg{
+		ServiceName:  rowString(row, "service"),
+		EventName:    rowString(row, "event"),
+		DeviceID:     rowString(row, "deviceId"),
+		Timestamp:    rowInt64(row, "ts"),
+		Details:      rowString(row, "details"),
+		ErrorMessage: rowString(row, "errMsg"),
+	}
+}
+
+// dashboard simpleJson
+func QueryTableEvent(deviceId, service, event, startTs, endTs string, count *int64) []*v1.InfluxEventData {
+	//utc
+	qb := Select(eventColumns...).From(eventMeasurement).
+		WhereEqual("deviceId", deviceId).
+		WhereEqual("service", service).
+		WhereEqual("event", event).
+		WhereTimeRange(startTs, endTs).
+		LimitPtr(count)
+
+	var eventTables []*v1.InfluxEventData
+	for _, row := range queryEventRows(qb) {
+		influxEvent := &v1.InfluxEventData{
+			ReportEventMsg: rowToEvent(row),
+			Time:           rowString(row, "time"),
 		}
+		eventTables = append(eventTables, influxEvent)
 	}
 	return eventTables
 }
 
 func QueryEvent(deviceId, service, event string, startTs, endTs, count *int64) []*v1.ReportEventMsg {
+	qb := Select(eventColumns...).From(eventMeasurement).
+		WhereEqual("deviceId", deviceId).
+		WhereEqual("service", service).
+		WhereEqual("event", event).
+		WhereIntRange("ts", startTs, endTs).
+		LimitPtr(count)
 
 	var eventTables []*v1.ReportEventMsg
-	sql := fmt.Sprintf("%s %s", "select deviceId, service, event, details, ts, errMsg from", eventMeasurement)
-	if deviceId != "" || service != "" || event != "" || startTs != nil || endTs != nil {
-		sql = fmt.Sprintf("%s where", sql)
-		var sqlList []string
-		if deviceId != "" {
-			sqlList = append(sqlList, fmt.Sprintf("deviceId = '%s'", deviceId))
-		}
-		if service != "" {
-			sqlList = append(sqlList, fmt.Sprintf("service = '%s'", service))
-		}
-
-		if event != "" {
-			sqlList = append(sqlList, fmt.Sprintf("event = '%s'", service))
-		}
-		//utc
-		if startTs != nil {
-			sqlList = append(sqlList, fmt.Sprintf("ts >= %v", *startTs))
-		}
-		//utc
-		if endTs != nil {
-			sqlList = append(sqlList, fmt.Sprintf("ts <= %v", *endTs))
-		}
-
-		whereSql := strings.Join(sqlList, " AND ")
-		sql = fmt.Sprintf("%s %s", sql, whereSql)
-
-	}
-	if count != nil {
-		sql = fmt.Sprintf("%s limit %d", sql, *count)
+	for _, row := range queryEventRows(qb) {
+		eventMsg := rowToEvent(row)
+		eventTables = append(eventTables, &eventMsg)
 	}
+	return eventTables
+}
 
-	klog.Infof("query sql: %s", sql)
+func DeleteEvent(deviceId, service, event string) error {
 	xClient := GetInfluxClient()
 	if xClient == nil {
-		return nil
+		return errors.New("Influx client is nul")
 	}
-	responces, err := xClient.QueryDB(sql)
+
+	sql, err := Delete().From(eventMeasurement).
+		WhereEqual("deviceId", deviceId).
+		WhereEqual("service", service).
+		WhereEqual("event", event).
+		Build()
 	if err != nil {
-		klog.Errorf("Query db error: %v", err)
-		return nil
-	}
-	klog.Infof("reponce: %v", responces)
-	if len(responces) > 0 && len(responces[0].Series) > 0 {
-		for _, row := range responces[0].Series[0].Values {
-			for j, value := range row {
-				klog.Infof("j:%d value:%v", j, value)
-
-			}
-			deviceId, ok := row[1].(string)
-			if !ok {
-				klog.Errorf("convert deviceId to string error: %v", row[1])
-			}
-			service, ok := row[2].(string)
-			if !ok {
-				klog.Errorf("convert service to string error: %v", row[2])
-			}
-			propertyName, ok := row[3].(string)
-			if !ok {
-				klog.Errorf("convert eventName to string error: %v", row[2])
-			}
-			details, ok := row[4].(string)
-			if !ok {
-				klog.Errorf("convert details to string error: %v", row[2])
-			}
-			ts, ok := row[5].(json.Number)
-			if !ok {
-				klog.Errorf("convert ts to json.Number error: %v", row[5])
-			}
-			temp, err := ts.Int64()
-			if err != nil {
-				klog.Errorf("convert ts to int64 error: %v", row[5])
-			}
-
-			errorMsg, ok := row[6].(string)
-			if !ok {
-				klog.Errorf("convert errorMsg to string error: %v", row[6])
-			}
-			eventMsg := &v1.ReportEventMsg{
-				ServiceName:  service,
-				EventName:    propertyName,
-				DeviceID:     deviceId,
-				Timestamp:    temp,
-				Details:      details,
-				ErrorMessage: errorMsg,
-			}
-			eventTables = append(eventTables, eventMsg)
-		}
+		return err
 	}
-	return eventTables
+
+	_, err = xClient.QueryDB(sql)
+	return err
 }
@@ -1,6 +1,7 @@
 package influx_store
 
 import (
+	"errors"
 	"fmt"
 	"github.com/influxdata/influxdb1-client/v2"
 	"k8s.io/klog/v2"
@@ -9,6 +10,8 @@ import (
 )
 
 var (
+	ErrInfluxClientNil = errors.New("influx client is nil")
+
 	xClient *influxDbClient
 	once    = &sync.Once{}
 )
@@ -17,6 +20,10 @@ type influxDbClient struct {
 	Client          client.Client
 	DbName          string
 	RetentionPolicy string
+	//the retention of the twin policy, 0 means infinite.
+	Duration time.Duration
+	//the downsampling tiers of the twins.
+	Tiers []*DownsampleTier
 }
 
 type Point struct {
@@ -65,12 +72,17 @@ func (influxClient influxDbClient) QueryDB(cmd string) (res []client.Result, err
 	return res, nil
 }
 
-func (influxClient influxDbClient) CreateRetentionPolicy(duration string) error {
+func (influxClient *influxDbClient) CreateRetentionPolicy(duration string) error {
 	//1h（1小时）、1d（1天）、1w（1周）
-	createPolicySql := fmt.Sprintf("create retention policy %s on  %s duration %s replication 1 SHARD DURATION %s DEFAULT", influxClient.RetentionPolicy, influxClient.DbName, duration, duration)
+	if !durationLiteral.MatchString(duration) {
+		return fmt.Errorf("invalid retention duration %s", duration)
+	}
+	influxClient.Duration, _ = ParseDuration(duration)
+	policy, dbName := QuoteIdent(influxClient.RetentionPolicy), QuoteIdent(influxClient.DbName)
+	createPolicySql := fmt.Sprintf("create retention policy %s on %s duration %s replication 1 SHARD DURATION %s DEFAULT", policy, dbName, duration, duration)
 	if _, err := influxClient.QueryDB(createPolicySql); err != nil {
 		klog.Errorf("create retention policy failed: %v", err)
-		alterPolicySql := fmt.Sprintf("alter retention policy %s on %s duration %s replication 1 SHARD DURATION %s DEFAULT", influxClient.RetentionPolicy, influxClient.DbName, duration, duration)
+		alterPolicySql := fmt.Sprintf("alter retention policy %s on %s duration %s replication 1 SHARD DURATION %s DEFAULT", policy, dbName, duration, duration)
 		if _, err := influxClient.QueryDB(alterPolicySql); err != nil {
 			klog.Errorf("alter retention policy failed: %v", err)
 		}
@@ -0,0 +1,438 @@
+package influx_store
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/v2"
+)
+
+const (
+	OrderAsc  = "ASC"
+	OrderDesc = "DESC"
+
+	AggMean  = "mean"
+	AggMin   = "min"
+	AggMax   = "max"
+	AggSum   = "sum"
+	AggCount = "count"
+	AggFirst = "first"
+	AggLast  = "last"
+)
+
+var (
+	//the InfluxQL duration literal, such as 1h, 7d, 1w or INF.
+	durationLiteral = regexp.MustCompile(`^(INF|inf|(\d+(ns|u|µ|ms|s|m|h|d|w))+)$`)
+	durationPart    = regexp.MustCompile(`(\d+)(ns|u|µ|ms|s|m|h|d|w)`)
+
+	comparisonOps = map[string]bool{
+		"=": true, "!=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true,
+	}
+	//the raw newline isn't allowed in the quoted identifiers and strings.
+	identEscaper  = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	stringEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`)
+
+	aggregateFuncs = map[string]bool{
+		AggMean: true, AggMin: true, AggMax: true, AggSum: true, AggCount: true, AggFirst: true, AggLast: true,
+	}
+)
+
+// QuoteIdent quote the identifier, such as measurement, tag key and field key.
+func QuoteIdent(name string) string {
+	//time is the keyword of the timestamp column.
+	if name == "time" {
+		return name
+	}
+
+	return `"` + identEscaper.Replace(name) + `"`
+}
+
+// QuoteString quote the string literal.
+func QuoteString(s string) string {
+	return `'` + stringEscaper.Replace(s) + `'`
+}
+
+// ParseDuration parse the InfluxQL duration literal such as 30d, 0 is returned for INF.
+func ParseDuration(literal string) (time.Duration, error) {
+	if !durationLiteral.MatchString(literal) {
+		return 0, fmt.Errorf("invalid duration %s", literal)
+	}
+	if strings.ToUpper(literal) == "INF" {
+		return 0, nil
+	}
+
+	units := map[string]time.Duration{
+		"ns": time.Nanosecond,
+		"u":  time.Microsecond,
+		"µ":  time.Microsecond,
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"m":  time.Minute,
+		"h":  time.Hour,
+		"d":  24 * time.Hour,
+		"w":  7 * 24 * time.Hour,
+	}
+	var d time.Duration
+	for _, part := range durationPart.FindAllStringSubmatch(literal, -1) {
+		n, err := strconv.ParseInt(part[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(n) * units[part[2]]
+	}
+
+	return d, nil
+}
+
+// formatDuration format the duration as the InfluxQL duration literal.
+func formatDuration(d time.Duration) string {
+	units := []struct {
+		unit string
+		d    time.Duration
+	}{
+		{"w", 7 * 24 * time.Hour},
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+		{"ms", time.Millisecond},
+		{"u", time.Microsecond},
+	}
+	for _, u := range units {
+		if d%u.d == 0 {
+			return fmt.Sprintf("%d%s", d/u.d, u.unit)
+		}
+	}
+
+	return fmt.Sprintf("%dns", d.Nanoseconds())
+}
+
+/*
+* QueryBuilder
+* build the InfluxQL with the escaped identifiers and literals, the
+* user input must only be passed as the values.
+ */
+type QueryBuilder struct {
+	isDelete    bool
+	fields      []string
+	measurement string
+	into        string
+	conditions  []string
+	groupBy     []string
+	fill        string
+	order       string
+	limit       int64
+	err         error
+}
+
+// Select start a select query with the fields.
+func Select(fields ...string) *QueryBuilder {
+	qb := &QueryBuilder{}
+	for _, field := range fields {
+		qb.fields = append(qb.fields, QuoteIdent(field))
+	}
+
+	return qb
+}
+
+// Delete start a delete query.
+func Delete() *QueryBuilder {
+	return &QueryBuilder{
+		isDelete: true,
+	}
+}
+
+func (qb *QueryBuilder) setError(err error) *QueryBuilder {
+	if qb.err == nil {
+		qb.err = err
+	}
+
+	return qb
+}
+
+// Aggregate select the aggregate of the field, such as mean("value") AS "alias".
+func (qb *QueryBuilder) Aggregate(fn, field, alias string) *QueryBuilder {
+	fn = strings.ToLower(fn)
+	if !aggregateFuncs[fn] {
+		return qb.setError(fmt.Errorf("unsupported aggregate %s", fn))
+	}
+
+	expr := fmt.Sprintf("%s(%s)", fn, QuoteIdent(field))
+	if alias != "" {
+		expr = fmt.Sprintf("%s AS %s", expr, QuoteIdent(alias))
+	}
+	qb.fields = append(qb.fields, expr)
+
+	return qb
+}
+
+func (qb *QueryBuilder) From(measurement string) *QueryBuilder {
+	qb.measurement = QuoteIdent(measurement)
+	return qb
+}
+
+// FromPolicy select from the measurement in the retention policy.
+func (qb *QueryBuilder) FromPolicy(policy, measurement string) *QueryBuilder {
+	qb.measurement = QuoteIdent(policy) + "." + QuoteIdent(measurement)
+	return qb
+}
+
+// Into write the results into the measurement, it's used by the continuous queries.
+func (qb *QueryBuilder) Into(dbName, policy, measurement string) *QueryBuilder {
+	qb.into = QuoteIdent(dbName) + "." + QuoteIdent(policy) + "." + QuoteIdent(measurement)
+	return qb
+}
+
+// Where compare the tag/field with the string value.
+func (qb *QueryBuilder) Where(key, op, value string) *QueryBuilder {
+	if !comparisonOps[op] {
+		return qb.setError(fmt.Errorf("unsupported operator %s", op))
+	}
+
+	qb.conditions = append(qb.conditions, fmt.Sprintf("%s %s %s", QuoteIdent(key), op, QuoteString(value)))
+	return qb
+}
+
+// WhereEqual filter the tag by value if the value is not empty.
+func (qb *QueryBuilder) WhereEqual(key, value string) *QueryBuilder {
+	if value == "" {
+		return qb
+	}
+
+	return qb.Where(key, "=", value)
+}
+
+// WhereInt compare the field with the integer value.
+func (qb *QueryBuilder) WhereInt(key, op string, value int64) *QueryBuilder {
+	if !comparisonOps[op] {
+		return qb.setError(fmt.Errorf("unsupported operator %s", op))
+	}
+
+	qb.conditions = append(qb.conditions, fmt.Sprintf("%s %s %d", QuoteIdent(key), op, value))
+	return qb
+}
+
+// WhereIntRange filter the field in [start, end], the nil bound is ignored.
+func (qb *QueryBuilder) WhereIntRange(key string, start, end *int64) *QueryBuilder {
+	if start != nil {
+		qb.WhereInt(key, ">=", *start)
+	}
+	if end != nil {
+		qb.WhereInt(key, "<=", *end)
+	}
+
+	return qb
+}
+
+// WhereTimeRange filter the time by the RFC3339 string in [start, end], the empty bound is ignored.
+func (qb *QueryBuilder) WhereTimeRange(start, end string) *QueryBuilder {
+	for _, bound := range []struct{ op, value string }{{">=", start}, {"<=", end}} {
+		if bound.value == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339Nano, bound.value); err != nil {
+			return qb.setError(fmt.Errorf("invalid time %s", bound.value))
+		}
+		qb.conditions = append(qb.conditions, fmt.Sprintf("time %s %s", bound.op, QuoteString(bound.value)))
+	}
+
+	return qb
+}
+
+// WhereTimeBetween filter the time by the timestamps in ms, the nil bound is ignored.
+func (qb *QueryBuilder) WhereTimeBetween(start, end *int64) *QueryBuilder {
+	if start != nil {
+		qb.conditions = append(qb.conditions, fmt.Sprintf("time >= %dms", *start))
+	}
+	if end != nil {
+		qb.conditions = append(qb.conditions, fmt.Sprintf("time <= %dms", *end))
+	}
+
+	return qb
+}
+
+// GroupBy group by the tags.
+func (qb *QueryBuilder) GroupBy(tags ...string) *QueryBuilder {
+	for _, tag := range tags {
+		qb.groupBy = append(qb.groupBy, QuoteIdent(tag))
+	}
+
+	return qb
+}
+
+// GroupByAllTags group by all the tags so that they are kept in the results.
+func (qb *QueryBuilder) GroupByAllTags() *QueryBuilder {
+	qb.groupBy = append(qb.groupBy, "*")
+	return qb
+}
+
+// GroupByTime group by the time buckets.
+func (qb *QueryBuilder) GroupByTime(interval time.Duration) *QueryBuilder {
+	if interval <= 0 {
+		return qb.setError(fmt.Errorf("invalid interval %v", interval))
+	}
+
+	qb.groupBy = append(qb.groupBy, fmt.Sprintf("time(%s)", formatDuration(interval)))
+	return qb
+}
+
+// Fill fill the empty time buckets: null, none, previous, linear or a number.
+func (qb *QueryBuilder) Fill(fill string) *QueryBuilder {
+	if !IsSeriesFill(fill) {
+		return qb.setError(fmt.Errorf("invalid fill %q", fill))
+	}
+	//the number is written in the canonical form, e.g. 1e3 is 1000.
+	if v, err := strconv.ParseFloat(fill, 64); err == nil {
+		fill = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+
+	qb.fill = fill
+	return qb
+}
+
+// OrderByTime order by the time, InfluxQL only supports ordering by time.
+func (qb *QueryBuilder) OrderByTime(order string) *QueryBuilder {
+	order = strings.ToUpper(order)
+	if order != OrderAsc && order != OrderDesc {
+		return qb.setError(fmt.Errorf("invalid order %s", order))
+	}
+
+	qb.order = order
+	return qb
+}
+
+// Limit limit the count of points, the non-positive limit is ignored.
+func (qb *QueryBuilder) Limit(limit int64) *QueryBuilder {
+	qb.limit = limit
+	return qb
+}
+
+// LimitPtr limit the count if it's not nil.
+func (qb *QueryBuilder) LimitPtr(limit *int64) *QueryBuilder {
+	if limit != nil {
+		qb.limit = *limit
+	}
+
+	return qb
+}
+
+// Build build the InfluxQL.
+func (qb *QueryBuilder) Build() (string, error) {
+	if qb.err != nil {
+		return "", qb.err
+	}
+	if qb.measurement == "" {
+		return "", fmt.Errorf("measurement is required")
+	}
+
+	var sb strings.Builder
+	if qb.isDelete {
+		sb.WriteString("DELETE FROM ")
+	} else {
+		if len(qb.fields) == 0 {
+			return "", fmt.Errorf("fields are required")
+		}
+		sb.WriteString("SELECT ")
+		sb.WriteString(strings.Join(qb.fields, ", "))
+		if qb.into != "" {
+			sb.WriteString(" INTO " + qb.into)
+		}
+		sb.WriteString(" FROM ")
+	}
+	sb.WriteString(qb.measurement)
+
+	if len(qb.conditions) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(qb.conditions, " AND "))
+	}
+	if qb.isDelete {
+		return sb.String(), nil
+	}
+
+	if len(qb.groupBy) > 0 {
+		sb.WriteString(" GROUP BY ")
+		sb.WriteString(strings.Join(qb.groupBy, ", "))
+	}
+	if qb.fill != "" {
+		sb.WriteString(" fill(" + qb.fill + ")")
+	}
+	if qb.order != "" {
+		sb.WriteString(" ORDER BY time " + qb.order)
+	}
+	if qb.limit > 0 {
+		sb.WriteString(fmt.Sprintf(" LIMIT %d", qb.limit))
+	}
+
+	return sb.String(), nil
+}
+
+// seriesRows convert the rows of all series to the maps keyed by the columns.
+func seriesRows(results []client.Result) []map[string]interface{} {
+	rows := make([]map[string]interface{}, 0)
+	for _, result := range results {
+		for _, series := range result.Series {
+			for _, values := range series.Values {
+				row := make(map[string]interface{}, len(series.Columns)+len(series.Tags))
+				for tag, value := range series.Tags {
+					row[tag] = value
+				}
+				for i, column := range series.Columns {
+					if i < len(values) {
+						row[column] = values[i]
+					}
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+
+	return rows
+}
+
+func rowString(row map[string]interface{}, column string) string {
+	s, _ := row[column].(string)
+	return s
+}
+
+func rowInt64(row map[string]interface{}, column string) int64 {
+	switch v := row[column].(type) {
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			f, _ := v.Float64()
+			n = int64(f)
+		}
+		return n
+	case float64:
+		return int64(v)
+	case int64:
+		return v
+	}
+
+	return 0
+}
+
+// rowFloat return nil if the column is null, such as the empty time bucket.
+func rowFloat(row map[string]interface{}, column string) *float64 {
+	var f float64
+	switch v := row[column].(type) {
+	case json.Number:
+		n, err := v.Float64()
+		if err != nil {
+			return nil
+		}
+		f = n
+	case float64:
+		f = v
+	case int64:
+		f = float64(v)
+	default:
+		return nil
+	}
+
+	return &f
+}
@@ -0,0 +1,167 @@
+package influx_store
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuoteIdent(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "deviceId", `"deviceId"`},
+		{"time keyword", "time", `time`},
+		{"double quote", `a"b`, `"a\"b"`},
+		{"break out", `x" OR 1=1; DROP DATABASE "ithings`, `"x\" OR 1=1; DROP DATABASE \"ithings"`},
+		{"backslash", `a\b`, `"a\\b"`},
+		{"escaped quote", `a\"b`, `"a\\\"b"`},
+		{"trailing backslash", `a\`, `"a\\"`},
+		{"newline", "a\nb", `"a\nb"`},
+		{"semicolon", "a;b", `"a;b"`},
+		{"single quote", "a'b", `"a'b"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QuoteIdent(tt.in); got != tt.want {
+				t.Errorf("QuoteIdent(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "abc", `'abc'`},
+		{"empty", "", `''`},
+		{"single quote", "a'b", `'a\'b'`},
+		{"break out", "x' OR deviceId =~ /.*/ --", `'x\' OR deviceId =~ /.*/ --'`},
+		{"backslash", `a\b`, `'a\\b'`},
+		{"escaped quote", `a\'b`, `'a\\\'b'`},
+		{"trailing backslash", `a\`, `'a\\'`},
+		{"newline", "a\nb", `'a\nb'`},
+		{"semicolon", "a'; DROP DATABASE \"ithings\"", `'a\'; DROP DATABASE "ithings"'`},
+		{"double quote", `a"b`, `'a"b'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QuoteString(tt.in); got != tt.want {
+				t.Errorf("QuoteString(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWhere(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		op      string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"equal", "deviceId", "=", "d1", `SELECT "value" FROM "twin" WHERE "deviceId" = 'd1'`, false},
+		{"quote in value", "deviceId", "=", "d1' OR '1'='1", `SELECT "value" FROM "twin" WHERE "deviceId" = 'd1\' OR \'1\'=\'1'`, false},
+		{"backslash in value", "deviceId", "=", `d1\' OR 1=1`, `SELECT "value" FROM "twin" WHERE "deviceId" = 'd1\\\' OR 1=1'`, false},
+		{"newline in value", "deviceId", "!=", "d1\nDROP DATABASE x", `SELECT "value" FROM "twin" WHERE "deviceId" != 'd1\nDROP DATABASE x'`, false},
+		{"semicolon in value", "deviceId", "=", "d1; DROP DATABASE x", `SELECT "value" FROM "twin" WHERE "deviceId" = 'd1; DROP DATABASE x'`, false},
+		{"quote in key", `device" = 'x' OR "a`, "=", "d1", `SELECT "value" FROM "twin" WHERE "device\" = 'x' OR \"a" = 'd1'`, false},
+		{"hostile operator", "deviceId", "= 'a' OR 1=1 --", "d1", "", true},
+		{"regex operator", "deviceId", "=~", "d1", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Select("value").From("twin").Where(tt.key, tt.op, tt.value).Build()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Build() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Build() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWhereTimeRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		start   string
+		end     string
+		want    string
+		wantErr bool
+	}{
+		{"both", "2023-01-01T00:00:00Z", "2023-01-02T00:00:00.5Z",
+			`SELECT "value" FROM "twin" WHERE time >= '2023-01-01T00:00:00Z' AND time <= '2023-01-02T00:00:00.5Z'`, false},
+		{"start only", "2023-01-01T00:00:00+08:00", "",
+			`SELECT "value" FROM "twin" WHERE time >= '2023-01-01T00:00:00+08:00'`, false},
+		{"none", "", "", `SELECT "value" FROM "twin"`, false},
+		{"quote", "2023-01-01T00:00:00Z' OR 1=1 --", "", "", true},
+		{"trailing text", "2023-01-01T00:00:00Z; DROP DATABASE x", "", "", true},
+		{"newline", "", "2023-01-01T00:00:00Z\n", "", true},
+		{"backslash", `2023-01-01T00:00:00Z\`, "", "", true},
+		{"not a time", "now() - 1h", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Select("value").From("twin").WhereTimeRange(tt.start, tt.end).Build()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Build() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Build() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFill(t *testing.T) {
+	tests := []struct {
+		name    string
+		fill    string
+		want    string
+		wantErr bool
+	}{
+		{"none", "none", "fill(none)", false},
+		{"null", "null", "fill(null)", false},
+		{"previous", "previous", "fill(previous)", false},
+		{"linear", "linear", "fill(linear)", false},
+		{"integer", "0", "fill(0)", false},
+		{"negative", "-1.5", "fill(-1.5)", false},
+		{"exponent", "1e3", "fill(1000)", false},
+		{"trailing text", `0) ; DROP DATABASE "ithings" --`, "", true},
+		{"trailing space", "1 ", "", true},
+		{"quote", "0'", "", true},
+		{"backslash", `0\`, "", true},
+		{"newline", "0\n", "", true},
+		{"semicolon", "0;", "", true},
+		{"nan", "NaN", "", true},
+		{"inf", "+Inf", "", true},
+		{"keyword case", "NONE", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Select("value").From("twin").GroupByTime(time.Minute).Fill(tt.fill).Build()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Build() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !strings.HasSuffix(got, "GROUP BY time(1m) "+tt.want) {
+				t.Errorf("Build() = %s, want suffix %s", got, tt.want)
+			}
+			if tt.wantErr && IsSeriesFill(tt.fill) {
+				t.Errorf("IsSeriesFill(%q) = true", tt.fill)
+			}
+		})
+	}
+}
@@ -1,17 +1,15 @@
 package influx_store
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/edgehook/ithings/common/types/v1"
 	"github.com/edgehook/ithings/common/utils"
 	"k8s.io/klog/v2"
-	"strings"
 )
 
 var (
 	twinMeasurement = "report_twin"
+	twinColumns     = []string{"time", "deviceId", "service", "property", "value", "ts", "errMsg"}
 )
 
 func StoreTwin(deviceID string, twinProperties []*v1.TwinProperty) error {
@@ -29,6 +27,9 @@ func StoreTwin(deviceID string, twinProperties []*v1.TwinProperty) error {
 		val := utils.ToString(twinProperty.Value)
 		tags := map[string]string{"service": twinProperty.Service, "property": twinProperty.PropertyName, "deviceId": deviceID}
 		fields := map[string]interface{}{"value": val, "ts": twinProperty.Timestamp}
+		if num, ok := utils.ToNumber(twinProperty.Value); ok {
+			fields[numValueField] = num
+		}
 		point := &Point{
 			Tags:   tags,
 			Fields: fields,
@@ -42,156 +43,86 @@ func StoreTwin(deviceID string, twinProperties []*v1.TwinProperty) error {
 	return nil
 }
 
-// dashboard simpleJson
-func QueryTableTwin(deviceId, serviceName, propertyName, startTs, endTs string, count *int64) []*v1.InfluxTwinData {
+// queryTwinRows query the twins and return the rows keyed by the columns.
+func queryTwinRows(qb *QueryBuilder) []map[string]interface{} {
 	xClient := GetInfluxClient()
 	if xClient == nil {
 		return nil
 	}
-	var twinPropertys []*v1.InfluxTwinData
-	sql := fmt.Sprintf("%s %s", "select deviceId, service, property, value, ts, errMsg from", twinMeasurement)
-	if deviceId != "" || serviceName != "" || propertyName != "" || startTs != "" || endTs != "" {
-		sql = fmt.Sprintf("%s where", sql)
-		var sqlList []string
-		if deviceId != "" {
-			sqlList = append(sqlList, fmt.Sprintf("deviceId = '%s'", deviceId))
-		}
-		if serviceName != "" {
-			sqlList = append(sqlList, fmt.Sprintf("service = '%s'", serviceName))
-		}
 
-		if propertyName != "" {
-			sqlList = append(sqlList, fmt.Sprintf("property = '%s'", propertyName))
-		}
-		//utc
-		if startTs != "" {
-			sqlList = append(sqlList, fmt.Sprintf("time >= '%v'", startTs))
-		}
-		//utc
-		if endTs != "" {
-			sqlList = append(sqlList, fmt.Sprintf("time <= '%v'", endTs))
-		}
+	sql, err := qb.Build()
+	if err != nil {
+		klog.Errorf("build twin query with err: %v", err)
+		return nil
+	}
+	responces, err := xClient.QueryDB(sql)
+	if err != nil {
+		return nil
+	}
 
-		whereSql := strings.Join(sqlList, " AND ")
-		sql = fmt.Sprintf("%s %s", sql, whereSql)
+	return seriesRows(responces)
+}
 
+func rowToTwin(row map[string]interface{}) v1.TwinProperty {
+	return v1.TwinProperty{
+		Service:      rowString(row, "service"),
+		PropertyName: rowString(row, "property"),
+		Value:        row["value"],
+		Timestamp:    rowInt64(row, "ts"),
+		ErrorMessage: rowString(row, "errMsg"),
 	}
-	if count != nil {
-		sql = fmt.Sprintf("%s limit %d", sql, *count)
-	}
+}
 
-	klog.Infof("query sql: %s", sql)
-	responces, _ := xClient.QueryDB(sql)
-	//klog.Infof("reponce: %v", responces)
-	if len(responces) > 0 && len(responces[0].Series) > 0 {
-		for _, row := range responces[0].Series[0].Values {
-			time, ok := row[0].(string)
-			if !ok {
-				klog.Errorf("convert time to string error: %v", row[0])
-			}
-			deviceId, ok := row[1].(string)
-			if !ok {
-				klog.Errorf("convert deviceId to string error: %v", row[1])
-			}
-			service, ok := row[2].(string)
-			if !ok {
-				klog.Errorf("convert service to string error: %v", row[2])
-			}
-			propertyName, ok := row[3].(string)
-			if !ok {
-				klog.Errorf("convert propertyName to string error: %v", row[2])
-			}
-			ts, ok := row[5].(json.Number)
-			if !ok {
-				klog.Errorf("convert ts to json.Number error: %v", row[5])
-			}
-			temp, err := ts.Int64()
-			if err != nil {
-				klog.Errorf("convert ts to int64 error: %v", row[5])
-			}
-			twinProperty := v1.TwinProperty{
-				Service:      service,
-				PropertyName: propertyName,
-				Value:        row[4],
-				Timestamp:    temp,
-			}
-			twinData := &v1.InfluxTwinData{
-				DeviceId:     deviceId,
-				TwinProperty: twinProperty,
-				Time:         time,
-			}
-			twinPropertys = append(twinPropertys, twinData)
+// dashboard simpleJson
+func QueryTableTwin(deviceId, serviceName, propertyName, startTs, endTs string, count *int64) []*v1.InfluxTwinData {
+	//utc
+	qb := Select(twinColumns...).From(twinMeasurement).
+		WhereEqual("deviceId", deviceId).
+		WhereEqual("service", serviceName).
+		WhereEqual("property", propertyName).
+		WhereTimeRange(startTs, endTs).
+		LimitPtr(count)
+
+	var twinPropertys []*v1.InfluxTwinData
+	for _, row := range queryTwinRows(qb) {
+		twinData := &v1.InfluxTwinData{
+			DeviceId:     rowString(row, "deviceId"),
+			TwinProperty: rowToTwin(row),
+			Time:         rowString(row, "time"),
 		}
+		twinPropertys = append(twinPropertys, twinData)
 	}
 	return twinPropertys
 }
 
 func QueryTwin(deviceId, serviceName, propertyName string, startTs, endTs, count *int64) []*v1.TwinProperty {
-	xClient := GetInfluxClient()
-	if xClient == nil {
-		return nil
-	}
-	var twinPropertys []*v1.TwinProperty
-	sql := fmt.Sprintf("%s %s", "select deviceId, service, property, value, ts, errMsg from", twinMeasurement)
-	if deviceId != "" || serviceName != "" || propertyName != "" || startTs != nil || endTs != nil {
-		sql = fmt.Sprintf("%s where", sql)
-		var sqlList []string
-		if deviceId != "" {
-			sqlList = append(sqlList, fmt.Sprintf("deviceId = '%s'", deviceId))
-		}
-		if serviceName != "" {
-			sqlList = append(sqlList, fmt.Sprintf("service = '%s'", serviceName))
-		}
-
-		if propertyName != "" {
-			sqlList = append(sqlList, fmt.Sprintf("property = '%s'", propertyName))
-		}
-		//utc
-		if startTs != nil {
-			sqlList = append(sqlList, fmt.Sprintf("ts >= %v", *startTs))
-		}
-		//utc
-		if endTs != nil {
-			sqlList = append(sqlList, fmt.Sprintf("ts <= %v", *endTs))
-		}
+	qb := Select(twinColumns...).From(twinMeasurement).
+		WhereEqual("deviceId", deviceId).
+		WhereEqual("service", serviceName).
+		WhereEqual("property", propertyName).
+		WhereIntRange("ts", startTs, endTs).
+		LimitPtr(count)
 
-		whereSql := strings.Join(sqlList, " AND ")
-		sql = fmt.Sprintf("%s %s", sql, whereSql)
-
-	}
-	if count != nil {
-		sql = fmt.Sprintf("%s limit %d", sql, *count)
+	var twinPropertys []*v1.TwinProperty
+	for _, row := range queryTwinRows(qb) {
+		twinProperty := rowToTwin(row)
+		twinPropertys = append(twinPropertys, &twinProperty)
 	}
+	return twinPropertys
+}
 
-	klog.Infof("query sql: %s", sql)
-	responces, _ := xClient.QueryDB(sql)
-	//klog.Infof("reponce: %v", responces)
-	if len(responces) > 0 && len(responces[0].Series) > 0 {
-		for _, row := range responces[0].Series[0].Values {
-			service, ok := row[2].(string)
-			if !ok {
-				klog.Errorf("convert service to string error: %v", row[2])
-			}
-			propertyName, ok := row[3].(string)
-			if !ok {
-				klog.Errorf("convert propertyName to string error: %v", row[2])
-			}
-			ts, ok := row[5].(json.Number)
-			if !ok {
-				klog.Errorf("convert ts to json.Number error: %v", row[5])
-			}
-			temp, err := ts.Int64()
-			if err != nil {
-				klog.Errorf("convert ts to int64 error: %v", row[5])
-			}
-			twinProperty := &v1.TwinProperty{
-				Service:      service,
-				PropertyName: propertyName,
-				Value:        row[4],
-				Timestamp:    temp,
-			}
-			twinPropertys = append(twinPropertys, twinProperty)
-		}
+// QueryLatestTwins query the latest twin of each property of this device.
+func QueryLatestTwins(deviceId string) []*v1.TwinProperty {
+	qb := Select("time", "value", "ts", "errMsg").From(twinMeasurement).
+		WhereEqual("deviceId", deviceId).
+		GroupBy("service", "property").
+		OrderByTime(OrderDesc).
+		Limit(1)
+
+	var twinPropertys []*v1.TwinProperty
+	for _, row := range queryTwinRows(qb) {
+		twinProperty := rowToTwin(row)
+		twinPropertys = append(twinPropertys, &twinProperty)
 	}
 	return twinPropertys
 }
@@ -201,27 +132,16 @@ func DeleteTwin(deviceId, serviceName, propertyName string) error {
 	if xClient == nil {
 		return errors.New("Influx client is nul")
 	}
-	sql := fmt.Sprintf("delete from %s ", twinMeasurement)
-	if deviceId != "" || serviceName != "" || propertyName != "" {
-		sql = fmt.Sprintf("%s where", sql)
-		var sqlList []string
-		if deviceId != "" {
-			sqlList = append(sqlList, fmt.Sprintf("deviceId = '%s'", deviceId))
-		}
-		if serviceName != "" {
-			sqlList = append(sqlList, fmt.Sprintf("service = '%s'", serviceName))
-		}
-
-		if propertyName != "" {
-			sqlList = append(sqlList, fmt.Sprintf("property = '%s'", propertyName))
-		}
-
-		whereSql := strings.Join(sqlList, " AND ")
-		sql = fmt.Sprintf("%s %s", sql, whereSql)
 
+	sql, err := Delete().From(twinMeasurement).
+		WhereEqual("deviceId", deviceId).
+		WhereEqual("service", serviceName).
+		WhereEqual("property", propertyName).
+		Build()
+	if err != nil {
+		return err
 	}
 
-	klog.Infof("query sql: %s", sql)
-	_, err := xClient.QueryDB(sql)
+	_, err = xClient.QueryDB(sql)
 	return err
 }
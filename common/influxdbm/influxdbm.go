@@ -3,6 +3,7 @@ package influxdbm
 import (
 	"fmt"
 	"github.com/edgehook/ithings/common/config"
+	"github.com/edgehook/ithings/common/influxdbm/influx2_store"
 	"github.com/edgehook/ithings/common/influxdbm/influx_store"
 	_ "github.com/influxdata/influxdb1-client"
 	"github.com/influxdata/influxdb1-client/v2"
@@ -40,13 +41,15 @@ func InitInfluxDb() error {
 		klog.Infof("InfluxDb is disable")
 		return nil
 	}
-	flag := 0
-retry:
+	if config.IsVersion2() {
+		return initInflux2Db(config)
+	}
 	xClient := connInfluxDB(config)
-	if xClient == nil && flag < 3 {
-		flag++
+	for retry := 0; xClient == nil && retry < 3; retry++ {
 		time.Sleep(3 * time.Second)
-		goto retry
+		xClient = connInfluxDB(config)
+	}
+	if xClient == nil {
 		klog.Errorf("Connect influxDb server fail")
 		return fmt.Errorf("Connect influxDb server fail")
 	}
@@ -57,5 +60,48 @@ retry:
 	}
 	influxClient := influx_store.RegisterInfluxDb(config.DbName, policy, xClient)
 	influxClient.CreateRetentionPolicy(config.Duration)
+	for _, tier := range config.Downsample {
+		if err := influxClient.CreateDownsample(tier.Interval, tier.Duration); err != nil {
+			klog.Errorf("create downsample %s:%s with err: %v", tier.Interval, tier.Duration, err)
+		}
+	}
+	return nil
+}
+
+/*
+* initInflux2Db
+* register the influxdb 2.x client and create the bucket, the retention
+* duration is mapped to the bucket retention.
+ */
+func initInflux2Db(conf *config.InfluxDBConfig) error {
+	retention := time.Duration(0)
+	if conf.Duration != "" {
+		d, err := influx_store.ParseDuration(conf.Duration)
+		if err != nil {
+			klog.Errorf("invalid influx duration %s", conf.Duration)
+			return err
+		}
+		retention = d
+	}
+	if len(conf.Downsample) > 0 {
+		klog.Warningf("the downsampling tiers are only supported by influxdb 1.x")
+	}
+
+	xClient := influx2_store.NewInflux2Client(conf.Address, conf.Org, conf.Bucket, conf.Token)
+	err := xClient.Ping()
+	for retry := 0; err != nil && retry < 3; retry++ {
+		time.Sleep(3 * time.Second)
+		err = xClient.Ping()
+	}
+	if err != nil {
+		klog.Errorf("Connect influxDb server fail: %v", err)
+		return err
+	}
+
+	if err := xClient.CreateBucket(retention); err != nil {
+		klog.Errorf("create bucket %s failed: %v", conf.Bucket, err)
+		return err
+	}
+	influx2_store.RegisterInflux2Db(xClient)
 	return nil
 }
@@ -0,0 +1,315 @@
+package tsdbm
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/edgehook/ithings/common/config"
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/influxdbm/influx_store"
+	"github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/common/utils"
+	"k8s.io/klog/v2"
+)
+
+/*
+* gormStore
+* keep the time series in the main database, the history older than
+* the retention is pruned periodically.
+ */
+type gormStore struct {
+	config    *config.HistoryConfig
+	pruneOnce sync.Once
+}
+
+func NewGormStore(config *config.HistoryConfig) TimeSeriesStore {
+	return &gormStore{
+		config: config,
+	}
+}
+
+// toMs parse the RFC3339 time to ms, nil if it's empty.
+func toMs(t string) (*int64, error) {
+	if t == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339Nano, t)
+	if err != nil {
+		return nil, err
+	}
+	ms := parsed.UnixNano() / 1e6
+
+	return &ms, nil
+}
+
+// formatMs format the ms as the RFC3339 time like influxdb.
+func formatMs(ms int64) string {
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC().Format(time.RFC3339Nano)
+}
+
+func (s *gormStore) StoreTwin(deviceID string, twins []*v1.TwinProperty) error {
+	now := utils.GetNowTimeStamp()
+	histories := make([]*db.TwinHistory, 0, len(twins))
+	for _, twin := range twins {
+		if twin == nil || twin.Value == nil {
+			continue
+		}
+
+		history := &db.TwinHistory{
+			DeviceId:        deviceID,
+			Service:         twin.Service,
+			Property:        twin.PropertyName,
+			Value:           utils.ToString(twin.Value),
+			ErrMsg:          twin.ErrorMessage,
+			Ts:              twin.Timestamp,
+			CreateTimeStamp: now,
+		}
+		if num, ok := utils.ToNumber(twin.Value); ok {
+			history.NumValue = &num
+		}
+		histories = append(histories, history)
+	}
+
+	return db.AddTwinHistories(histories)
+}
+
+func (s *gormStore) StoreEvent(event *v1.ReportEventMsg) error {
+	return db.AddEventHistory(&db.EventHistory{
+		DeviceId:        event.DeviceID,
+		Service:         event.ServiceName,
+		Event:           event.EventName,
+		Details:         event.Details,
+		ErrMsg:          event.ErrorMessage,
+		Ts:              event.Timestamp,
+		CreateTimeStamp: utils.GetNowTimeStamp(),
+	})
+}
+
+func toTwin(history *db.TwinHistory) v1.TwinProperty {
+	return v1.TwinProperty{
+		Service:      history.Service,
+		PropertyName: history.Property,
+		Value:        history.Value,
+		Timestamp:    history.Ts,
+		ErrorMessage: history.ErrMsg,
+	}
+}
+
+func toEvent(history *db.EventHistory) v1.ReportEventMsg {
+	return v1.ReportEventMsg{
+		DeviceID:     history.DeviceId,
+		ServiceName:  history.Service,
+		EventName:    history.Event,
+		Details:      history.Details,
+		ErrorMessage: history.ErrMsg,
+		Timestamp:    history.Ts,
+	}
+}
+
+func (s *gormStore) QueryTableTwin(deviceId, serviceName, propertyName, startTime, endTime string, count *int64) []*v1.InfluxTwinData {
+	start, err1 := toMs(startTime)
+	end, err2 := toMs(endTime)
+	if err1 != nil || err2 != nil {
+		klog.Errorf("invalid time range %s ~ %s", startTime, endTime)
+		return nil
+	}
+
+	histories, err := db.GetTwinHistoryByCondition(&db.TwinHistoryCondition{
+		DeviceId:  deviceId,
+		Service:   serviceName,
+		Property:  propertyName,
+		StartTime: start,
+		EndTime:   end,
+		Limit:     count,
+	})
+	if err != nil {
+		return nil
+	}
+
+	var twins []*v1.InfluxTwinData
+	for _, history := range histories {
+		twins = append(twins, &v1.InfluxTwinData{
+			TwinProperty: toTwin(history),
+			Time:         formatMs(history.CreateTimeStamp),
+			DeviceId:     history.DeviceId,
+		})
+	}
+	return twins
+}
+
+func (s *gormStore) QueryTableEvent(deviceId, serviceName, eventName, startTime, endTime string, count *int64) []*v1.InfluxEventData {
+	start, err1 := toMs(startTime)
+	end, err2 := toMs(endTime)
+	if err1 != nil || err2 != nil {
+		klog.Errorf("invalid time range %s ~ %s", startTime, endTime)
+		return nil
+	}
+
+	histories, err := db.GetEventHistoryByCondition(&db.EventHistoryCondition{
+		DeviceId:  deviceId,
+		Service:   serviceName,
+		Event:     eventName,
+		StartTime: start,
+		EndTime:   end,
+		Limit:     count,
+	})
+	if err != nil {
+		return nil
+	}
+
+	var events []*v1.InfluxEventData
+	for _, history := range histories {
+		events = append(events, &v1.InfluxEventData{
+			ReportEventMsg: toEvent(history),
+			Time:           formatMs(history.CreateTimeStamp),
+		})
+	}
+	return events
+}
+
+func (s *gormStore) QueryTwin(deviceId, serviceName, propertyName string, startTs, endTs, count *int64) []*v1.TwinProperty {
+	histories, err := db.GetTwinHistoryByCondition(&db.TwinHistoryCondition{
+		DeviceId: deviceId,
+		Service:  serviceName,
+		Property: propertyName,
+		StartTs:  startTs,
+		EndTs:    endTs,
+		Limit:    count,
+	})
+	if err != nil {
+		return nil
+	}
+
+	var twins []*v1.TwinProperty
+	for _, history := range histories {
+		twin := toTwin(history)
+		twins = append(twins, &twin)
+	}
+	return twins
+}
+
+func (s *gormStore) QueryEvent(deviceId, serviceName, eventName string, startTs, endTs, count *int64) []*v1.ReportEventMsg {
+	histories, err := db.GetEventHistoryByCondition(&db.EventHistoryCondition{
+		DeviceId: deviceId,
+		Service:  serviceName,
+		Event:    eventName,
+		StartTs:  startTs,
+		EndTs:    endTs,
+		Limit:    count,
+	})
+	if err != nil {
+		return nil
+	}
+
+	var events []*v1.ReportEventMsg
+	for _, history := range histories {
+		event := toEvent(history)
+		events = append(events, &event)
+	}
+	return events
+}
+
+/*
+* QueryTwinSeries
+* aggregate the numeric values over the time buckets in memory, since
+* the first/last aggregates and the time buckets are not portable
+* between the databases.
+ */
+func (s *gormStore) QueryTwinSeries(deviceId, serviceName, propertyName, agg string, interval time.Duration, startTs, endTs int64, fill string) ([]*v1.TwinSeriesPoint, error) {
+	if !influx_store.IsSeriesAggregate(agg) {
+		return nil, fmt.Errorf("unsupported aggregate %s", agg)
+	}
+	step := interval.Milliseconds()
+	if step <= 0 || endTs < startTs {
+		return nil, fmt.Errorf("invalid time range")
+	}
+	if (endTs-startTs)/step > influx_store.MaxSeriesBuckets {
+		return nil, fmt.Errorf("too many time buckets, the max is %d", influx_store.MaxSeriesBuckets)
+	}
+
+	histories, err := db.GetTwinHistoryNumValues(deviceId, serviceName, propertyName, startTs, endTs)
+	if err != nil {
+		return nil, err
+	}
+
+	//the buckets are aligned to the epoch like influxdb.
+	first := startTs - startTs%step
+	buckets := make(map[int64][]float64)
+	for _, history := range histories {
+		if history.NumValue == nil {
+			continue
+		}
+		bucket := history.Ts - history.Ts%step
+		buckets[bucket] = append(buckets[bucket], *history.NumValue)
+	}
+
+	points := make([]*v1.TwinSeriesPoint, 0)
+	for bucket := first; bucket <= endTs; bucket += step {
+		point := &v1.TwinSeriesPoint{
+			Timestamp: bucket,
+		}
+		if values := buckets[bucket]; len(values) > 0 {
+			value := aggregate(agg, values)
+			point.Value = &value
+		}
+		points = append(points, point)
+	}
+
+	return fillPoints(points, fill)
+}
+
+func (s *gormStore) QueryLatestTwins(deviceId string) []*v1.TwinProperty {
+	histories, err := db.GetLatestTwinHistoryByDeviceId(deviceId)
+	if err != nil {
+		return nil
+	}
+
+	twins := make([]*v1.TwinProperty, 0, len(histories))
+	for _, history := range histories {
+		twin := toTwin(history)
+		twins = append(twins, &twin)
+	}
+	return twins
+}
+
+func (s *gormStore) DeleteTwin(deviceId, serviceName, propertyName string) error {
+	return db.DeleteTwinHistory(deviceId, serviceName, propertyName)
+}
+
+func (s *gormStore) DeleteEvent(deviceId, serviceName, eventName string) error {
+	return db.DeleteEventHistory(deviceId, serviceName, eventName)
+}
+
+// StartPrune prune the history older than the retention periodically.
+func (s *gormStore) StartPrune() {
+	s.pruneOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(s.config.PruneInterval)
+			defer ticker.Stop()
+
+			s.prune()
+			for range ticker.C {
+				s.prune()
+			}
+		}()
+	})
+}
+
+func (s *gormStore) prune() {
+	before := utils.GetNowTimeStamp() - s.config.Retention.Milliseconds()
+
+	twins, err := db.PruneTwinHistory(before)
+	if err != nil {
+		return
+	}
+	events, err := db.PruneEventHistory(before)
+	if err != nil {
+		return
+	}
+
+	if twins > 0 || events > 0 {
+		klog.Infof("pruned %d twins and %d events of the history", twins, events)
+	}
+}
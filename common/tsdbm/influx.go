@@ -0,0 +1,55 @@
+package tsdbm
+
+import (
+	"time"
+
+	"github.com/edgehook/ithings/common/influxdbm/influx_store"
+	"github.com/edgehook/ithings/common/types/v1"
+)
+
+// influxStore keep the time series in the influxdb.
+type influxStore struct{}
+
+func NewInfluxStore() TimeSeriesStore {
+	return &influxStore{}
+}
+
+func (s *influxStore) StoreTwin(deviceID string, twins []*v1.TwinProperty) error {
+	return influx_store.StoreTwin(deviceID, twins)
+}
+
+func (s *influxStore) StoreEvent(event *v1.ReportEventMsg) error {
+	return influx_store.StoreEvent(event)
+}
+
+func (s *influxStore) QueryTableTwin(deviceId, serviceName, propertyName, startTime, endTime string, count *int64) []*v1.InfluxTwinData {
+	return influx_store.QueryTableTwin(deviceId, serviceName, propertyName, startTime, endTime, count)
+}
+
+func (s *influxStore) QueryTableEvent(deviceId, serviceName, eventName, startTime, endTime string, count *int64) []*v1.InfluxEventData {
+	return influx_store.QueryTableEvent(deviceId, serviceName, eventName, startTime, endTime, count)
+}
+
+func (s *influxStore) QueryTwin(deviceId, serviceName, propertyName string, startTs, endTs, count *int64) []*v1.TwinProperty {
+	return influx_store.QueryTwin(deviceId, serviceName, propertyName, startTs, endTs, count)
+}
+
+func (s *influxStore) QueryEvent(deviceId, serviceName, eventName string, startTs, endTs, count *int64) []*v1.ReportEventMsg {
+	return influx_store.QueryEvent(deviceId, serviceName, eventName, startTs, endTs, count)
+}
+
+func (s *influxStore) QueryTwinSeries(deviceId, serviceName, propertyName, agg string, interval time.Duration, startTs, endTs int64, fill string) ([]*v1.TwinSeriesPoint, error) {
+	return influx_store.QueryTwinSeries(deviceId, serviceName, propertyName, agg, interval, startTs, endTs, fill)
+}
+
+func (s *influxStore) QueryLatestTwins(deviceId string) []*v1.TwinProperty {
+	return influx_store.QueryLatestTwins(deviceId)
+}
+
+func (s *influxStore) DeleteTwin(deviceId, serviceName, propertyName string) error {
+	return influx_store.DeleteTwin(deviceId, serviceName, propertyName)
+}
+
+func (s *influxStore) DeleteEvent(deviceId, serviceName, eventName string) error {
+	return influx_store.DeleteEvent(deviceId, serviceName, eventName)
+}
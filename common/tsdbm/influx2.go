@@ -0,0 +1,61 @@
+package tsdbm
+
+import (
+	"time"
+
+	"github.com/edgehook/ithings/common/influxdbm/influx2_store"
+	"github.com/edgehook/ithings/common/types/v1"
+)
+
+// influx2Store keep the time series in the influxdb 2.x.
+type influx2Store struct{}
+
+func NewInflux2Store() TimeSeriesStore {
+	return &influx2Store{}
+}
+
+func (s *influx2Store) StoreTwin(deviceID string, twins []*v1.TwinProperty) error {
+	return influx2_store.StoreTwin(deviceID, twins)
+}
+
+func (s *influx2Store) StoreEvent(event *v1.ReportEventMsg) error {
+	return influx2_store.StoreEvent(event)
+}
+
+func (s *influx2Store) QueryTableTwin(deviceId, serviceName, propertyName, startTime, endTime string, count *int64) []*v1.InfluxTwinData {
+	return influx2_store.QueryTableTwin(deviceId, serviceName, propertyName, startTime, endTime, count)
+}
+
+func (s *influx2Store) QueryTableEvent(deviceId, serviceName, eventName, startTime, endTime string, count *int64) []*v1.InfluxEventData {
+	return influx2_store.QueryTableEvent(deviceId, serviceName, eventName, startTime, endTime, count)
+}
+
+func (s *influx2Store) QueryTwin(deviceId, serviceName, propertyName string, startTs, endTs, count *int64) []*v1.TwinProperty {
+	return influx2_store.QueryTwin(deviceId, serviceName, propertyName, startTs, endTs, count)
+}
+
+func (s *influx2Store) QueryEvent(deviceId, serviceName, eventName string, startTs, endTs, count *int64) []*v1.ReportEventMsg {
+	return influx2_store.QueryEvent(deviceId, serviceName, eventName, startTs, endTs, count)
+}
+
+// QueryTwinSeries the empty windows are filled here like the fill() of influxql.
+func (s *influx2Store) QueryTwinSeries(deviceId, serviceName, propertyName, agg string, interval time.Duration, startTs, endTs int64, fill string) ([]*v1.TwinSeriesPoint, error) {
+	points, err := influx2_store.QueryTwinSeries(deviceId, serviceName, propertyName, agg, interval, startTs, endTs)
+	if err != nil {
+		return nil, err
+	}
+
+	return fillPoints(points, fill)
+}
+
+func (s *influx2Store) QueryLatestTwins(deviceId string) []*v1.TwinProperty {
+	return influx2_store.QueryLatestTwins(deviceId)
+}
+
+func (s *influx2Store) DeleteTwin(deviceId, serviceName, propertyName string) error {
+	return influx2_store.DeleteTwin(deviceId, serviceName, propertyName)
+}
+
+func (s *influx2Store) DeleteEvent(deviceId, serviceName, eventName string) error {
+	return influx2_store.DeleteEvent(deviceId, serviceName, eventName)
+}
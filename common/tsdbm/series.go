@@ -0,0 +1,94 @@
+package tsdbm
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/edgehook/ithings/common/influxdbm/influx_store"
+	"github.com/edgehook/ithings/common/types/v1"
+)
+
+// aggregate the values which are ordered by ts.
+func aggregate(agg string, values []float64) float64 {
+	switch agg {
+	case influx_store.AggCount:
+		return float64(len(values))
+	case influx_store.AggFirst:
+		return values[0]
+	case influx_store.AggLast:
+		return values[len(values)-1]
+	}
+
+	result := values[0]
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+		if agg == influx_store.AggMin && v < result {
+			result = v
+		}
+		if agg == influx_store.AggMax && v > result {
+			result = v
+		}
+	}
+
+	switch agg {
+	case influx_store.AggSum:
+		return sum
+	case influx_store.AggMean:
+		return sum / float64(len(values))
+	}
+	return result
+}
+
+// fillPoints fill the empty buckets like the fill() of influxdb.
+func fillPoints(points []*v1.TwinSeriesPoint, fill string) ([]*v1.TwinSeriesPoint, error) {
+	switch fill {
+	case "", "null":
+		return points, nil
+	case "none":
+		filled := make([]*v1.TwinSeriesPoint, 0, len(points))
+		for _, point := range points {
+			if point.Value != nil {
+				filled = append(filled, point)
+			}
+		}
+		return filled, nil
+	case "previous":
+		var previous *float64
+		for _, point := range points {
+			if point.Value == nil {
+				point.Value = previous
+			}
+			previous = point.Value
+		}
+		return points, nil
+	case "linear":
+		last := -1
+		for i, point := range points {
+			if point.Value == nil {
+				continue
+			}
+			if last >= 0 && i-last > 1 {
+				from, to := *points[last].Value, *point.Value
+				for j := last + 1; j < i; j++ {
+					value := from + (to-from)*float64(j-last)/float64(i-last)
+					points[j].Value = &value
+				}
+			}
+			last = i
+		}
+		return points, nil
+	}
+
+	value, err := strconv.ParseFloat(fill, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fill %s", fill)
+	}
+	for _, point := range points {
+		if point.Value == nil {
+			v := value
+			point.Value = &v
+		}
+	}
+	return points, nil
+}
@@ -0,0 +1,122 @@
+package tsdbm
+
+import (
+	"sync"
+	"time"
+
+	"github.com/edgehook/ithings/common/config"
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/influxdbm/influx2_store"
+	"github.com/edgehook/ithings/common/influxdbm/influx_store"
+	"github.com/edgehook/ithings/common/types/v1"
+	"k8s.io/klog/v2"
+)
+
+/*
+* TimeSeriesStore
+* store and query the history of the reported twins and events. The
+* influxdb is used if it's enabled, otherwise the main database.
+ */
+type TimeSeriesStore interface {
+	StoreTwin(deviceID string, twins []*v1.TwinProperty) error
+	StoreEvent(event *v1.ReportEventMsg) error
+
+	//query by the time range in RFC3339.
+	QueryTableTwin(deviceId, serviceName, propertyName, startTime, endTime string, count *int64) []*v1.InfluxTwinData
+	QueryTableEvent(deviceId, serviceName, eventName, startTime, endTime string, count *int64) []*v1.InfluxEventData
+	//query by the ts range in ms.
+	QueryTwin(deviceId, serviceName, propertyName string, startTs, endTs, count *int64) []*v1.TwinProperty
+	QueryEvent(deviceId, serviceName, eventName string, startTs, endTs, count *int64) []*v1.ReportEventMsg
+	QueryTwinSeries(deviceId, serviceName, propertyName, agg string, interval time.Duration, startTs, endTs int64, fill string) ([]*v1.TwinSeriesPoint, error)
+	//query the latest twin of each property.
+	QueryLatestTwins(deviceId string) []*v1.TwinProperty
+
+	DeleteTwin(deviceId, serviceName, propertyName string) error
+	DeleteEvent(deviceId, serviceName, eventName string) error
+}
+
+// the store which prunes the expired history by itself.
+type pruner interface {
+	StartPrune()
+}
+
+var (
+	defaultStore TimeSeriesStore
+	storeOnce    sync.Once
+)
+
+// GetTimeSeriesStore return the influxdb (2.x or 1.x) store if it's connected, otherwise the main database.
+func GetTimeSeriesStore() TimeSeriesStore {
+	storeOnce.Do(func() {
+		if influx2_store.GetInflux2Client() != nil {
+			defaultStore = NewInflux2Store()
+			klog.Infof("time series are stored in influxdb 2.x")
+			return
+		}
+		if influx_store.GetInfluxClient() != nil {
+			defaultStore = NewInfluxStore()
+			klog.Infof("time series are stored in influxdb")
+			return
+		}
+
+		defaultStore = NewGormStore(config.GetHistoryConfig())
+		klog.Infof("influxdb is disabled, time series are stored in the main database")
+	})
+
+	return defaultStore
+}
+
+// Start start pruning the expired history if the store needs.
+func Start() {
+	if p, ok := GetTimeSeriesStore().(pruner); ok {
+		p.StartPrune()
+	}
+}
+
+func StoreTwin(deviceID string, twins []*v1.TwinProperty) error {
+	return GetTimeSeriesStore().StoreTwin(deviceID, twins)
+}
+
+func StoreEvent(event *v1.ReportEventMsg) error {
+	return GetTimeSeriesStore().StoreEvent(event)
+}
+
+func QueryTableTwin(deviceId, serviceName, propertyName, startTime, endTime string, count *int64) []*v1.InfluxTwinData {
+	return GetTimeSeriesStore().QueryTableTwin(deviceId, serviceName, propertyName, startTime, endTime, count)
+}
+
+func QueryTableEvent(deviceId, serviceName, eventName, startTime, endTime string, count *int64) []*v1.InfluxEventData {
+	return GetTimeSeriesStore().QueryTableEvent(deviceId, serviceName, eventName, startTime, endTime, count)
+}
+
+func QueryTwin(deviceId, serviceName, propertyName string, startTs, endTs, count *int64) []*v1.TwinProperty {
+	return GetTimeSeriesStore().QueryTwin(deviceId, serviceName, propertyName, startTs, endTs, count)
+}
+
+func QueryEvent(deviceId, serviceName, eventName string, startTs, endTs, count *int64) []*v1.ReportEventMsg {
+	return GetTimeSeriesStore().QueryEvent(deviceId, serviceName, eventName, startTs, endTs, count)
+}
+
+func QueryTwinSeries(deviceId, serviceName, propertyName, agg string, interval time.Duration, startTs, endTs int64, fill string) ([]*v1.TwinSeriesPoint, error) {
+	return GetTimeSeriesStore().QueryTwinSeries(deviceId, serviceName, propertyName, agg, interval, startTs, endTs, fill)
+}
+
+func QueryLatestTwins(deviceId string) []*v1.TwinProperty {
+	return GetTimeSeriesStore().QueryLatestTwins(deviceId)
+}
+
+// DeleteTwin delete the twins of the device, the deviceId is required.
+func DeleteTwin(deviceId, serviceName, propertyName string) error {
+	if deviceId == "" {
+		return db.ErrEmptyDeviceId
+	}
+	return GetTimeSeriesStore().DeleteTwin(deviceId, serviceName, propertyName)
+}
+
+// DeleteEvent delete the events of the device, the deviceId is required.
+func DeleteEvent(deviceId, serviceName, eventName string) error {
+	if deviceId == "" {
+		return db.ErrEmptyDeviceId
+	}
+	return GetTimeSeriesStore().DeleteEvent(deviceId, serviceName, eventName)
+}
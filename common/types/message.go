@@ -2,6 +2,7 @@ package types
 
 import (
 	"encoding/json"
+	"strconv"
 	"strings"
 
 	"github.com/google/uuid"
@@ -18,11 +19,27 @@ const (
 	MSG_OPS_FETCH        = "fetch"
 	MSG_OPS_LIFE_CONTROL = "life_control"
 	MSG_OPS_SET_PROPERTY = "set_property"
+	MSG_OPS_CONTROL      = "control"
+
+	//the edge itself, not a mapper.
+	MSG_MAPPER_EDGE = "edge"
+
+	//report resources
+	MSG_RESOURCE_TWINS  = "twins"
+	MSG_RESOURCE_STATUS = "status"
+	MSG_RESOURCE_EVENT  = "event"
+	MSG_RESOURCE_DEVICE = "device"
+	MSG_RESOURCE_EDGE   = "edge"
 )
 
 type RequestPayload struct {
 	ID      string `json:"id"`
 	Content string `json:"content"`
+	//the signature of the frame from the edge, the signer is the
+	//device id, or empty if it's signed by the edge.
+	Timestamp int64  `json:"ts,omitempty"`
+	Signer    string `json:"signer,omitempty"`
+	Sign      string `json:"sign,omitempty"`
 }
 
 type Request struct {
@@ -108,6 +125,14 @@ func (r *Request) GetMessageID() string {
 	return r.Payload.ID
 }
 
+// SigningString return the signed fields of the request, the topic is included
+// so that the frame can't be replayed on another topic.
+func (r *Request) SigningString() string {
+	return strings.Join([]string{r.EdgeID, r.MapperID, r.Operation, r.Resource,
+		r.Payload.ID, strconv.FormatInt(r.Payload.Timestamp, 10), r.Payload.Signer,
+		r.Payload.Content}, "\n")
+}
+
 func (r *Request) BuildTopic() string {
 	topic := SERVER_TOPIC_PREFIX + "/" + r.EdgeID + "/mapper/" +
 		r.MapperID + "/" + r.Operation
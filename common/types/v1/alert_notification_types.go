@@ -0,0 +1,105 @@
+package v1
+
+const (
+	//alert notification events
+	AlertNotifyCreated      string = "created"
+	AlertNotifyLevelChanged string = "levelChanged"
+	AlertNotifyEscalated    string = "escalated"
+)
+
+/*
+* AlertNotification
+* the notification spec stored in AlertConfig.Notification as json,
+* every channel is optional.
+ */
+type AlertNotification struct {
+	Webhook *NotificationWebhook `form:"webhook" json:"webhook,omitempty"`
+	Email   *NotificationEmail   `form:"email" json:"email,omitempty"`
+	Syslog  *NotificationSyslog  `form:"syslog" json:"syslog,omitempty"`
+}
+
+type NotificationWebhook struct {
+	Url     string            `form:"url" json:"url"`
+	Method  string            `form:"method" json:"method,omitempty"`
+	Headers map[string]string `form:"headers" json:"headers,omitempty"`
+	//go template rendered from AlertNotifyData, default is the json of it.
+	BodyTemplate string `form:"bodyTemplate" json:"bodyTemplate,omitempty"`
+	//sign the body with HMAC-SHA256 if it's not empty.
+	Secret string `form:"secret" json:"secret,omitempty"`
+}
+
+type NotificationEmail struct {
+	//smtp server, host:port
+	Server   string   `form:"server" json:"server"`
+	Username string   `form:"username" json:"username,omitempty"`
+	Password string   `form:"password" json:"password,omitempty"`
+	From     string   `form:"from" json:"from"`
+	To       []string `form:"to" json:"to"`
+	//use implicit TLS, otherwise STARTTLS is used if the server supports.
+	TLS                bool   `form:"tls" json:"tls,omitempty"`
+	InsecureSkipVerify bool   `form:"insecureSkipVerify" json:"insecureSkipVerify,omitempty"`
+	SubjectTemplate    string `form:"subjectTemplate" json:"subjectTemplate,omitempty"`
+	BodyTemplate       string `form:"bodyTemplate" json:"bodyTemplate,omitempty"`
+}
+
+type NotificationSyslog struct {
+	//udp or tcp, default is udp.
+	Network string `form:"network" json:"network,omitempty"`
+	//syslog server, host:port
+	Address string `form:"address" json:"address"`
+	//syslog facility code, default is 16(local0).
+	Facility *int   `form:"facility" json:"facility,omitempty"`
+	Tag      string `form:"tag" json:"tag,omitempty"`
+	Template string `form:"template" json:"template,omitempty"`
+}
+
+/*
+* AlertEscalationStep
+* the escalation policy stored in AlertConfig.Escalation as a json list,
+* the step applies once the alert is still open after the duration.
+ */
+type AlertEscalationStep struct {
+	//duration since the alert is raised, such as 15m
+	After string `form:"after" json:"after"`
+	//raise the alert to this level, keep the level if it's nil.
+	Level *int64 `form:"level" json:"level,omitempty"`
+	//notify by this spec, use the notification of the alert config if it's nil.
+	Notification *AlertNotification `form:"notification" json:"notification,omitempty"`
+	//escalate the acknowledged alerts too, default is only the unsolved.
+	IncludeSolving bool `form:"includeSolving" json:"includeSolving,omitempty"`
+}
+
+// the data to render the notification templates.
+type AlertNotifyData struct {
+	Event         string `json:"event"`
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	Description   string `json:"description,omitempty"`
+	Level         int64  `json:"level"`
+	LevelName     string `json:"levelName"`
+	PreviousLevel *int64 `json:"previousLevel,omitempty"`
+	EdgeId        string `json:"edgeId"`
+	EdgeName      string `json:"edgeName,omitempty"`
+	DeviceId      string `json:"deviceId"`
+	DeviceName    string `json:"deviceName,omitempty"`
+	Record        string `json:"record"`
+	Status        int32  `json:"status"`
+	RepeatCount   int64  `json:"repeatCount,omitempty"`
+	//the escalation step, start from 1.
+	EscalationStep int   `json:"escalationStep,omitempty"`
+	Timestamp      int64 `json:"ts"`
+}
+
+// AlertLevelName get the readable name of the alert level.
+func AlertLevelName(level int64) string {
+	switch level {
+	case AlertInfoLevel:
+		return "info"
+	case AlertWarningLevel:
+		return "warning"
+	case AlertErrorLevel:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
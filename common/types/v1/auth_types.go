@@ -0,0 +1,89 @@
+package v1
+
+import "github.com/edgehook/ithings/common/dbm/model"
+
+const (
+	TokenTypeBearer = "Bearer"
+)
+
+type LoginRequest struct {
+	Username string `form:"username" json:"username" binding:"required"`
+	Password string `form:"password" json:"password" binding:"required"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `form:"refreshToken" json:"refreshToken" binding:"required"`
+}
+
+type LogoutRequest struct {
+	//optional, the refresh token of this session is revoked too.
+	RefreshToken string `form:"refreshToken" json:"refreshToken"`
+}
+
+/*
+* TokenPair
+* the access token is used in the Authorization or accesstoken header,
+* the refresh token is only used to get a new token pair.
+ */
+type TokenPair struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	TokenType    string `json:"tokenType"`
+	//lifetime of the access token in seconds
+	ExpiresIn int64 `json:"expiresIn"`
+}
+
+type UserRequest struct {
+	Username    string `form:"username" json:"username"`
+	Password    string `form:"password" json:"password"`
+	Description string `form:"description" json:"description"`
+	Disabled    *bool  `form:"disabled" json:"disabled"`
+	//the role name, default is viewer.
+	Role string `form:"role" json:"role"`
+	//the comma separated device groups and edges the user is restricted to,
+	//empty allows no device unless the role is admin.
+	GroupIDs string `form:"groupIds" json:"groupIds"`
+	EdgeIDs  string `form:"edgeIds" json:"edgeIds"`
+}
+
+/*
+* RoleRequest
+* every permission is "*" or "resource:action", the resources are models,
+* devices, twins, alerts, dataforward and users, the actions are read,
+* write and delete, the resource or action can be "*".
+ */
+type RoleRequest struct {
+	Name        string   `form:"name" json:"name"`
+	Description string   `form:"description" json:"description"`
+	Permissions []string `form:"permissions" json:"permissions"`
+}
+
+type PasswordRequest struct {
+	//the old password is required when the user changes its own password.
+	OldPassword string `form:"oldPassword" json:"oldPassword"`
+	Password    string `form:"password" json:"password" binding:"required"`
+}
+
+/*
+* APIKeyRequest
+* the scopes are the same as the permissions of the role except users,
+* e.g. "twins:read" to read the twins, "twins:write" to set the desired
+* values and "alerts:*" to manage the alert rules.
+ */
+type APIKeyRequest struct {
+	Name        string   `form:"name" json:"name"`
+	Description string   `form:"description" json:"description"`
+	Scopes      []string `form:"scopes" json:"scopes"`
+	Disabled    *bool    `form:"disabled" json:"disabled"`
+	//requests per second and burst, 0 is the default.
+	RateLimit float64 `form:"rateLimit" json:"rateLimit"`
+	Burst     int     `form:"burst" json:"burst"`
+	//the timestamp in ms, 0 never expires.
+	ExpireTimeStamp int64 `form:"expireTimeStamp" json:"expireTimeStamp"`
+}
+
+// APIKeyCreated is the new API key, the key is only shown here.
+type APIKeyCreated struct {
+	APIKey *model.APIKey `json:"apiKey"`
+	Key    string        `json:"key"`
+}
@@ -6,9 +6,10 @@ const (
 	SOURCETYPEEDGE  string = "edge"
 	SOURCETYPETAG   string = "tag"
 
-	DestinationTypeAMQP  string = "amqp"
-	DestinationTypeKafka string = "kafka"
-	DestinationTypeMqtt  string = "mqtt"
+	DestinationTypeAMQP    string = "amqp"
+	DestinationTypeKafka   string = "kafka"
+	DestinationTypeMqtt    string = "mqtt"
+	DestinationTypeWebhook string = "webhook"
 )
 
 // dataForward
@@ -29,6 +30,19 @@ type DestinationKafka struct {
 }
 
 type DestinationMqtt struct {
+	// the topic to publish, default is ithings/forward/<deviceId>
+	MqttTopic string `form:"mqttTopic" json:"mqttTopic,omitempty"`
+}
+
+type DestinationWebhook struct {
+	Url    string `form:"url" json:"url,omitempty"`
+	Method string `form:"method" json:"method,omitempty"`
+	//custom http headers
+	Headers map[string]string `form:"headers" json:"headers,omitempty"`
+	//go template rendered from the forward message, default is the json of it.
+	BodyTemplate string `form:"bodyTemplate" json:"bodyTemplate,omitempty"`
+	//the key of the HMAC-SHA256 signature.
+	Secret string `form:"secret" json:"secret,omitempty"`
 }
 
 type DataForwardDestination struct {
@@ -42,4 +56,23 @@ type DataForwardDestination struct {
 	*DestinationKafka `json:",inline,omitempty"`
 	//mqtt
 	*DestinationMqtt `json:",inline,omitempty"`
+	//webhook
+	*DestinationWebhook `json:",inline,omitempty"`
+}
+
+const (
+	ForwardTypeTwins string = "twins"
+	ForwardTypeEvent string = "event"
+)
+
+// the message forwarded to the destinations.
+type ForwardMessage struct {
+	Type       string          `json:"type"`
+	EdgeID     string          `json:"edgeId"`
+	DeviceID   string          `json:"deviceId"`
+	DeviceName string          `json:"deviceName,omitempty"`
+	ModelID    int64           `json:"modelId,omitempty"`
+	Timestamp  int64           `json:"ts"`
+	Twins      []*TwinProperty `json:"twins,omitempty"`
+	Event      *ReportEventMsg `json:"event,omitempty"`
 }
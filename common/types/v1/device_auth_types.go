@@ -0,0 +1,25 @@
+package v1
+
+/*
+* DeviceSecret
+* the secret of the device or the edge, the secret and the MQTT password
+* derived from it are only shown when it's generated.
+ */
+type DeviceSecret struct {
+	ID       string `json:"id"`
+	AuthType string `json:"authType"`
+	Secret   string `json:"secret,omitempty"`
+	//the MQTT username is the device id or the edge id.
+	MqttUsername string `json:"mqttUsername,omitempty"`
+	MqttPassword string `json:"mqttPassword,omitempty"`
+	//the previous secret is accepted until this timestamp in ms.
+	PreviousSecretExpireTimeStamp int64 `json:"previousSecretExpireTimeStamp,omitempty"`
+	UpdateTimeStamp               int64 `json:"updateTimeStamp,omitempty"`
+}
+
+type DeviceSecretRequest struct {
+	//optional, a random secret is generated if it's empty.
+	Secret string `form:"secret" json:"secret"`
+	//drop the previous secret at once instead of keeping it in the grace period.
+	Immediate bool `form:"immediate" json:"immediate"`
+}
@@ -70,14 +70,15 @@ type DeviceSpec struct {
 	DeviceIdentificationCode string `form:"id_code" json:"id_code,omitempty"`
 	Description              string `form:"desc" json:"desc"`
 
-	//group.
-	//TODO: reserved in future
+	//group, the users can be restricted to the groups by the id.
 	GroupName string `form:"group_name" json:"group_name,omitempty"`
+	GroupID   string `form:"group_id" json:"group_id,omitempty"`
 	//who create the device by ID.
 	Creator string `form:"creator" json:"creator,omitempty"`
 
 	// +optional
-	//TODO: reserved in future
+	//the secret to sign the frames of this device, at least 16 characters,
+	//the auth type is hmac if it's set. It's managed by the secret API later.
 	DeviceAuthType string `form:"auth_type" json:"auth_type,omitempty"`
 	Secret         string `form:"secret" json:"secret,omitempty"`
 
@@ -422,15 +423,13 @@ func CopyDeviceInstance(deviceInstance *db.DeviceInstance, edgeId string) error
 		Name:                     deviceInstance.Name,
 		EdgeID:                   edgeId,
 		DeviceOS:                 deviceInstance.DeviceOS,
-		DeviceCategory:           deviceInstance.DeviceOS,
+		DeviceCategory:           deviceInstance.DeviceCategory,
 		DeviceVersion:            deviceInstance.DeviceVersion,
 		DeviceIdentificationCode: deviceInstance.DeviceIdentificationCode,
 		Description:              deviceInstance.Description,
 		GroupName:                deviceInstance.GroupName,
 		GroupID:                  deviceInstance.GroupID,
 		Creator:                  deviceInstance.Creator,
-		DeviceAuthType:           deviceInstance.DeviceAuthType,
-		Secret:                   deviceInstance.DeviceAuthType,
 		DeviceType:               deviceInstance.DeviceType,
 		GatewayID:                deviceInstance.GatewayID,
 		GatewayName:              deviceInstance.GatewayName,
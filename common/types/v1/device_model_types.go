@@ -190,7 +190,7 @@ Loop:
 		return err
 	}
 
-	dmodel, err := model.GetDeviceModelByName(deviceModel.Name)
+	dmodel, err := model.GetDeviceModelByName(deviceName)
 	if err != nil {
 		klog.Errorf("Check deviceModel err: %v", err)
 		return err
@@ -221,6 +221,12 @@ Loop:
 					if isExist := model.IsExistPropertyModel(smodel.ID, property.Name); !isExist {
 						if err := tx.Create(&model.PropertyModel{
 							Name:           property.Name,
+							WriteAble:      property.WriteAble,
+							Report:         property.Report,
+							MaxValue:       property.MaxValue,
+							MinValue:       property.MinValue,
+							Unit:           property.Unit,
+							DataType:       property.DataType,
 							Description:    property.Description,
 							ServiceModelId: smodel.ID,
 						}).Error; err != nil {
@@ -238,6 +244,11 @@ Loop:
 					if isExist := model.IsExistEventModel(smodel.ID, event.Name); !isExist {
 						if err := tx.Create(&model.EventModel{
 							Name:           event.Name,
+							EventType:      event.EventType,
+							MaxValue:       event.MaxValue,
+							MinValue:       event.MinValue,
+							Unit:           event.Unit,
+							DataType:       event.DataType,
 							Description:    event.Description,
 							ServiceModelId: smodel.ID,
 						}).Error; err != nil {
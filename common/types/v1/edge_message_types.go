@@ -1,5 +1,11 @@
 package v1
 
+import (
+	"encoding/json"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+)
+
 /*
 * DeviceSpecMeta
 * this is for edge device or edge gateway
@@ -24,6 +30,136 @@ type DeviceSpecMeta struct {
 	Services []*DeviceServiceSpec `json:"svcs,omitempty"`
 }
 
+/*
+* NewDeviceSpecMeta
+* build the device spec meta from the device instance and it's model.
+ */
+func NewDeviceSpecMeta(di *db.DeviceInstance, dm *db.DeviceModel) *DeviceSpecMeta {
+	dsm := &DeviceSpecMeta{
+		DeviceID:                 di.DeviceID,
+		DeviceOS:                 di.DeviceOS,
+		DeviceCatagory:           di.DeviceCategory,
+		DeviceIdentificationCode: di.DeviceIdentificationCode,
+		State:                    di.State,
+		Services:                 make([]*DeviceServiceSpec, 0),
+	}
+
+	if di.Tags != "" {
+		tags := make(map[string]string)
+		if err := json.Unmarshal([]byte(di.Tags), &tags); err == nil {
+			dsm.Tags = tags
+		}
+	}
+	if di.Protocol != nil {
+		dsm.Protocol = *di.Protocol
+	}
+
+	for _, si := range di.ServiceInstances {
+		if si == nil {
+			continue
+		}
+
+		var sm *db.ServiceModel
+		if dm != nil {
+			sm = dm.FindServiceModel(si.Name)
+		}
+		dsm.Services = append(dsm.Services, newDeviceServiceSpec(si, sm))
+	}
+
+	return dsm
+}
+
+func newDeviceServiceSpec(si *db.ServiceInstance, sm *db.ServiceModel) *DeviceServiceSpec {
+	dss := &DeviceServiceSpec{
+		Name:       si.Name,
+		Properties: make([]*DevicePropertySpec, 0),
+		Events:     make([]*DeviceEventSpec, 0),
+		Commands:   make([]*DeviceCommandSpec, 0),
+	}
+
+	for _, pi := range si.PropertyInstances {
+		if pi == nil {
+			continue
+		}
+
+		pm := &DevicePropertyModel{Name: pi.Name}
+		if sm != nil {
+			for _, m := range sm.PropertyModels {
+				if m != nil && m.Name == pi.Name {
+					pm.Report = m.Report
+					pm.WriteAble = m.WriteAble
+					pm.MaxValue = m.MaxValue
+					pm.MinValue = m.MinValue
+					pm.Unit = m.Unit
+					pm.DataType = m.DataType
+					pm.Description = m.Description
+					break
+				}
+			}
+		}
+
+		dss.Properties = append(dss.Properties, &DevicePropertySpec{
+			DevicePropertyModel: pm,
+			AccessConfig:        pi.AccessConfig,
+		})
+	}
+
+	for _, ei := range si.EventInstances {
+		if ei == nil {
+			continue
+		}
+
+		em := &DeviceEventModel{Name: ei.Name}
+		if sm != nil {
+			for _, m := range sm.EventModels {
+				if m != nil && m.Name == ei.Name {
+					em.EventType = m.EventType
+					em.MaxValue = m.MaxValue
+					em.MinValue = m.MinValue
+					em.Unit = m.Unit
+					em.DataType = m.DataType
+					em.Description = m.Description
+					break
+				}
+			}
+		}
+
+		dss.Events = append(dss.Events, &DeviceEventSpec{
+			DeviceEventModel: em,
+			AccessConfig:     ei.AccessConfig,
+		})
+	}
+
+	for _, ci := range si.CommandInstances {
+		if ci == nil {
+			continue
+		}
+
+		cm := &DeviceCommandModel{Name: ci.Name}
+		if sm != nil {
+			for _, m := range sm.CommandModels {
+				if m != nil && m.Name == ci.Name {
+					cm.Description = m.Description
+					if m.RequestParam != "" {
+						params := make(map[string]string)
+						if err := json.Unmarshal([]byte(m.RequestParam), &params); err == nil {
+							cm.RequestParam = params
+						}
+					}
+					break
+				}
+			}
+		}
+
+		dss.Commands = append(dss.Commands, &DeviceCommandSpec{
+			DeviceCommandModel: cm,
+			AccessConfig:       ci.AccessConfig,
+		})
+	}
+
+	return dss
+}
+
 /*
 * Single device report message.
  */
@@ -74,3 +210,24 @@ type DeviceDesiredTwinsUpdateMessage struct {
 	//Optional:
 	DesiredTwins []*TwinProperty `json:"desired_twins,omitempty"`
 }
+
+// fetch desired twins message.
+type FetchDesiredTwinsMessage struct {
+	// fetch all devices in this edge if it's empty.
+	DeviceIDs []string `json:"d_ids,omitempty"`
+}
+
+// devices desired twins message.
+type DevicesDesiredTwinsMessage struct {
+	Devices []*DeviceDesiredTwinsUpdateMessage `json:"devs,omitempty"`
+}
+
+// device life control message.
+type DeviceLifeControlMsg struct {
+	DeviceID string `json:"d_id"`
+	// create, start, stop, delete, update
+	Action int `json:"action"`
+	// device spec, just for create/update.
+	// +optional
+	Spec *DeviceSpecMeta `json:"spec,omitempty"`
+}
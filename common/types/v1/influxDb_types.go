@@ -10,3 +10,31 @@ type InfluxEventData struct {
 	ReportEventMsg `json:"inline"`
 	Time           string `json:"time"`
 }
+
+// the aggregated value of the time bucket.
+type TwinSeriesPoint struct {
+	Timestamp int64 `json:"ts"`
+	//nil if there is no value in the bucket.
+	Value *float64 `json:"val"`
+}
+
+type TwinSeries struct {
+	DeviceId  string             `json:"deviceId"`
+	Service   string             `json:"svc"`
+	Property  string             `json:"pn"`
+	Aggregate string             `json:"agg"`
+	Interval  string             `json:"interval"`
+	Points    []*TwinSeriesPoint `json:"points"`
+}
+
+type TwinSeriesQuery struct {
+	//mean, min, max, sum, count, first or last, default is mean.
+	Aggregate string `form:"agg" json:"agg"`
+	//the time bucket such as 1m, default is 1m.
+	Interval string `form:"interval" json:"interval"`
+	//the time range in ms, default is the last 24 hours.
+	Start *int64 `form:"start" json:"start"`
+	End   *int64 `form:"end" json:"end"`
+	//null, none, previous, linear or a number.
+	Fill string `form:"fill" json:"fill"`
+}
@@ -44,6 +44,8 @@ const (
 	AlertLogSolving  int32 = 1
 	AlertLogResolved int32 = 2
 	AlertLogInvalid  int32 = 3
+	//the from status of the alert history when the alert log is created.
+	AlertLogStatusNone int32 = -1
 
 	//alert type: event, monitor
 	AlertLogTypeEvent   string = "event"
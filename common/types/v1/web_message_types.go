@@ -39,6 +39,14 @@ type DesiredPropertyMsg struct {
 	Value        interface{} `form:"value" json:"value,omitempty"`
 }
 
+// instance type of the access config
+const (
+	InstanceTypeDevice   = "device"
+	InstanceTypeProperty = "property"
+	InstanceTypeEvent    = "event"
+	InstanceTypeCommand  = "command"
+)
+
 // Device instance access config web api
 type InstanceConfig struct {
 	// Required: The device property name.
@@ -56,6 +64,8 @@ type Alert struct {
 	Description  string `form:"description" json:"description"`
 	Level        *int64 `form:"level" json:"level" binding:"required"`
 	Notification string `form:"notification" json:"notification"`
+	//json list of the escalation steps.
+	Escalation string `form:"escalation" json:"escalation"`
 }
 
 // Alert log api
@@ -63,6 +73,32 @@ type AlertLog struct {
 	Record string `form:"record" json:"record"`
 	Status *int32 `form:"status" json:"status"`
 	Level  *int64 `form:"level" json:"level"`
+	//who changes the status.
+	Operator string `form:"operator" json:"operator"`
+	Comment  string `form:"comment" json:"comment"`
+}
+
+// Alert log status transition api: acknowledge/resolve/invalidate
+type AlertTransition struct {
+	Operator string `form:"operator" json:"operator"`
+	Comment  string `form:"comment" json:"comment"`
+}
+
+// Alert silence web api, the empty scope matches all.
+type AlertSilence struct {
+	Name          string `form:"name" json:"name" binding:"required"`
+	Description   string `form:"description" json:"description"`
+	Enable        *bool  `form:"enable" json:"enable"`
+	EdgeId        string `form:"edgeId" json:"edgeId"`
+	DeviceId      string `form:"deviceId" json:"deviceId"`
+	DeviceModelId *int64 `form:"deviceModelId" json:"deviceModelId"`
+	Level         *int64 `form:"level" json:"level"`
+	//maintenance window in ms
+	StartTimeStamp int64 `form:"startTimeStamp" json:"startTimeStamp"`
+	EndTimeStamp   int64 `form:"endTimeStamp" json:"endTimeStamp"`
+	//daily window, HH:MM or HH:MM:SS
+	DailyStartTime string `form:"dailyStartTime" json:"dailyStartTime"`
+	DailyEndTime   string `form:"dailyEndTime" json:"dailyEndTime"`
 }
 
 // RuleLinkage web api  status: enable/disable
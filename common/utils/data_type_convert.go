@@ -2,7 +2,9 @@ package utils
 
 import (
 	"encoding/json"
+	"math"
 	"strconv"
+	"strings"
 )
 
 func ToString(value interface{}) string {
@@ -116,3 +118,20 @@ func ToInt(value interface{}) int {
 	}
 	return key
 }
+
+// ToNumber convert the value to float64 so that it can be aggregated, bool is 1 or 0.
+func ToNumber(value interface{}) (float64, bool) {
+	if b, ok := value.(bool); ok {
+		if b {
+			return 1, true
+		}
+		return 0, true
+	}
+
+	num, err := strconv.ParseFloat(strings.TrimSpace(ToString(value)), 64)
+	if err != nil || math.IsNaN(num) || math.IsInf(num, 0) {
+		return 0, false
+	}
+
+	return num, true
+}
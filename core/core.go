@@ -0,0 +1,81 @@
+package core
+
+import (
+	"github.com/edgehook/ithings/alert"
+	"github.com/edgehook/ithings/common/global"
+	"github.com/edgehook/ithings/common/tsdbm"
+	"github.com/edgehook/ithings/core/devicetwin"
+	"github.com/edgehook/ithings/core/twincache"
+	"github.com/edgehook/ithings/dataforward"
+	"github.com/edgehook/ithings/transport/deviceauth"
+	"github.com/jwzl/beehive/pkg/core"
+	beehiveCtx "github.com/jwzl/beehive/pkg/core/context"
+	"github.com/jwzl/wssocket/model"
+	"k8s.io/klog/v2"
+)
+
+type Core struct {
+	ic *ICore
+}
+
+// Register this module.
+func Register() {
+	c := &Core{}
+	core.Register(c)
+}
+
+// Name
+func (c *Core) Name() string {
+	return global.IMODULE_CORE
+}
+
+// Group
+func (c *Core) Group() string {
+	return global.IMODULE_CORE
+}
+
+// Enable indicates whether this module is enabled
+func (c *Core) Enable() bool {
+	//The module is always enabled!
+	return true
+}
+
+// Start this module.
+func (c *Core) Start() {
+	c.ic = NewICore()
+	if c.ic == nil {
+		klog.Errorf("Create icore failed, core exit!")
+		return
+	}
+	defer c.ic.Close()
+	defaultICore = c.ic
+	//the secrets encrypted by the legacy DES or the retired keys.
+	deviceauth.MigrateSecrets()
+	tsdbm.Start()
+	twincache.Start()
+	devicetwin.Start()
+	dataforward.Start()
+	alert.StartEscalation()
+
+	for {
+		select {
+		case <-beehiveCtx.Done():
+			klog.Warningf("core module exit!")
+			return
+		default:
+		}
+
+		v, err := beehiveCtx.Receive(c.Name())
+		if err != nil {
+			klog.Errorf("core receive message with err: %v", err)
+			continue
+		}
+
+		msg, isMsg := v.(*model.Message)
+		if !isMsg {
+			continue
+		}
+
+		c.ic.HandleMessage(msg)
+	}
+}
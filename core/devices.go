@@ -0,0 +1,306 @@
+package core
+
+import (
+	"encoding/json"
+	"github.com/edgehook/ithings/alert"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/global"
+	"github.com/edgehook/ithings/common/tsdbm"
+	"github.com/edgehook/ithings/common/types"
+	"github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/common/utils"
+	"github.com/edgehook/ithings/core/devicetwin"
+	"github.com/edgehook/ithings/core/eventdetector"
+	"github.com/edgehook/ithings/core/twincache"
+	"github.com/edgehook/ithings/dataforward"
+	"github.com/edgehook/ithings/rulelinkage"
+	"k8s.io/klog/v2"
+)
+
+/*
+* handleRegister
+* response all device specs in this edge.
+ */
+func (ic *ICore) handleRegister(req *types.Request) {
+	dis, err := db.GetAllDeviceInstancesV2ByEdgeId(req.EdgeID)
+	if err != nil {
+		utils.SendResponse2Edge(req, global.IRespCodeInternalError, global.IRespInternalErrString)
+		return
+	}
+
+	//device model cache.
+	models := make(map[int64]*db.DeviceModel)
+	specs := make([]*v1.DeviceSpecMeta, 0)
+	for _, di := range dis {
+		if di == nil {
+			continue
+		}
+
+		dm, exist := models[di.DeviceModelId]
+		if !exist {
+			dm, err = db.GetDeviceModelAllInfoByID(di.DeviceModelId)
+			if err != nil {
+				klog.Warningf("device %s get model with err: %v", di.DeviceID, err)
+				dm = nil
+			}
+			models[di.DeviceModelId] = dm
+		}
+
+		specs = append(specs, v1.NewDeviceSpecMeta(di, dm))
+	}
+
+	klog.Infof("edge %s(%s) registered with %d devices", req.EdgeID, req.MapperID, len(specs))
+	utils.SendResponse2Edge(req, global.IRespCodeOk, specs)
+}
+
+/*
+* handleReport
+* store the device twins, status and events.
+ */
+func (ic *ICore) handleReport(req *types.Request) {
+	var err error
+	//the count of the reported and the accepted devices.
+	var reported, accepted int
+
+	content := []byte(req.GetContent())
+	switch req.Resource {
+	case types.MSG_RESOURCE_TWINS:
+		msg := &v1.ReportDevicesMessage{}
+		if err = json.Unmarshal(content, msg); err != nil {
+			break
+		}
+		reported, accepted = len(msg.Devices), ic.reportTwins(req.EdgeID, msg)
+	case types.MSG_RESOURCE_STATUS:
+		msg := &v1.DevicesStatusMessage{}
+		if err = json.Unmarshal(content, msg); err != nil {
+			break
+		}
+		reported, accepted = len(msg.DevicesStatus), ic.reportStatus(req.EdgeID, msg)
+	case types.MSG_RESOURCE_EVENT:
+		msg := &v1.ReportEventMsg{}
+		if err = json.Unmarshal(content, msg); err != nil {
+			break
+		}
+		if !ownedBy(req.EdgeID, msg.DeviceID) {
+			klog.Warningf("edge %s reports event of foreign device %s", req.EdgeID, msg.DeviceID)
+			utils.SendResponse2Edge(req, global.IRespCodeNoSuchDevice, global.IRespNoSuchDeviceString)
+			return
+		}
+		ic.reportEvent(msg, false)
+	default:
+		klog.Warningf("unsupported report resource %s", req.Resource)
+		utils.SendResponse2Edge(req, global.IRespCodeInvalidMsg, global.IRespInvalidMsgString)
+		return
+	}
+
+	if err != nil {
+		klog.Errorf("parse report message with err: %v", err)
+		utils.SendResponse2Edge(req, global.IRespCodeInvalidMsg, global.IRespInvalidMsgString)
+		return
+	}
+	if reported > 0 && accepted == 0 {
+		utils.SendResponse2Edge(req, global.IRespCodeNoSuchDevice, global.IRespNoSuchDeviceString)
+		return
+	}
+
+	utils.SendResponse2Edge(req, global.IRespCodeOk, global.IRespOkString)
+}
+
+// ownedBy reports whether the device is bound to the edge.
+func ownedBy(edgeID, deviceID string) bool {
+	if edgeID == "" || deviceID == "" {
+		return false
+	}
+
+	di := db.GetDeviceInstance(deviceID)
+	return di != nil && di.EdgeID == edgeID
+}
+
+// reportTwins handle the twins of the devices bound to the edge, return the count of them.
+func (ic *ICore) reportTwins(edgeID string, msg *v1.ReportDevicesMessage) int {
+	accepted := 0
+
+	for _, dev := range msg.Devices {
+		if dev == nil || dev.DeviceID == "" {
+			continue
+		}
+		if !ownedBy(edgeID, dev.DeviceID) {
+			klog.Warningf("edge %s reports twins of foreign device %s", edgeID, dev.DeviceID)
+			continue
+		}
+		accepted++
+
+		if err := tsdbm.StoreTwin(dev.DeviceID, dev.Services); err != nil {
+			klog.Errorf("store twins of %s with err: %v", dev.DeviceID, err)
+		}
+		devicetwin.UpdateReported(dev.DeviceID, dev.Services)
+		dataforward.ForwardTwins(dev.DeviceID, dev.Services)
+
+		//detect the events on server side.
+		events, recovered := eventdetector.HandleTwins(dev.DeviceID, dev.Services)
+		ic.handleDetected(events, recovered)
+
+		//the cache only notifies the optional subscribers.
+		twincache.Update(dev.DeviceID, dev.Services)
+
+		//the device is online since it reports the twins.
+		db.UpdateDeviceInstance(dev.DeviceID, &db.DeviceInstance{
+			DeviceStatus: global.DeviceStatusOnline,
+		})
+	}
+
+	return accepted
+}
+
+// reportStatus update the status of the devices bound to the edge, return the count of them.
+func (ic *ICore) reportStatus(edgeID string, msg *v1.DevicesStatusMessage) int {
+	accepted := 0
+
+	for _, ds := range msg.DevicesStatus {
+		if ds == nil || ds.DeviceID == "" {
+			continue
+		}
+		if !ownedBy(edgeID, ds.DeviceID) {
+			klog.Warningf("edge %s reports status of foreign device %s", edgeID, ds.DeviceID)
+			continue
+		}
+		accepted++
+
+		db.UpdateDeviceInstance(ds.DeviceID, &db.DeviceInstance{
+			DeviceStatus: ds.Status,
+		})
+
+		//0: normal, 2: error
+		health := int64(0)
+		if ds.ErrorMessage != "" {
+			klog.Warningf("device %s: %s", ds.DeviceID, ds.ErrorMessage)
+			health = 2
+		}
+		db.UpdateDeviceInstanceHealth(ds.DeviceID, health)
+	}
+
+	return accepted
+}
+
+// handleDetected handle the events detected on server side.
+func (ic *ICore) handleDetected(events, recovered []*v1.ReportEventMsg) {
+	for _, event := range events {
+		ic.reportEvent(event, true)
+	}
+	for _, event := range recovered {
+		alert.AutoResolve(event.DeviceID, alert.TriggerKey(event.ServiceName, event.EventName))
+	}
+}
+
+// reportEvent handle the event, detected is true if it's detected on server side.
+func (ic *ICore) reportEvent(msg *v1.ReportEventMsg, detected bool) {
+	if msg.DeviceID == "" {
+		return
+	}
+	if msg.Timestamp == 0 {
+		msg.Timestamp = utils.GetNowTimeStamp()
+	}
+
+	if err := tsdbm.StoreEvent(msg); err != nil {
+		klog.Errorf("store event of %s with err: %v", msg.DeviceID, err)
+	}
+	if detected {
+		rulelinkage.HandleMonitorEvent(msg)
+	} else {
+		rulelinkage.HandleEvent(msg)
+	}
+	dataforward.ForwardEvent(msg)
+}
+
+/*
+* handleFetch
+* response the desired twins of the devices.
+ */
+func (ic *ICore) handleFetch(req *types.Request) {
+	fetch := &v1.FetchDesiredTwinsMessage{}
+	if content := req.GetContent(); content != "" {
+		if err := json.Unmarshal([]byte(content), fetch); err != nil {
+			klog.Errorf("parse fetch message with err: %v", err)
+			utils.SendResponse2Edge(req, global.IRespCodeInvalidMsg, global.IRespInvalidMsgString)
+			return
+		}
+	}
+
+	deviceIDs := fetch.DeviceIDs
+	if len(deviceIDs) == 0 {
+		dis, err := db.GetDeviceInstanceByEdgeId(req.EdgeID)
+		if err != nil {
+			utils.SendResponse2Edge(req, global.IRespCodeInternalError, global.IRespInternalErrString)
+			return
+		}
+
+		for _, di := range dis {
+			deviceIDs = append(deviceIDs, di.DeviceID)
+		}
+	}
+
+	resp := &v1.DevicesDesiredTwinsMessage{
+		Devices: make([]*v1.DeviceDesiredTwinsUpdateMessage, 0),
+	}
+	for _, deviceID := range deviceIDs {
+		if len(fetch.DeviceIDs) > 0 && !ownedBy(req.EdgeID, deviceID) {
+			continue
+		}
+
+		twins := devicetwin.GetDesiredTwins(deviceID)
+		if len(twins) == 0 {
+			continue
+		}
+
+		resp.Devices = append(resp.Devices, &v1.DeviceDesiredTwinsUpdateMessage{
+			DeviceID:     deviceID,
+			DesiredTwins: twins,
+		})
+	}
+
+	utils.SendResponse2Edge(req, global.IRespCodeOk, resp)
+}
+
+/*
+* handleLifeControl
+* the edge notify us the device life changed.
+ */
+func (ic *ICore) handleLifeControl(req *types.Request) {
+	msg := &v1.DeviceLifeControlMsg{}
+	err := json.Unmarshal([]byte(req.GetContent()), msg)
+	if err != nil || msg.DeviceID == "" {
+		klog.Errorf("parse life control message with err: %v", err)
+		utils.SendResponse2Edge(req, global.IRespCodeInvalidMsg, global.IRespInvalidMsgString)
+		return
+	}
+
+	if !ownedBy(req.EdgeID, msg.DeviceID) {
+		klog.Warningf("edge %s controls foreign device %s", req.EdgeID, msg.DeviceID)
+		utils.SendResponse2Edge(req, global.IRespCodeNoSuchDevice, global.IRespNoSuchDeviceString)
+		return
+	}
+
+	doc := &db.DeviceInstance{}
+	switch msg.Action {
+	case global.DeviceStart:
+		doc.State = global.DeviceStateStarted
+	case global.DeviceStop:
+		doc.State = global.DeviceStateStopped
+	case global.DeviceDelete:
+		doc.State = global.DeviceStateStopped
+		doc.DeviceStatus = global.DeviceStatusInactive
+	case global.DeviceCreate, global.DeviceUpdate:
+		doc.DeviceStatus = global.DeviceStatusActive
+	default:
+		utils.SendResponse2Edge(req, global.IRespCodeInvalidMsg, global.IRespInvalidMsgString)
+		return
+	}
+
+	if err := db.UpdateDeviceInstance(msg.DeviceID, doc); err != nil {
+		utils.SendResponse2Edge(req, global.IRespCodeInternalError, global.IRespInternalErrString)
+		return
+	}
+
+	utils.SendResponse2Edge(req, global.IRespCodeOk, global.IRespOkString)
+}
@@ -0,0 +1,425 @@
+package devicetwin
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/global"
+	"github.com/edgehook/ithings/common/types"
+	"github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/common/utils"
+	"github.com/edgehook/ithings/core/syncreq"
+	"github.com/edgehook/ithings/core/twincache"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultCheckInterval = time.Second
+	//resend the desired value if it's not converged.
+	defaultRetryInterval = global.DefaultEdgeMaxResponseTime
+	//the expired desired value is kept with the error before it's removed.
+	defaultExpiredRetention = 24 * time.Hour
+
+	ErrMsgDesiredExpired = "desired value expired"
+)
+
+type desiredTwin struct {
+	twin     *v1.TwinProperty
+	setTime  time.Time
+	lifeTime time.Duration
+	lastPush time.Time
+	//the desired value expired, stop retry.
+	expired bool
+}
+
+type deviceTwins struct {
+	deviceID string
+	edgeID   string
+	mapperID string
+	desired  map[string]*desiredTwin
+}
+
+/*
+* TwinManager
+* keep the desired/reported twins of the devices, and reconcile them
+* until converged or the desired value expired.
+ */
+type TwinManager struct {
+	sync.RWMutex
+	devices map[string]*deviceTwins
+	once    sync.Once
+}
+
+var (
+	defaultTwinManager = NewTwinManager()
+)
+
+func NewTwinManager() *TwinManager {
+	return &TwinManager{
+		devices: make(map[string]*deviceTwins),
+	}
+}
+
+func twinKey(svc, prop string) string {
+	return svc + "/" + prop
+}
+
+// copyTwin copy the twin so that it can be used out of the lock.
+func copyTwin(twin *v1.TwinProperty) *v1.TwinProperty {
+	t := *twin
+	return &t
+}
+
+func (d *desiredTwin) expireTime() time.Time {
+	return d.setTime.Add(d.lifeTime)
+}
+
+func (d *desiredTwin) toModel(deviceID string) *db.DesiredTwin {
+	value, _ := json.Marshal(d.twin.Value)
+	return &db.DesiredTwin{
+		DeviceID:        deviceID,
+		Service:         d.twin.Service,
+		PropertyName:    d.twin.PropertyName,
+		Value:           string(value),
+		ErrorMessage:    d.twin.ErrorMessage,
+		SetTimeStamp:    d.setTime.UnixNano() / 1e6,
+		ExpireTimeStamp: d.expireTime().UnixNano() / 1e6,
+	}
+}
+
+// isSameValue compare the desired and reported value.
+func isSameValue(desired, reported interface{}) bool {
+	d := utils.ToString(desired)
+	r := utils.ToString(reported)
+	if d == r {
+		return true
+	}
+
+	df, err1 := strconv.ParseFloat(d, 64)
+	rf, err2 := strconv.ParseFloat(r, 64)
+	return err1 == nil && err2 == nil && df == rf
+}
+
+// Start restore the desired twins and start the reconcile loop.
+func (tm *TwinManager) Start() {
+	tm.once.Do(func() {
+		tm.restore()
+		go tm.reconcileLoop()
+	})
+}
+
+/*
+* restore
+* load the stored desired twins, the twins of the deleted devices are removed.
+ */
+func (tm *TwinManager) restore() {
+	twins, err := db.GetDesiredTwins()
+	if err != nil {
+		return
+	}
+
+	devices := make(map[string]*db.DeviceInstance)
+	for _, t := range twins {
+		di, exist := devices[t.DeviceID]
+		if !exist {
+			if device, err := db.GetDeviceInstanceByDeviceId(t.DeviceID); err == nil {
+				di = &device
+			}
+			devices[t.DeviceID] = di
+		}
+		if di == nil {
+			db.DeleteDesiredTwinByDeviceId(t.DeviceID)
+			continue
+		}
+
+		var value interface{}
+		if err := json.Unmarshal([]byte(t.Value), &value); err != nil {
+			klog.Warningf("drop the desired twin %s/%s of %s: %v", t.Service, t.PropertyName, t.DeviceID, err)
+			db.DeleteDesiredTwin(t)
+			continue
+		}
+
+		setTime := time.Unix(0, t.SetTimeStamp*int64(time.Millisecond))
+		twin := v1.NewTwinProperty(t.Service, t.PropertyName, t.ErrorMessage, value)
+		twin.Timestamp = t.SetTimeStamp
+
+		tm.Lock()
+		dt := tm.ensureDeviceTwins(di)
+		dt.desired[twinKey(t.Service, t.PropertyName)] = &desiredTwin{
+			twin:     twin,
+			setTime:  setTime,
+			lifeTime: time.Duration(t.ExpireTimeStamp-t.SetTimeStamp) * time.Millisecond,
+			expired:  t.ErrorMessage != "",
+		}
+		tm.Unlock()
+	}
+
+	klog.Infof("restore %d desired twins", len(twins))
+}
+
+// ensureDeviceTwins get or create the twins of the device, it must be called with the lock.
+func (tm *TwinManager) ensureDeviceTwins(di *db.DeviceInstance) *deviceTwins {
+	dt, exist := tm.devices[di.DeviceID]
+	if !exist {
+		dt = &deviceTwins{
+			deviceID: di.DeviceID,
+			desired:  make(map[string]*desiredTwin),
+		}
+		tm.devices[di.DeviceID] = dt
+	}
+	dt.edgeID = di.EdgeID
+	dt.mapperID = di.ProtocolType
+
+	return dt
+}
+
+/*
+* SetDesired
+* store the desired value and push it to the edge.
+ */
+func (tm *TwinManager) SetDesired(msg *v1.DesiredPropertyMsg) error {
+	if msg == nil || msg.DeviceId == "" || msg.PropertyName == "" {
+		return global.ErrInvalidParms
+	}
+
+	di, err := db.GetDeviceInstanceByDeviceId(msg.DeviceId)
+	if err != nil {
+		return global.ErrNoSuchDevice
+	}
+	lifeTime := global.DefaultLifeTimeOfDesiredValue
+	if di.LifeTimeOfDesiredValue > 0 {
+		lifeTime = time.Duration(di.LifeTimeOfDesiredValue) * time.Millisecond
+	}
+
+	now := time.Now()
+	desired := &desiredTwin{
+		twin:     v1.NewTwinProperty(msg.ServiceName, msg.PropertyName, "", msg.Value),
+		setTime:  now,
+		lifeTime: lifeTime,
+		lastPush: now,
+	}
+	if err := db.SaveDesiredTwin(desired.toModel(msg.DeviceId)); err != nil {
+		return err
+	}
+
+	tm.Lock()
+	dt := tm.ensureDeviceTwins(&di)
+	dt.desired[twinKey(msg.ServiceName, msg.PropertyName)] = desired
+	twins := tm.pendingTwins(dt)
+	//the device twins are changed by the others after unlock.
+	edgeID, mapperID, deviceID := dt.edgeID, dt.mapperID, dt.deviceID
+	tm.Unlock()
+
+	tm.pushDesired(edgeID, mapperID, deviceID, twins)
+
+	return nil
+}
+
+/*
+* UpdateReported
+* check the reported twins, the desired twin is removed once it's converged.
+ */
+func (tm *TwinManager) UpdateReported(deviceID string, twins []*v1.TwinProperty) {
+	converged := make([]*db.DesiredTwin, 0)
+
+	tm.Lock()
+	dt, exist := tm.devices[deviceID]
+	if !exist {
+		tm.Unlock()
+		return
+	}
+	for _, twin := range twins {
+		if twin == nil {
+			continue
+		}
+
+		key := twinKey(twin.Service, twin.PropertyName)
+		if dtwin, exist := dt.desired[key]; exist && twin.ErrorMessage == "" &&
+			isSameValue(dtwin.twin.Value, twin.Value) {
+			klog.V(4).Infof("twin %s of %s converged", key, deviceID)
+			delete(dt.desired, key)
+			converged = append(converged, dtwin.toModel(deviceID))
+		}
+	}
+	if len(dt.desired) == 0 {
+		delete(tm.devices, deviceID)
+	}
+	tm.Unlock()
+
+	for _, twin := range converged {
+		db.DeleteDesiredTwin(twin)
+	}
+}
+
+// GetDesiredTwins return the desired twins which are not expired.
+func (tm *TwinManager) GetDesiredTwins(deviceID string) []*v1.TwinProperty {
+	tm.RLock()
+	defer tm.RUnlock()
+
+	dt, exist := tm.devices[deviceID]
+	if !exist {
+		return nil
+	}
+
+	return tm.pendingTwins(dt)
+}
+
+// GetDeviceTwin return the desired twins and the last known reported twins of this device.
+func (tm *TwinManager) GetDeviceTwin(deviceID string) *v1.DeviceTwin {
+	tm.RLock()
+	desired := make([]*v1.TwinProperty, 0)
+	if dt, exist := tm.devices[deviceID]; exist {
+		for _, d := range dt.desired {
+			desired = append(desired, copyTwin(d.twin))
+		}
+	}
+	tm.RUnlock()
+
+	return v1.NewDeviceTwin(desired, twincache.GetTwins(deviceID))
+}
+
+// RemoveDevice clear all twins of this device.
+func (tm *TwinManager) RemoveDevice(deviceID string) {
+	tm.Lock()
+	delete(tm.devices, deviceID)
+	tm.Unlock()
+
+	db.DeleteDesiredTwinByDeviceId(deviceID)
+}
+
+func (tm *TwinManager) pendingTwins(dt *deviceTwins) []*v1.TwinProperty {
+	twins := make([]*v1.TwinProperty, 0)
+	for _, d := range dt.desired {
+		if !d.expired {
+			twins = append(twins, copyTwin(d.twin))
+		}
+	}
+
+	return twins
+}
+
+func (tm *TwinManager) pushDesired(edgeID, mapperID, deviceID string, twins []*v1.TwinProperty) {
+	if len(twins) == 0 {
+		return
+	}
+
+	req := types.BuildRequest(edgeID, mapperID, types.MSG_RESOURCE_TWINS, types.MSG_OPS_SET_PROPERTY)
+	req.SetContent(&v1.DeviceDesiredTwinsUpdateMessage{
+		DeviceID:     deviceID,
+		DesiredTwins: twins,
+	})
+
+	syncreq.SendRequestAsync(req, global.DefaultEdgeMaxResponseTime, func(resp *types.Response, err error) {
+		if err != nil {
+			klog.Warningf("set property of %s with err: %v", deviceID, err)
+		}
+	})
+}
+
+func (tm *TwinManager) reconcileLoop() {
+	ticker := time.NewTicker(defaultCheckInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		tm.reconcile(now)
+	}
+}
+
+/*
+* reconcile
+* resend the desired twins which are not converged, mark the expired
+* ones and remove them after the retention.
+ */
+func (tm *TwinManager) reconcile(now time.Time) {
+	type pushTask struct {
+		edgeID, mapperID, deviceID string
+		twins                      []*v1.TwinProperty
+	}
+	tasks := make([]*pushTask, 0)
+	expired := make([]*db.DesiredTwin, 0)
+	removed := make([]*db.DesiredTwin, 0)
+
+	tm.Lock()
+	for deviceID, dt := range tm.devices {
+		retry := false
+		for key, d := range dt.desired {
+			if d.expired {
+				//the expired twin is kept with the error for a while.
+				if now.Sub(d.expireTime()) > defaultExpiredRetention {
+					delete(dt.desired, key)
+					removed = append(removed, d.toModel(deviceID))
+				}
+				continue
+			}
+
+			if now.After(d.expireTime()) {
+				d.expired = true
+				d.twin.ErrorMessage = ErrMsgDesiredExpired
+				expired = append(expired, d.toModel(deviceID))
+				klog.Warningf("desired twin %s of %s expired", key, dt.deviceID)
+				continue
+			}
+			if now.Sub(d.lastPush) >= defaultRetryInterval {
+				d.lastPush = now
+				retry = true
+			}
+		}
+
+		if retry {
+			tasks = append(tasks, &pushTask{
+				edgeID:   dt.edgeID,
+				mapperID: dt.mapperID,
+				deviceID: dt.deviceID,
+				twins:    tm.pendingTwins(dt),
+			})
+		}
+		if len(dt.desired) == 0 {
+			delete(tm.devices, deviceID)
+		}
+	}
+	tm.Unlock()
+
+	for _, twin := range expired {
+		db.SaveDesiredTwinError(twin, ErrMsgDesiredExpired)
+	}
+	for _, twin := range removed {
+		db.DeleteDesiredTwin(twin)
+	}
+
+	for _, t := range tasks {
+		tm.pushDesired(t.edgeID, t.mapperID, t.deviceID, t.twins)
+	}
+}
+
+// Start the default twin manager.
+func Start() {
+	defaultTwinManager.Start()
+}
+
+// SetDesired set the desired value by default twin manager.
+func SetDesired(msg *v1.DesiredPropertyMsg) error {
+	return defaultTwinManager.SetDesired(msg)
+}
+
+// UpdateReported update the reported twins by default twin manager.
+func UpdateReported(deviceID string, twins []*v1.TwinProperty) {
+	defaultTwinManager.UpdateReported(deviceID, twins)
+}
+
+// GetDesiredTwins get the desired twins by default twin manager.
+func GetDesiredTwins(deviceID string) []*v1.TwinProperty {
+	return defaultTwinManager.GetDesiredTwins(deviceID)
+}
+
+// GetDeviceTwin get the device twin by default twin manager.
+func GetDeviceTwin(deviceID string) *v1.DeviceTwin {
+	return defaultTwinManager.GetDeviceTwin(deviceID)
+}
+
+// RemoveDevice remove the device by default twin manager.
+func RemoveDevice(deviceID string) {
+	defaultTwinManager.RemoveDevice(deviceID)
+}
@@ -0,0 +1,321 @@
+package eventdetector
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/common/utils"
+	"k8s.io/klog/v2"
+)
+
+const (
+	//reload the event configs of the device periodically.
+	defaultConfigReloadInterval = 30 * time.Second
+
+	ConditionAnd = "and"
+	ConditionOr  = "or"
+
+	ReportTypeOnce      = "once"
+	ReportTypeContinued = "continued"
+)
+
+type eventState struct {
+	serviceName string
+	eventName   string
+	config      *v1.EventsAccessConfig
+	period      time.Duration
+
+	lastCheck time.Time
+	hits      int
+	reported  bool
+}
+
+type deviceState struct {
+	loadTime time.Time
+	events   []*eventState
+	//the last reported value of the properties.
+	values map[string]interface{}
+}
+
+/*
+* EventDetector
+* evaluate the events access config over the reported twins, so that
+* the devices with dumb edges still get events.
+ */
+type EventDetector struct {
+	sync.Mutex
+	devices map[string]*deviceState
+}
+
+var (
+	defaultDetector = NewEventDetector()
+)
+
+func NewEventDetector() *EventDetector {
+	return &EventDetector{
+		devices: make(map[string]*deviceState),
+	}
+}
+
+func valueKey(svc, prop string) string {
+	return svc + "/" + prop
+}
+
+func toDuration(period int, unit string) time.Duration {
+	d := time.Duration(period)
+	switch strings.ToLower(unit) {
+	case "ms":
+		return d * time.Millisecond
+	case "m":
+		return d * time.Minute
+	case "h":
+		return d * time.Hour
+	default:
+		return d * time.Second
+	}
+}
+
+// loadEvents load all events which have detection rules in this device.
+func loadEvents(deviceID string) []*eventState {
+	events := make([]*eventState, 0)
+
+	sis, err := db.GetServiceInstanceByDeviceID(deviceID)
+	if err != nil {
+		return events
+	}
+
+	for _, si := range sis {
+		for _, ei := range si.EventInstances {
+			if ei == nil || ei.AccessConfig == "" {
+				continue
+			}
+
+			cfg := &v1.EventsAccessConfig{}
+			if err := json.Unmarshal([]byte(ei.AccessConfig), cfg); err != nil {
+				klog.V(4).Infof("event %s/%s has no rules config", si.Name, ei.Name)
+				continue
+			}
+			if len(cfg.Rules) == 0 {
+				continue
+			}
+
+			es := &eventState{
+				serviceName: si.Name,
+				eventName:   ei.Name,
+				config:      cfg,
+			}
+			if cfg.DetectionPeriod != nil && *cfg.DetectionPeriod > 0 {
+				es.period = toDuration(*cfg.DetectionPeriod, cfg.DetectionPeriodUnit)
+			}
+			events = append(events, es)
+		}
+	}
+
+	return events
+}
+
+// needReload indicates whether the events of this device are stale.
+func (ed *EventDetector) needReload(deviceID string, now time.Time) bool {
+	ed.Lock()
+	defer ed.Unlock()
+
+	ds, exist := ed.devices[deviceID]
+	return !exist || now.Sub(ds.loadTime) > defaultConfigReloadInterval
+}
+
+// getDeviceState return the state of this device, the events are replaced if they are reloaded.
+func (ed *EventDetector) getDeviceState(deviceID string, now time.Time, events []*eventState, reloaded bool) *deviceState {
+	ds, exist := ed.devices[deviceID]
+	if !exist {
+		ds = &deviceState{
+			values: make(map[string]interface{}),
+		}
+		ed.devices[deviceID] = ds
+	}
+
+	if reloaded {
+		ds.events = mergeEvents(ds.events, events)
+		ds.loadTime = now
+	}
+
+	return ds
+}
+
+// mergeEvents keep the detection state of the unchanged events.
+func mergeEvents(old, events []*eventState) []*eventState {
+	for _, es := range events {
+		for _, o := range old {
+			if o.serviceName == es.serviceName && o.eventName == es.eventName {
+				es.lastCheck = o.lastCheck
+				es.hits = o.hits
+				es.reported = o.reported
+				break
+			}
+		}
+	}
+
+	return events
+}
+
+/*
+* HandleTwins
+* update the property values and return the detected events, and
+* the reported events whose conditions are cleared.
+ */
+func (ed *EventDetector) HandleTwins(deviceID string, twins []*v1.TwinProperty) ([]*v1.ReportEventMsg, []*v1.ReportEventMsg) {
+	now := time.Now()
+	//load the events from db without blocking the other devices.
+	var events []*eventState
+	reloaded := ed.needReload(deviceID, now)
+	if reloaded {
+		events = loadEvents(deviceID)
+	}
+
+	ed.Lock()
+	defer ed.Unlock()
+
+	ds := ed.getDeviceState(deviceID, now, events, reloaded)
+	for _, twin := range twins {
+		if twin == nil || twin.Value == nil || twin.ErrorMessage != "" {
+			continue
+		}
+		ds.values[valueKey(twin.Service, twin.PropertyName)] = twin.Value
+	}
+
+	msgs := make([]*v1.ReportEventMsg, 0)
+	recovered := make([]*v1.ReportEventMsg, 0)
+	for _, es := range ds.events {
+		if es.period > 0 && now.Sub(es.lastCheck) < es.period {
+			continue
+		}
+		es.lastCheck = now
+
+		matched, details := es.evaluate(ds.values)
+		if !matched {
+			if es.reported {
+				recovered = append(recovered, &v1.ReportEventMsg{
+					DeviceID:    deviceID,
+					ServiceName: es.serviceName,
+					EventName:   es.eventName,
+					Timestamp:   utils.GetNowTimeStamp(),
+				})
+			}
+			es.hits = 0
+			es.reported = false
+			continue
+		}
+
+		es.hits++
+		maintain := 1
+		if es.config.Maintain != nil && *es.config.Maintain > 1 {
+			maintain = *es.config.Maintain
+		}
+		if es.hits < maintain {
+			continue
+		}
+		if es.reported && strings.ToLower(es.config.ReportType) != ReportTypeContinued {
+			continue
+		}
+		es.reported = true
+
+		msgs = append(msgs, &v1.ReportEventMsg{
+			DeviceID:    deviceID,
+			ServiceName: es.serviceName,
+			EventName:   es.eventName,
+			Details:     details,
+			Timestamp:   utils.GetNowTimeStamp(),
+		})
+	}
+
+	return msgs, recovered
+}
+
+// RemoveDevice clear the detection state of this device.
+func (ed *EventDetector) RemoveDevice(deviceID string) {
+	ed.Lock()
+	delete(ed.devices, deviceID)
+	ed.Unlock()
+}
+
+/*
+* evaluate
+* check the rules by the condition, return the matched property values.
+ */
+func (es *eventState) evaluate(values map[string]interface{}) (bool, string) {
+	isOr := strings.ToLower(es.config.Condition) == ConditionOr
+	matchedValues := make(map[string]interface{})
+
+	result := !isOr
+	checked := 0
+	for _, rule := range es.config.Rules {
+		if rule == nil || rule.Relation == nil {
+			continue
+		}
+		checked++
+
+		key := valueKey(rule.ServiceName, rule.PropertyName)
+		val, exist := values[key]
+		hit := exist && compare(val, int32(*rule.Relation), rule.Value)
+		if hit {
+			matchedValues[key] = val
+		}
+
+		if isOr && hit {
+			result = true
+		}
+		if !isOr && !hit {
+			return false, ""
+		}
+	}
+	//nothing is matched if all the rules are invalid.
+	if !result || checked == 0 {
+		return false, ""
+	}
+
+	d, _ := json.Marshal(matchedValues)
+	return true, string(d)
+}
+
+func compare(val interface{}, relation int32, target string) bool {
+	str := utils.ToString(val)
+
+	v, err1 := strconv.ParseFloat(str, 64)
+	t, err2 := strconv.ParseFloat(strings.TrimSpace(target), 64)
+	if err1 == nil && err2 == nil {
+		switch relation {
+		case v1.EventMoreRelation:
+			return v > t
+		case v1.EventLessRelation:
+			return v < t
+		case v1.EventEqualRelation:
+			return v == t
+		case v1.EventNotEqualRelation:
+			return v != t
+		}
+		return false
+	}
+
+	switch relation {
+	case v1.EventEqualRelation:
+		return str == target
+	case v1.EventNotEqualRelation:
+		return str != target
+	}
+
+	return false
+}
+
+// HandleTwins detect the events by the default detector.
+func HandleTwins(deviceID string, twins []*v1.TwinProperty) ([]*v1.ReportEventMsg, []*v1.ReportEventMsg) {
+	return defaultDetector.HandleTwins(deviceID, twins)
+}
+
+// RemoveDevice remove the device from the default detector.
+func RemoveDevice(deviceID string) {
+	defaultDetector.RemoveDevice(deviceID)
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"github.com/edgehook/ithings/common/global"
+	"github.com/edgehook/ithings/common/grp"
+	"github.com/edgehook/ithings/common/types"
+	"github.com/edgehook/ithings/common/utils"
+	"github.com/edgehook/ithings/core/syncreq"
+	"github.com/jwzl/wssocket/model"
+	"k8s.io/klog/v2"
+)
+
+var (
+	defaultICore *ICore
+)
+
+type ICore struct {
+	Pool *grp.GoRoutinePool
+}
+
+func NewICore() *ICore {
+	pool := grp.NewGoRoutinePool(global.DefaultMaxGoRoutines)
+	if pool == nil {
+		return nil
+	}
+
+	return &ICore{
+		Pool: pool,
+	}
+}
+
+func (ic *ICore) Close() {
+	ic.Pool.Close()
+}
+
+/*
+* HandleMessage
+* dispatch the edge message by the operation.
+ */
+func (ic *ICore) HandleMessage(msg *model.Message) {
+	if msg.GetOperation() != "do_edge_msg" {
+		klog.Warningf("core ignore the operation %s", msg.GetOperation())
+		return
+	}
+
+	imsg, ok := msg.GetContent().(*types.IMessage)
+	if !ok || imsg == nil {
+		klog.Warningf("core ignore the invalid message content")
+		return
+	}
+
+	err := ic.Pool.Run(func() {
+		if imsg.Req != nil {
+			ic.handleRequest(imsg.Req)
+		}
+		if imsg.Resp != nil {
+			if !syncreq.MatchResponse(imsg.Resp) {
+				klog.V(4).Infof("no waiter for the response %s", imsg.Resp.Payload.ParentID)
+			}
+		}
+	})
+	if err != nil {
+		klog.Errorf("Drop the edge message with err: %v", err)
+	}
+}
+
+func (ic *ICore) handleRequest(req *types.Request) {
+	switch req.Operation {
+	case types.MSG_OPS_REGISTER:
+		ic.handleRegister(req)
+	case types.MSG_OPS_REPORT:
+		ic.handleReport(req)
+	case types.MSG_OPS_FETCH:
+		ic.handleFetch(req)
+	case types.MSG_OPS_LIFE_CONTROL:
+		ic.handleLifeControl(req)
+	default:
+		klog.Warningf("unsupported operation %s from edge %s", req.Operation, req.EdgeID)
+		utils.SendResponse2Edge(req, global.IRespCodeInvalidMsg, global.IRespInvalidMsgString)
+	}
+}
@@ -0,0 +1,246 @@
+package syncreq
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/edgehook/ithings/common/global"
+	"github.com/edgehook/ithings/common/types"
+	"github.com/edgehook/ithings/common/utils"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultGCInterval = time.Second
+)
+
+// ResponseCallback is called when the response arrived or the request timeout.
+type ResponseCallback func(resp *types.Response, err error)
+
+type pendingRequest struct {
+	//only the edge which the request is sent to can response it.
+	edgeID   string
+	deadline time.Time
+	respCh   chan *types.Response
+	//just for async request.
+	callback ResponseCallback
+}
+
+/*
+* SyncRequestManager
+* track the outstanding requests which are sent to the edge, and match
+* the response by the parent id.
+ */
+type SyncRequestManager struct {
+	sync.Mutex
+	pending map[string]*pendingRequest
+	gcOnce  sync.Once
+}
+
+var (
+	defaultManager = NewSyncRequestManager()
+)
+
+func NewSyncRequestManager() *SyncRequestManager {
+	return &SyncRequestManager{
+		pending: make(map[string]*pendingRequest),
+	}
+}
+
+/*
+* SendRequest
+* send the request to edge and wait for the response until timeout.
+ */
+func (m *SyncRequestManager) SendRequest(req *types.Request, timeout time.Duration) (*types.Response, error) {
+	if req == nil {
+		return nil, global.ErrInvalidParms
+	}
+	if timeout <= 0 {
+		timeout = global.DefaultEdgeMaxResponseTime
+	}
+
+	id := req.GetMessageID()
+	pr := &pendingRequest{
+		edgeID:   req.EdgeID,
+		deadline: time.Now().Add(timeout),
+		respCh:   make(chan *types.Response, 1),
+	}
+	m.add(id, pr)
+
+	utils.SendRequest2Edge(req)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case resp := <-pr.respCh:
+		return resp, CodeToError(resp)
+	case <-timer.C:
+		m.remove(id)
+		return nil, global.ErrEdgeIsNotOnline
+	}
+}
+
+/*
+* SendRequestAsync
+* send the request to edge, the callback will be called when the response
+* arrived or the request timeout.
+ */
+func (m *SyncRequestManager) SendRequestAsync(req *types.Request, timeout time.Duration, cb ResponseCallback) {
+	if req == nil {
+		return
+	}
+	if timeout <= 0 {
+		timeout = global.DefaultEdgeMaxResponseTime
+	}
+
+	if cb != nil {
+		m.add(req.GetMessageID(), &pendingRequest{
+			edgeID:   req.EdgeID,
+			deadline: time.Now().Add(timeout),
+			callback: cb,
+		})
+	}
+
+	utils.SendRequest2Edge(req)
+}
+
+/*
+* MatchResponse
+* deliver the response to the waiter, return false if nobody waits for it.
+ */
+func (m *SyncRequestManager) MatchResponse(resp *types.Response) bool {
+	if resp == nil {
+		return false
+	}
+
+	pr := m.removeByEdge(resp.Payload.ParentID, resp.EdgeID)
+	if pr == nil {
+		return false
+	}
+
+	if pr.callback != nil {
+		go pr.callback(resp, CodeToError(resp))
+		return true
+	}
+
+	pr.respCh <- resp
+	return true
+}
+
+func (m *SyncRequestManager) add(id string, pr *pendingRequest) {
+	m.gcOnce.Do(func() {
+		go m.gc()
+	})
+
+	m.Lock()
+	m.pending[id] = pr
+	m.Unlock()
+}
+
+func (m *SyncRequestManager) remove(id string) *pendingRequest {
+	m.Lock()
+	defer m.Unlock()
+
+	pr, exist := m.pending[id]
+	if !exist {
+		return nil
+	}
+	delete(m.pending, id)
+
+	return pr
+}
+
+// removeByEdge remove the request only if it's sent to this edge.
+func (m *SyncRequestManager) removeByEdge(id, edgeID string) *pendingRequest {
+	m.Lock()
+	defer m.Unlock()
+
+	pr, exist := m.pending[id]
+	if !exist {
+		return nil
+	}
+	if pr.edgeID != edgeID {
+		klog.Warningf("edge %s responses the request %s of edge %s", edgeID, id, pr.edgeID)
+		return nil
+	}
+	delete(m.pending, id)
+
+	return pr
+}
+
+// gc remove all expired requests.
+func (m *SyncRequestManager) gc() {
+	ticker := time.NewTicker(defaultGCInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		expired := make([]*pendingRequest, 0)
+
+		m.Lock()
+		for id, pr := range m.pending {
+			if now.After(pr.deadline) {
+				delete(m.pending, id)
+				expired = append(expired, pr)
+			}
+		}
+		m.Unlock()
+
+		for _, pr := range expired {
+			if pr.callback != nil {
+				pr.callback(nil, global.ErrEdgeIsNotOnline)
+			}
+		}
+		if len(expired) > 0 {
+			klog.V(4).Infof("%d requests timeout", len(expired))
+		}
+	}
+}
+
+/*
+* CodeToError
+* map the response code to the global errors.
+ */
+func CodeToError(resp *types.Response) error {
+	if resp == nil {
+		return global.ErrInvalidResponseStruct
+	}
+
+	switch resp.Payload.Code {
+	case global.IRespCodeOk:
+		return nil
+	case global.IRespCodeInvalidMsg:
+		return global.ErrInvalidParms
+	case global.IRespCodeInternalError:
+		return global.ErrUnknown
+	case global.IRespCodeNoSuchDevice:
+		return global.ErrNoSuchDevice
+	case global.IRespCodeDeviceOffline:
+		return global.ErrDeviceIsOffline
+	case global.IRespCodeDevNotActive:
+		return global.ErrDeviceIsNotActive
+	case global.IRespCodeError:
+		if resp.Payload.Content != "" {
+			return errors.New(resp.Payload.Content)
+		}
+		return global.ErrUnknown
+	default:
+		return global.ErrInvalidResponseStruct
+	}
+}
+
+// SendRequest send the request by default manager.
+func SendRequest(req *types.Request, timeout time.Duration) (*types.Response, error) {
+	return defaultManager.SendRequest(req, timeout)
+}
+
+// SendRequestAsync send the async request by default manager.
+func SendRequestAsync(req *types.Request, timeout time.Duration, cb ResponseCallback) {
+	defaultManager.SendRequestAsync(req, timeout, cb)
+}
+
+// MatchResponse match the response by default manager.
+func MatchResponse(resp *types.Response) bool {
+	return defaultManager.MatchResponse(resp)
+}
@@ -0,0 +1,326 @@
+package twincache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/tsdbm"
+	"github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/common/utils"
+	"k8s.io/klog/v2"
+)
+
+const (
+	//the notifications are dropped if the subscriber is too slow.
+	defaultSubscriberQueueSize = 1024
+)
+
+// SubscriberStats is the queue state of a subscriber.
+type SubscriberStats struct {
+	Name    string `json:"name"`
+	Queued  int    `json:"queued"`
+	Dropped uint64 `json:"dropped"`
+}
+
+// TwinChange is the property whose value is changed.
+type TwinChange struct {
+	Previous *v1.TwinProperty
+	Current  *v1.TwinProperty
+}
+
+// TwinNotification notify the subscribers the reported twins of the device.
+type TwinNotification struct {
+	DeviceID string
+	//all reported twins of this report.
+	Twins []*v1.TwinProperty
+	//the twins whose value is changed.
+	Changes []*TwinChange
+}
+
+type subscriber struct {
+	//the dropped notifications, keep it first for the 64-bit atomic alignment.
+	dropped uint64
+	name    string
+	handler func(*TwinNotification)
+	queue   chan *TwinNotification
+	stop    chan struct{}
+}
+
+func (s *subscriber) run() {
+	for {
+		select {
+		case n := <-s.queue:
+			s.handler(n)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+/*
+* TwinCache
+* keep the last known value of the device properties, it's updated by
+* the reports and warmed from the time series store at startup.
+ */
+type TwinCache struct {
+	sync.RWMutex
+	devices map[string]map[string]*v1.TwinProperty
+
+	subMutex    sync.RWMutex
+	subscribers map[string]*subscriber
+	once        sync.Once
+}
+
+var (
+	defaultTwinCache = NewTwinCache()
+)
+
+func NewTwinCache() *TwinCache {
+	return &TwinCache{
+		devices:     make(map[string]map[string]*v1.TwinProperty),
+		subscribers: make(map[string]*subscriber),
+	}
+}
+
+func twinKey(svc, prop string) string {
+	return svc + "/" + prop
+}
+
+// Start warm the cache from the time series store.
+func (tc *TwinCache) Start() {
+	tc.once.Do(func() {
+		go tc.warm()
+	})
+}
+
+func (tc *TwinCache) warm() {
+	dis, err := db.GetDeviceInstances()
+	if err != nil {
+		return
+	}
+
+	count := 0
+	for _, di := range dis {
+		if di == nil || di.DeviceID == "" {
+			continue
+		}
+
+		twins := tsdbm.QueryLatestTwins(di.DeviceID)
+		tc.Lock()
+		tc.merge(di.DeviceID, twins)
+		tc.Unlock()
+		count += len(twins)
+	}
+
+	klog.Infof("twin cache is warmed with %d twins of %d devices", count, len(dis))
+}
+
+/*
+* merge
+* keep the newer twins, return the changed ones. The twins without value
+* only update the error message of the cached twin.
+ */
+func (tc *TwinCache) merge(deviceID string, twins []*v1.TwinProperty) []*TwinChange {
+	cached, exist := tc.devices[deviceID]
+	if !exist {
+		cached = make(map[string]*v1.TwinProperty)
+		tc.devices[deviceID] = cached
+	}
+
+	changes := make([]*TwinChange, 0)
+	for _, twin := range twins {
+		if twin == nil {
+			continue
+		}
+
+		key := twinKey(twin.Service, twin.PropertyName)
+		prev, exist := cached[key]
+		if exist && twin.Timestamp != 0 && twin.Timestamp < prev.Timestamp {
+			continue
+		}
+		if twin.Value == nil {
+			if exist {
+				updated := *prev
+				updated.ErrorMessage = twin.ErrorMessage
+				cached[key] = &updated
+			}
+			continue
+		}
+
+		current := *twin
+		cached[key] = &current
+		if !exist || utils.ToString(prev.Value) != utils.ToString(current.Value) {
+			changes = append(changes, &TwinChange{
+				Previous: prev,
+				Current:  &current,
+			})
+		}
+	}
+
+	return changes
+}
+
+// Update update the cache by the reported twins and notify the subscribers.
+func (tc *TwinCache) Update(deviceID string, twins []*v1.TwinProperty) {
+	tc.Lock()
+	changes := tc.merge(deviceID, twins)
+	tc.Unlock()
+
+	tc.publish(&TwinNotification{
+		DeviceID: deviceID,
+		Twins:    twins,
+		Changes:  changes,
+	})
+}
+
+// GetTwins return the cached twins of this device.
+func (tc *TwinCache) GetTwins(deviceID string) []*v1.TwinProperty {
+	tc.RLock()
+	defer tc.RUnlock()
+
+	twins := make([]*v1.TwinProperty, 0)
+	for _, twin := range tc.devices[deviceID] {
+		t := *twin
+		twins = append(twins, &t)
+	}
+
+	return twins
+}
+
+// GetTwin return the cached twin of this property, nil if it's not cached.
+func (tc *TwinCache) GetTwin(deviceID, svc, prop string) *v1.TwinProperty {
+	tc.RLock()
+	defer tc.RUnlock()
+
+	twin, exist := tc.devices[deviceID][twinKey(svc, prop)]
+	if !exist {
+		return nil
+	}
+	t := *twin
+
+	return &t
+}
+
+// RemoveDevice clear the cached twins of this device.
+func (tc *TwinCache) RemoveDevice(deviceID string) {
+	tc.Lock()
+	delete(tc.devices, deviceID)
+	tc.Unlock()
+}
+
+/*
+* Subscribe
+* the handler is called in the order of the reports by its own goroutine,
+* the subscriber with the same name is replaced. The notifications are
+* best effort, the critical consumers are called by the report path.
+ */
+func (tc *TwinCache) Subscribe(name string, handler func(*TwinNotification)) {
+	s := &subscriber{
+		name:    name,
+		handler: handler,
+		queue:   make(chan *TwinNotification, defaultSubscriberQueueSize),
+		stop:    make(chan struct{}),
+	}
+
+	tc.subMutex.Lock()
+	if old, exist := tc.subscribers[name]; exist {
+		close(old.stop)
+	}
+	tc.subscribers[name] = s
+	tc.subMutex.Unlock()
+
+	go s.run()
+}
+
+// Unsubscribe stop notifying the subscriber.
+func (tc *TwinCache) Unsubscribe(name string) {
+	tc.subMutex.Lock()
+	if s, exist := tc.subscribers[name]; exist {
+		close(s.stop)
+		delete(tc.subscribers, name)
+	}
+	tc.subMutex.Unlock()
+}
+
+/*
+* publish
+* queue the notification to all subscribers without blocking the report,
+* the notification is dropped and counted if the queue of a subscriber is
+* full. The subscribers must not rely on every notification.
+ */
+func (tc *TwinCache) publish(n *TwinNotification) {
+	tc.subMutex.RLock()
+	defer tc.subMutex.RUnlock()
+
+	for _, s := range tc.subscribers {
+		select {
+		case s.queue <- n:
+		default:
+			dropped := atomic.AddUint64(&s.dropped, 1)
+			klog.Warningf("subscriber %s is too slow, drop the twins of %s, %d dropped",
+				s.name, n.DeviceID, dropped)
+		}
+	}
+}
+
+// Stats return the queue state of the subscribers.
+func (tc *TwinCache) Stats() []*SubscriberStats {
+	tc.subMutex.RLock()
+	defer tc.subMutex.RUnlock()
+
+	stats := make([]*SubscriberStats, 0, len(tc.subscribers))
+	for _, s := range tc.subscribers {
+		stats = append(stats, &SubscriberStats{
+			Name:    s.name,
+			Queued:  len(s.queue),
+			Dropped: atomic.LoadUint64(&s.dropped),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+
+	return stats
+}
+
+// Start warm the default twin cache.
+func Start() {
+	defaultTwinCache.Start()
+}
+
+// Update update the default twin cache.
+func Update(deviceID string, twins []*v1.TwinProperty) {
+	defaultTwinCache.Update(deviceID, twins)
+}
+
+// GetTwins get the cached twins from the default twin cache.
+func GetTwins(deviceID string) []*v1.TwinProperty {
+	return defaultTwinCache.GetTwins(deviceID)
+}
+
+// GetTwin get the cached twin from the default twin cache.
+func GetTwin(deviceID, svc, prop string) *v1.TwinProperty {
+	return defaultTwinCache.GetTwin(deviceID, svc, prop)
+}
+
+// RemoveDevice remove the device from the default twin cache.
+func RemoveDevice(deviceID string) {
+	defaultTwinCache.RemoveDevice(deviceID)
+}
+
+// Subscribe subscribe the notifications of the default twin cache.
+func Subscribe(name string, handler func(*TwinNotification)) {
+	defaultTwinCache.Subscribe(name, handler)
+}
+
+// Stats return the subscriber state of the default twin cache.
+func Stats() []*SubscriberStats {
+	return defaultTwinCache.Stats()
+}
+
+// Unsubscribe unsubscribe the notifications of the default twin cache.
+func Unsubscribe(name string) {
+	defaultTwinCache.Unsubscribe(name)
+}
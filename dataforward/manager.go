@@ -0,0 +1,309 @@
+package dataforward
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/edgehook/ithings/common/config"
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/grp"
+	"github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/common/utils"
+	"k8s.io/klog/v2"
+)
+
+const (
+	//reload the data forward rules from database periodically.
+	defaultRuleReloadInterval = 10 * time.Second
+	defaultMaxGoRoutines      = 128
+)
+
+type dataForward struct {
+	rule        *db.DataForward
+	sources     []*v1.DataForwardSource
+	destination *v1.DataForwardDestination
+}
+
+/*
+* DataForwardManager
+* forward the reported twins and events to the destinations of the
+* matched data forward rules.
+ */
+type DataForwardManager struct {
+	sync.RWMutex
+	rules    []*dataForward
+	loadTime time.Time
+
+	pool       *grp.GoRoutinePool
+	publishers *PublisherPool
+
+	//the retry config and the backoff of each data forward.
+	cfg         *config.DataForwardConfig
+	backoffLock sync.Mutex
+	backoffs    map[string]*ruleBackoff
+	retrying    map[string]bool
+}
+
+var (
+	defaultManager *DataForwardManager
+	managerOnce    sync.Once
+)
+
+func getManager() *DataForwardManager {
+	managerOnce.Do(func() {
+		defaultManager = &DataForwardManager{
+			pool:       grp.NewGoRoutinePool(defaultMaxGoRoutines),
+			publishers: NewPublisherPool(),
+			cfg:        config.GetDataForwardConfig(),
+			backoffs:   make(map[string]*ruleBackoff),
+			retrying:   make(map[string]bool),
+		}
+		go defaultManager.retryLoop()
+	})
+
+	return defaultManager
+}
+
+func parseDataForward(rule *db.DataForward) (*dataForward, error) {
+	df := &dataForward{
+		rule:        rule,
+		destination: &v1.DataForwardDestination{},
+	}
+
+	if rule.Source != "" {
+		if err := json.Unmarshal([]byte(rule.Source), &df.sources); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal([]byte(rule.Destination), df.destination); err != nil {
+		return nil, err
+	}
+
+	return df, nil
+}
+
+// Reload load all enabled data forward rules from database.
+func (m *DataForwardManager) Reload() {
+	rules, err := db.GetDataForward()
+	if err != nil {
+		return
+	}
+
+	dfs := make([]*dataForward, 0)
+	for _, rule := range rules {
+		if rule == nil || rule.Status != v1.StatusEnable {
+			continue
+		}
+
+		df, err := parseDataForward(rule)
+		if err != nil {
+			klog.Warningf("ignore the invalid data forward %s: %v", rule.Name, err)
+			continue
+		}
+		dfs = append(dfs, df)
+	}
+
+	m.Lock()
+	m.rules = dfs
+	m.loadTime = time.Now()
+	m.Unlock()
+}
+
+// getRule get the enabled data forward by id.
+func (m *DataForwardManager) getRule(id string) *dataForward {
+	for _, df := range m.getRules() {
+		if df.rule.ID == id {
+			return df
+		}
+	}
+
+	return nil
+}
+
+func (m *DataForwardManager) getRules() []*dataForward {
+	m.RLock()
+	expired := time.Since(m.loadTime) > defaultRuleReloadInterval
+	m.RUnlock()
+
+	if expired {
+		m.Reload()
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+	return m.rules
+}
+
+/*
+* matchRules
+* find the data forward rules of this device, the device matches a rule
+* by the model/edge source, or by the device forward relation.
+ */
+func (m *DataForwardManager) matchRules(di *db.DeviceInstance) []*dataForward {
+	related := make(map[string]bool)
+	relations, err := db.GetDeviceDataForwardRelationByDeviceId(di.DeviceID)
+	if err == nil {
+		for _, r := range relations {
+			related[r.DataForwardId] = true
+		}
+	}
+
+	matched := make([]*dataForward, 0)
+	for _, df := range m.getRules() {
+		if related[df.rule.ID] || df.matchSource(di) {
+			matched = append(matched, df)
+		}
+	}
+
+	return matched
+}
+
+func (df *dataForward) matchSource(di *db.DeviceInstance) bool {
+	for _, src := range df.sources {
+		if src == nil {
+			continue
+		}
+
+		switch src.Type {
+		case v1.SOURCETYPEMODEL:
+			if src.ModelId == nil || *src.ModelId != di.DeviceModelId {
+				continue
+			}
+			if src.DeviceId != "" && src.DeviceId != di.DeviceID {
+				continue
+			}
+			return true
+		case v1.SOURCETYPEEDGE:
+			if src.EdgeId != "" && src.EdgeId == di.EdgeID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (m *DataForwardManager) forward(deviceID string, build func(di *db.DeviceInstance) *v1.ForwardMessage) {
+	di, err := db.GetDeviceInstanceByDeviceId(deviceID)
+	if err != nil {
+		return
+	}
+
+	rules := m.matchRules(&di)
+	if len(rules) == 0 {
+		return
+	}
+
+	msg := build(&di)
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		klog.Errorf("json Marshal with err %v", err)
+		return
+	}
+
+	for _, df := range rules {
+		rule := df
+		err := m.pool.Run(func() {
+			m.publish(rule, msg, payload)
+		})
+		if err != nil {
+			klog.Errorf("forward to %s with err: %v", df.rule.Name, err)
+		}
+	}
+}
+
+// send publish the message to the destination of this data forward.
+func (m *DataForwardManager) send(df *dataForward, msg *v1.ForwardMessage, payload []byte) error {
+	p, err := m.publishers.Get(df.destination)
+	if err != nil {
+		return err
+	}
+
+	err = p.Publish(msg, payload)
+	if err != nil {
+		//the connection may be broken, recreate it next time.
+		m.publishers.Invalidate(df.destination)
+	}
+
+	return err
+}
+
+func (m *DataForwardManager) publish(df *dataForward, msg *v1.ForwardMessage, payload []byte) {
+	logID := startDataForwardLog(df, payload)
+
+	//queue the message while the destination is unavailable.
+	if m.isBackingOff(df.rule.ID) {
+		m.enqueue(df, msg, payload, logID, 0, ErrDestinationBackingOff)
+		return
+	}
+
+	err := m.send(df, msg, payload)
+	if err != nil {
+		klog.Warningf("forward %s to %s with err: %v", msg.DeviceID, df.rule.Name, err)
+		m.backoff(df.rule.ID)
+		m.enqueue(df, msg, payload, logID, 1, err)
+		return
+	}
+
+	m.resetBackoff(df.rule.ID)
+	finishDataForwardLog(logID, nil)
+}
+
+// ForwardTwins forward the reported twins of this device.
+func (m *DataForwardManager) ForwardTwins(deviceID string, twins []*v1.TwinProperty) {
+	if len(twins) == 0 {
+		return
+	}
+
+	m.forward(deviceID, func(di *db.DeviceInstance) *v1.ForwardMessage {
+		return &v1.ForwardMessage{
+			Type:       v1.ForwardTypeTwins,
+			EdgeID:     di.EdgeID,
+			DeviceID:   di.DeviceID,
+			DeviceName: di.Name,
+			ModelID:    di.DeviceModelId,
+			Timestamp:  utils.GetNowTimeStamp(),
+			Twins:      twins,
+		}
+	})
+}
+
+// ForwardEvent forward the event of this device.
+func (m *DataForwardManager) ForwardEvent(event *v1.ReportEventMsg) {
+	if event == nil {
+		return
+	}
+
+	m.forward(event.DeviceID, func(di *db.DeviceInstance) *v1.ForwardMessage {
+		return &v1.ForwardMessage{
+			Type:       v1.ForwardTypeEvent,
+			EdgeID:     di.EdgeID,
+			DeviceID:   di.DeviceID,
+			DeviceName: di.Name,
+			ModelID:    di.DeviceModelId,
+			Timestamp:  utils.GetNowTimeStamp(),
+			Event:      event,
+		}
+	})
+}
+
+// Reload reload the rules of the default manager.
+func Reload() {
+	getManager().Reload()
+}
+
+// ForwardTwins forward the twins by the default manager.
+func ForwardTwins(deviceID string, twins []*v1.TwinProperty) {
+	getManager().ForwardTwins(deviceID, twins)
+}
+
+// ForwardEvent forward the event by the default manager.
+func ForwardEvent(event *v1.ReportEventMsg) {
+	getManager().ForwardEvent(event)
+}
+
+// Start start the default manager to retry the messages in the outbox.
+func Start() {
+	getManager()
+}
@@ -0,0 +1,268 @@
+package dataforward
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/common/utils"
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultRetryInterval  = time.Second
+	defaultRetryBatchSize = 100
+)
+
+var (
+	ErrDestinationBackingOff = errors.New("destination is unavailable, queued for retry")
+	ErrDataForwardNotEnabled = errors.New("data forward is not enabled")
+	ErrDeadLetterReplaying   = errors.New("dead letter is being replayed")
+)
+
+// the backoff of a data forward after its destination failed.
+type ruleBackoff struct {
+	failures int
+	until    time.Time
+}
+
+func (m *DataForwardManager) isBackingOff(id string) bool {
+	m.backoffLock.Lock()
+	defer m.backoffLock.Unlock()
+
+	b, exist := m.backoffs[id]
+	return exist && time.Now().Before(b.until)
+}
+
+// backoff double the waiting time of this data forward until MaxBackoff.
+func (m *DataForwardManager) backoff(id string) {
+	m.backoffLock.Lock()
+	defer m.backoffLock.Unlock()
+
+	b, exist := m.backoffs[id]
+	if !exist {
+		b = &ruleBackoff{}
+		m.backoffs[id] = b
+	}
+	b.failures++
+
+	delay := m.cfg.InitialBackoff
+	for i := 1; i < b.failures && delay < m.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > m.cfg.MaxBackoff {
+		delay = m.cfg.MaxBackoff
+	}
+	b.until = time.Now().Add(delay)
+}
+
+func (m *DataForwardManager) resetBackoff(id string) {
+	m.backoffLock.Lock()
+	defer m.backoffLock.Unlock()
+
+	delete(m.backoffs, id)
+}
+
+// enqueue store the failed message into the outbox.
+func (m *DataForwardManager) enqueue(df *dataForward, msg *v1.ForwardMessage, payload []byte,
+	logID string, attempts int, cause error) {
+	err := db.AddDataForwardOutbox(&db.DataForwardOutbox{
+		ID:            utils.NewUUID(),
+		DataForwardId: df.rule.ID,
+		Name:          df.rule.Name,
+		DeviceId:      msg.DeviceID,
+		Payload:       string(payload),
+		Attempts:      attempts,
+		Error:         cause.Error(),
+		LogId:         logID,
+	})
+	if err != nil {
+		//the message is lost.
+		finishDataForwardLog(logID, cause)
+		return
+	}
+
+	finishDataForwardLog(logID, fmt.Errorf("attempt %d/%d: %v", attempts, m.cfg.MaxAttempts, cause))
+}
+
+/*
+* retryLoop
+* retry the messages in the outbox of the data forwards which are
+* not backing off.
+ */
+func (m *DataForwardManager) retryLoop() {
+	ticker := time.NewTicker(defaultRetryInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		//the messages of the disabled data forwards wait until they are enabled.
+		ids, err := db.GetDataForwardOutboxForwardIds(v1.StatusEnable)
+		if err != nil {
+			continue
+		}
+
+		for _, id := range ids {
+			if m.isBackingOff(id) || !m.startRetrying(id) {
+				continue
+			}
+
+			forwardID := id
+			err := m.pool.Run(func() {
+				defer m.stopRetrying(forwardID)
+				m.retryOutbox(forwardID)
+			})
+			if err != nil {
+				m.stopRetrying(id)
+			}
+		}
+	}
+}
+
+func (m *DataForwardManager) startRetrying(id string) bool {
+	m.backoffLock.Lock()
+	defer m.backoffLock.Unlock()
+
+	if m.retrying[id] {
+		return false
+	}
+	m.retrying[id] = true
+
+	return true
+}
+
+func (m *DataForwardManager) stopRetrying(id string) {
+	m.backoffLock.Lock()
+	defer m.backoffLock.Unlock()
+
+	delete(m.retrying, id)
+}
+
+// retryOutbox resend the messages of this data forward in order.
+func (m *DataForwardManager) retryOutbox(id string) {
+	df := m.getRule(id)
+	if df == nil {
+		//the data forward is deleted, nobody will forward these messages.
+		if _, err := db.GetDataForwardById(id); errors.Is(err, gorm.ErrRecordNotFound) {
+			m.deadLetterAll(id)
+		}
+		return
+	}
+
+	outboxes, err := db.GetDataForwardOutboxByDataForwardId(id, defaultRetryBatchSize)
+	if err != nil {
+		return
+	}
+
+	for _, outbox := range outboxes {
+		msg := &v1.ForwardMessage{}
+		if err := json.Unmarshal([]byte(outbox.Payload), msg); err != nil {
+			outbox.Error = err.Error()
+			if m.deadLetter(outbox) != nil {
+				return
+			}
+			continue
+		}
+
+		err := m.send(df, msg, []byte(outbox.Payload))
+		if err == nil {
+			m.resetBackoff(id)
+			db.DeleteDataForwardOutbox(outbox.ID)
+			finishDataForwardLog(outbox.LogId, nil)
+			continue
+		}
+
+		klog.Warningf("retry forward %s to %s with err: %v", outbox.DeviceId, df.rule.Name, err)
+		m.backoff(id)
+		outbox.Attempts++
+		outbox.Error = err.Error()
+		if outbox.Attempts >= m.cfg.MaxAttempts {
+			m.deadLetter(outbox)
+		} else {
+			db.SaveDataForwardOutboxAttempts(outbox.ID, outbox.Attempts, outbox.Error)
+			finishDataForwardLog(outbox.LogId, fmt.Errorf("attempt %d/%d: %s",
+				outbox.Attempts, m.cfg.MaxAttempts, outbox.Error))
+		}
+
+		//wait for the backoff before the next message.
+		return
+	}
+}
+
+func (m *DataForwardManager) deadLetter(outbox *db.DataForwardOutbox) error {
+	if err := db.MoveDataForwardOutboxToDeadLetter(outbox); err != nil {
+		return err
+	}
+
+	finishDataForwardLog(outbox.LogId, fmt.Errorf("dead letter after %d attempts: %s",
+		outbox.Attempts, outbox.Error))
+	return nil
+}
+
+// deadLetterAll move all the messages of this data forward into dead letter,
+// it gives up on the first failure and leaves the rest for the next retry.
+func (m *DataForwardManager) deadLetterAll(id string) {
+	for {
+		outboxes, err := db.GetDataForwardOutboxByDataForwardId(id, defaultRetryBatchSize)
+		if err != nil || len(outboxes) == 0 {
+			return
+		}
+
+		for _, outbox := range outboxes {
+			outbox.Error = "data forward is deleted"
+			if err := m.deadLetter(outbox); err != nil {
+				klog.Errorf("move the outbox of %s into dead letter with err: %v", id, err)
+				return
+			}
+		}
+	}
+}
+
+/*
+* ReplayDeadLetter
+* resend the dead letter to the destination of its data forward, the
+* dead letter is removed after it's forwarded successfully.
+ */
+func (m *DataForwardManager) ReplayDeadLetter(id string) error {
+	//the dead letters share the retrying guard with the data forwards.
+	key := "deadletter/" + id
+	if !m.startRetrying(key) {
+		return ErrDeadLetterReplaying
+	}
+	defer m.stopRetrying(key)
+
+	deadLetter, err := db.GetDataForwardDeadLetterById(id)
+	if err != nil {
+		return err
+	}
+
+	df := m.getRule(deadLetter.DataForwardId)
+	if df == nil {
+		return ErrDataForwardNotEnabled
+	}
+
+	msg := &v1.ForwardMessage{}
+	if err := json.Unmarshal([]byte(deadLetter.Payload), msg); err != nil {
+		return err
+	}
+
+	payload := []byte(deadLetter.Payload)
+	logID := startDataForwardLog(df, payload)
+	err = m.send(df, msg, payload)
+	finishDataForwardLog(logID, err)
+	if err != nil {
+		db.SaveDataForwardDeadLetterAttempts(id, deadLetter.Attempts+1, err.Error())
+		return err
+	}
+
+	m.resetBackoff(df.rule.ID)
+	return db.DeleteDataForwardDeadLetter(id)
+}
+
+// ReplayDeadLetter replay the dead letter by the default manager.
+func ReplayDeadLetter(id string) error {
+	return getManager().ReplayDeadLetter(id)
+}
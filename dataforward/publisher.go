@@ -0,0 +1,274 @@
+package dataforward
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/common/utils"
+	"github.com/streadway/amqp"
+)
+
+const (
+	defaultPublishTimeout  = 5 * time.Second
+	defaultMqttTopicPrefix = "ithings/forward/"
+)
+
+// Publisher publish the forward message to the destination.
+type Publisher interface {
+	Publish(msg *v1.ForwardMessage, payload []byte) error
+	Close()
+}
+
+/*
+* PublisherPool
+* the connections to the destinations are reused, keyed by the destination.
+ */
+type PublisherPool struct {
+	sync.Mutex
+	publishers map[string]Publisher
+}
+
+func NewPublisherPool() *PublisherPool {
+	return &PublisherPool{
+		publishers: make(map[string]Publisher),
+	}
+}
+
+func destinationKey(dest *v1.DataForwardDestination) string {
+	d, _ := json.Marshal(dest)
+	return string(d)
+}
+
+/*
+* Get
+* get or create the publisher of this destination, the connection is
+* dialed without the lock so that an unreachable destination doesn't
+* block the others.
+ */
+func (pp *PublisherPool) Get(dest *v1.DataForwardDestination) (Publisher, error) {
+	key := destinationKey(dest)
+
+	pp.Lock()
+	p, exist := pp.publishers[key]
+	pp.Unlock()
+	if exist {
+		return p, nil
+	}
+
+	p, err := newPublisher(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	pp.Lock()
+	//the other caller created it while we are dialing.
+	if other, ok := pp.publishers[key]; ok {
+		pp.Unlock()
+		p.Close()
+		return other, nil
+	}
+	pp.publishers[key] = p
+	pp.Unlock()
+
+	return p, nil
+}
+
+// Invalidate close the broken publisher, it will be recreated next time.
+func (pp *PublisherPool) Invalidate(dest *v1.DataForwardDestination) {
+	key := destinationKey(dest)
+
+	pp.Lock()
+	p, exist := pp.publishers[key]
+	delete(pp.publishers, key)
+	pp.Unlock()
+
+	if exist {
+		p.Close()
+	}
+}
+
+func (pp *PublisherPool) Close() {
+	pp.Lock()
+	defer pp.Unlock()
+
+	for key, p := range pp.publishers {
+		p.Close()
+		delete(pp.publishers, key)
+	}
+}
+
+func newPublisher(dest *v1.DataForwardDestination) (Publisher, error) {
+	switch dest.Type {
+	case v1.DestinationTypeAMQP:
+		return newAmqpPublisher(dest)
+	case v1.DestinationTypeKafka:
+		return newKafkaPublisher(dest)
+	case v1.DestinationTypeMqtt:
+		return newMqttPublisher(dest)
+	case v1.DestinationTypeWebhook:
+		return newWebhookPublisher(dest)
+	default:
+		return nil, fmt.Errorf("unsupported destination %s", dest.Type)
+	}
+}
+
+type amqpPublisher struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	queue   string
+}
+
+func newAmqpPublisher(dest *v1.DataForwardDestination) (Publisher, error) {
+	if dest.DestinationAmqp == nil || dest.QueueName == "" {
+		return nil, fmt.Errorf("amqp queue name is required")
+	}
+
+	url := dest.Host
+	if !strings.Contains(url, "://") {
+		url = fmt.Sprintf("amqp://%s:%s@%s/", dest.UserName, dest.Password, dest.Host)
+	}
+
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_, err = channel.QueueDeclare(dest.QueueName, true, false, false, false, nil)
+	if err != nil {
+		channel.Close()
+		conn.Close()
+		return nil, err
+	}
+
+	return &amqpPublisher{
+		conn:    conn,
+		channel: channel,
+		queue:   dest.QueueName,
+	}, nil
+}
+
+func (ap *amqpPublisher) Publish(msg *v1.ForwardMessage, payload []byte) error {
+	return ap.channel.Publish("", ap.queue, false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+		Body:         payload,
+	})
+}
+
+func (ap *amqpPublisher) Close() {
+	ap.channel.Close()
+	ap.conn.Close()
+}
+
+type kafkaPublisher struct {
+	producer sarama.SyncProducer
+	topic    string
+}
+
+func newKafkaPublisher(dest *v1.DataForwardDestination) (Publisher, error) {
+	if dest.DestinationKafka == nil || dest.Topic == "" {
+		return nil, fmt.Errorf("kafka topic is required")
+	}
+
+	cfg := sarama.NewConfig()
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.RequiredAcks = sarama.WaitForLocal
+	cfg.Producer.Timeout = defaultPublishTimeout
+	if dest.UserName != "" {
+		cfg.Net.SASL.Enable = true
+		cfg.Net.SASL.User = dest.UserName
+		cfg.Net.SASL.Password = dest.Password
+	}
+
+	producer, err := sarama.NewSyncProducer(strings.Split(dest.Host, ","), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafkaPublisher{
+		producer: producer,
+		topic:    dest.Topic,
+	}, nil
+}
+
+func (kp *kafkaPublisher) Publish(msg *v1.ForwardMessage, payload []byte) error {
+	_, _, err := kp.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: kp.topic,
+		Key:   sarama.StringEncoder(msg.DeviceID),
+		Value: sarama.ByteEncoder(payload),
+	})
+
+	return err
+}
+
+func (kp *kafkaPublisher) Close() {
+	kp.producer.Close()
+}
+
+type mqttPublisher struct {
+	client mqtt.Client
+	topic  string
+}
+
+func newMqttPublisher(dest *v1.DataForwardDestination) (Publisher, error) {
+	broker := dest.Host
+	if !strings.Contains(broker, "://") {
+		broker = "tcp://" + broker
+	}
+
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(broker)
+	opts.SetClientID("ithings-forward-" + utils.NewUUID())
+	opts.SetUsername(dest.UserName)
+	opts.SetPassword(dest.Password)
+	opts.SetAutoReconnect(true)
+	opts.SetConnectTimeout(defaultPublishTimeout)
+
+	client := mqtt.NewClient(opts)
+	token := client.Connect()
+	if !token.WaitTimeout(defaultPublishTimeout) {
+		client.Disconnect(0)
+		return nil, fmt.Errorf("connect to %s timeout", broker)
+	}
+	if token.Error() != nil {
+		return nil, token.Error()
+	}
+
+	mp := &mqttPublisher{
+		client: client,
+	}
+	if dest.DestinationMqtt != nil {
+		mp.topic = dest.MqttTopic
+	}
+
+	return mp, nil
+}
+
+func (mp *mqttPublisher) Publish(msg *v1.ForwardMessage, payload []byte) error {
+	topic := mp.topic
+	if topic == "" {
+		topic = defaultMqttTopicPrefix + msg.DeviceID
+	}
+
+	token := mp.client.Publish(topic, 1, false, payload)
+	if !token.WaitTimeout(defaultPublishTimeout) {
+		return fmt.Errorf("publish to %s timeout", topic)
+	}
+
+	return token.Error()
+}
+
+func (mp *mqttPublisher) Close() {
+	mp.client.Disconnect(250)
+}
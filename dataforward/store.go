@@ -0,0 +1,55 @@
+package dataforward
+
+import (
+	"encoding/json"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/common/utils"
+)
+
+// wayDetails describe the destination without the password and secret.
+func wayDetails(dest *v1.DataForwardDestination) string {
+	d := *dest
+	d.Password = ""
+	if d.DestinationWebhook != nil {
+		webhook := *d.DestinationWebhook
+		webhook.Secret = ""
+		d.DestinationWebhook = &webhook
+	}
+
+	bytes, _ := json.Marshal(&d)
+	return string(bytes)
+}
+
+// startDataForwardLog record the forwarding is running.
+func startDataForwardLog(df *dataForward, payload []byte) string {
+	log := &db.DataForwardLog{
+		ID:            utils.NewUUID(),
+		Name:          df.rule.Name,
+		Source:        df.rule.Source,
+		SourceDetails: string(payload),
+		Status:        v1.StatusRunning,
+		Way:           df.destination.Type,
+		WayDetails:    wayDetails(df.destination),
+	}
+
+	if err := db.AddDataForwardLog(log); err != nil {
+		return ""
+	}
+
+	return log.ID
+}
+
+// finishDataForwardLog record the result of the forwarding.
+func finishDataForwardLog(id string, err error) {
+	if id == "" {
+		return
+	}
+
+	if err != nil {
+		db.SaveDataForwardLogStatus(id, err.Error(), v1.StatusError)
+		return
+	}
+	db.SaveDataForwardLogStatus(id, "", v1.StatusSuccess)
+}
@@ -0,0 +1,111 @@
+package dataforward
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/edgehook/ithings/common/crypto/webhooksign"
+	"github.com/edgehook/ithings/common/types/v1"
+)
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+type webhookPublisher struct {
+	client  *http.Client
+	url     string
+	method  string
+	headers map[string]string
+	secret  []byte
+	body    *template.Template
+}
+
+func newWebhookPublisher(dest *v1.DataForwardDestination) (Publisher, error) {
+	if dest.DestinationWebhook == nil || dest.Url == "" {
+		return nil, fmt.Errorf("webhook url is required")
+	}
+	if dest.Secret == "" {
+		return nil, fmt.Errorf("webhook secret is required")
+	}
+
+	wp := &webhookPublisher{
+		client: &http.Client{
+			Timeout: defaultPublishTimeout,
+		},
+		url:     dest.Url,
+		method:  strings.ToUpper(dest.Method),
+		headers: dest.Headers,
+		secret:  []byte(dest.Secret),
+	}
+	if wp.method == "" {
+		wp.method = http.MethodPost
+	}
+
+	if dest.BodyTemplate != "" {
+		tmpl, err := template.New("body").Funcs(templateFuncs).Parse(dest.BodyTemplate)
+		if err != nil {
+			return nil, err
+		}
+		wp.body = tmpl
+	}
+
+	return wp, nil
+}
+
+func (wp *webhookPublisher) render(msg *v1.ForwardMessage, payload []byte) ([]byte, error) {
+	if wp.body == nil {
+		return payload, nil
+	}
+
+	var buf bytes.Buffer
+	if err := wp.body.Execute(&buf, msg); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (wp *webhookPublisher) Publish(msg *v1.ForwardMessage, payload []byte) error {
+	body, err := wp.render(msg, payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(wp.method, wp.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range wp.headers {
+		req.Header.Set(key, value)
+	}
+	webhooksign.SetHeaders(req.Header, wp.secret, msg.Timestamp, body)
+
+	resp, err := wp.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		detail, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook responds %d: %s", resp.StatusCode, string(detail))
+	}
+
+	return nil
+}
+
+func (wp *webhookPublisher) Close() {
+	wp.client.CloseIdleConnections()
+}
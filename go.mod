@@ -17,6 +17,7 @@ require (
 	github.com/spf13/viper v1.10.0
 	github.com/streadway/amqp v1.0.0
 	github.com/xuri/excelize/v2 v2.6.0
+	golang.org/x/crypto v0.9.0
 	google.golang.org/grpc v1.53.0
 	google.golang.org/protobuf v1.30.0
 	gorm.io/driver/mysql v1.3.2
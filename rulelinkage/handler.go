@@ -0,0 +1,183 @@
+package rulelinkage
+
+import (
+	"fmt"
+
+	"github.com/edgehook/ithings/alert"
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/global"
+	"github.com/edgehook/ithings/common/types"
+	"github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/core/devicetwin"
+	"github.com/edgehook/ithings/core/syncreq"
+	"k8s.io/klog/v2"
+)
+
+/*
+* execute
+* run all actions of this rule and record the result.
+ */
+func (rl *ruleLinkage) execute(di *db.DeviceInstance, msg *v1.ReportEventMsg, trigger *v1.RuleLinkageTrigger, logType string) {
+	logID := startRuleLinkageLog(rl, trigger, msg)
+
+	details := make([]string, 0)
+	errs := make([]string, 0)
+	for _, action := range rl.actions {
+		if action == nil {
+			continue
+		}
+
+		detail, err := handleAction(action, di, msg, logType)
+		if detail != "" {
+			details = append(details, detail)
+		}
+		if err != nil {
+			klog.Errorf("rule %s action %s with err: %v", rl.rule.Name, action.Type, err)
+			errs = append(errs, fmt.Sprintf("%s: %v", action.Type, err))
+		}
+	}
+
+	finishRuleLinkageLog(logID, details, errs)
+}
+
+func handleAction(action *v1.RuleLinkageAction, di *db.DeviceInstance, msg *v1.ReportEventMsg, logType string) (string, error) {
+	switch action.Type {
+	case v1.REPORTACTION:
+		return handleReportAction(action, di, msg, logType)
+	case v1.PROPERTYACTION:
+		return handlePropertyAction(action, di)
+	case v1.PARTICULARPROPERTYACTION:
+		return handleParticularPropertyAction(action)
+	case v1.CONTROLACTION:
+		if action.RuleLinkageControl == nil {
+			return "", global.ErrInvalidParms
+		}
+		return handleControlAction(di.EdgeID, action.ControlType)
+	case v1.PARTICULARCONTROLACTION:
+		return handleParticularControlAction(action)
+	case v1.REBOOT, v1.SHUTDOWN:
+		return handleControlAction(di.EdgeID, action.Type)
+	default:
+		return "", fmt.Errorf("unsupported action %s", action.Type)
+	}
+}
+
+// handleReportAction raise the alert configured in the action.
+func handleReportAction(action *v1.RuleLinkageAction, di *db.DeviceInstance, msg *v1.ReportEventMsg, logType string) (string, error) {
+	if action.RuleLinkageAlert == nil || action.AlertId == nil {
+		return "", global.ErrInvalidParms
+	}
+
+	alertConfig, err := db.GetAlertById(*action.AlertId)
+	if err != nil {
+		return "", err
+	}
+
+	record := msg.Details
+	if record == "" {
+		record = fmt.Sprintf("%s/%s", msg.ServiceName, msg.EventName)
+	}
+	alertLog := &db.AlertLog{
+		Name:        alertConfig.Name,
+		LogType:     logType,
+		Description: alertConfig.Description,
+		Level:       alertConfig.Level,
+		EdgeId:      di.EdgeID,
+		DeviceName:  di.Name,
+		DeviceId:    di.DeviceID,
+		Status:      v1.AlertLogUnsolved,
+		Record:      record,
+		Trigger:     alert.TriggerKey(msg.ServiceName, msg.EventName),
+	}
+	if err := alert.Raise(alertConfig, alertLog); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("alert %s raised on %s", alertConfig.Name, di.Name), nil
+}
+
+// handlePropertyAction set the property of the devices with this model in the same edge.
+func handlePropertyAction(action *v1.RuleLinkageAction, di *db.DeviceInstance) (string, error) {
+	if action.RuleLinkageProperty == nil {
+		return "", global.ErrInvalidParms
+	}
+
+	dm, err := db.GetDeviceModelByName(action.DeviceModel)
+	if err != nil {
+		return "", global.ErrNoSuchDeviceModel
+	}
+	dis, err := db.GetDeviceInstancesByDeviceModelId(dm.ID)
+	if err != nil {
+		return "", err
+	}
+
+	count := 0
+	for _, d := range dis {
+		if d == nil || d.EdgeID != di.EdgeID {
+			continue
+		}
+
+		err := devicetwin.SetDesired(&v1.DesiredPropertyMsg{
+			DeviceId:     d.DeviceID,
+			ServiceName:  action.ServiceName,
+			PropertyName: action.PropertyName,
+			Value:        action.PropertyValue,
+		})
+		if err != nil {
+			return "", err
+		}
+		count++
+	}
+
+	return fmt.Sprintf("set %s/%s=%s on %d devices", action.ServiceName,
+		action.PropertyName, action.PropertyValue, count), nil
+}
+
+// handleParticularPropertyAction set the property of the particular device.
+func handleParticularPropertyAction(action *v1.RuleLinkageAction) (string, error) {
+	pp := action.RuleLinkageParticularProperty
+	if pp == nil || pp.DeviceId == "" {
+		return "", global.ErrInvalidParms
+	}
+
+	err := devicetwin.SetDesired(&v1.DesiredPropertyMsg{
+		DeviceId:     pp.DeviceId,
+		ServiceName:  pp.ParticularServiceName,
+		PropertyName: pp.ParticularPropertyName,
+		Value:        pp.ParticularPropertyValue,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("set %s/%s=%s on %s", pp.ParticularServiceName,
+		pp.ParticularPropertyName, pp.ParticularPropertyValue, pp.DeviceId), nil
+}
+
+func handleParticularControlAction(action *v1.RuleLinkageAction) (string, error) {
+	pc := action.RuleLinkageParticularControl
+	if pc == nil || pc.EdgeId == "" {
+		return "", global.ErrInvalidParms
+	}
+
+	return handleControlAction(pc.EdgeId, pc.ParticularControlType)
+}
+
+// handleControlAction send the control command to the edge.
+func handleControlAction(edgeID, controlType string) (string, error) {
+	if edgeID == "" || controlType == "" {
+		return "", global.ErrInvalidParms
+	}
+
+	req := types.BuildRequest(edgeID, types.MSG_MAPPER_EDGE, types.MSG_RESOURCE_EDGE, types.MSG_OPS_CONTROL)
+	req.SetContent(&v1.GrpcDeviceControl{
+		EdgeId: edgeID,
+		Action: controlType,
+	})
+
+	if _, err := syncreq.SendRequest(req, global.DefaultEdgeMaxResponseTime); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s edge %s", controlType, edgeID), nil
+}
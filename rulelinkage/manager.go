@@ -0,0 +1,272 @@
+package rulelinkage
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/global"
+	"github.com/edgehook/ithings/common/grp"
+	"github.com/edgehook/ithings/common/types/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	//reload the rules from database periodically.
+	defaultRuleReloadInterval = 10 * time.Second
+	defaultMaxGoRoutines      = 64
+)
+
+type ruleLinkage struct {
+	rule     *db.RuleLinkage
+	triggers []*v1.RuleLinkageTrigger
+	filters  []*v1.RuleLinkageFilter
+	actions  []*v1.RuleLinkageAction
+}
+
+/*
+* RuleLinkageManager
+* evaluate the enabled rules when the device events arrived.
+ */
+type RuleLinkageManager struct {
+	sync.RWMutex
+	rules    []*ruleLinkage
+	loadTime time.Time
+	pool     *grp.GoRoutinePool
+}
+
+var (
+	defaultManager *RuleLinkageManager
+	managerOnce    sync.Once
+)
+
+func getManager() *RuleLinkageManager {
+	managerOnce.Do(func() {
+		defaultManager = &RuleLinkageManager{
+			pool: grp.NewGoRoutinePool(defaultMaxGoRoutines),
+		}
+	})
+
+	return defaultManager
+}
+
+func parseRuleLinkage(rule *db.RuleLinkage) (*ruleLinkage, error) {
+	rl := &ruleLinkage{
+		rule: rule,
+	}
+
+	if err := json.Unmarshal([]byte(rule.Trigger), &rl.triggers); err != nil {
+		return nil, err
+	}
+	if rule.Filter != "" {
+		if err := json.Unmarshal([]byte(rule.Filter), &rl.filters); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal([]byte(rule.Action), &rl.actions); err != nil {
+		return nil, err
+	}
+
+	return rl, nil
+}
+
+// Reload load all enabled rules from database.
+func (m *RuleLinkageManager) Reload() {
+	rules, err := db.GetRuleLinkage()
+	if err != nil {
+		return
+	}
+
+	rls := make([]*ruleLinkage, 0)
+	for _, rule := range rules {
+		if rule == nil || rule.Status != v1.RuleLinkageStatusEnable {
+			continue
+		}
+
+		rl, err := parseRuleLinkage(rule)
+		if err != nil {
+			klog.Warningf("ignore the invalid rule %s: %v", rule.Name, err)
+			continue
+		}
+		rls = append(rls, rl)
+	}
+
+	m.Lock()
+	m.rules = rls
+	m.loadTime = time.Now()
+	m.Unlock()
+}
+
+func (m *RuleLinkageManager) getRules() []*ruleLinkage {
+	m.RLock()
+	expired := time.Since(m.loadTime) > defaultRuleReloadInterval
+	m.RUnlock()
+
+	if expired {
+		m.Reload()
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+	return m.rules
+}
+
+/*
+* HandleEvent
+* match the event with the rules' triggers, and run the actions
+* if the filters are passed.
+ */
+func (m *RuleLinkageManager) HandleEvent(msg *v1.ReportEventMsg, logType string) {
+	if msg == nil || msg.DeviceID == "" {
+		return
+	}
+
+	di, err := db.GetDeviceInstanceByDeviceId(msg.DeviceID)
+	if err != nil {
+		klog.Warningf("rule linkage: %v", global.ErrNoSuchDevice)
+		return
+	}
+
+	now := time.Now()
+	for _, rl := range m.getRules() {
+		trigger := rl.matchTrigger(&di, msg)
+		if trigger == nil {
+			continue
+		}
+		if !rl.passFilters(now) {
+			klog.V(4).Infof("rule %s is not effective now", rl.rule.Name)
+			continue
+		}
+
+		rule := rl
+		err := m.pool.Run(func() {
+			rule.execute(&di, msg, trigger, logType)
+		})
+		if err != nil {
+			klog.Errorf("run rule %s with err: %v", rl.rule.Name, err)
+		}
+	}
+}
+
+func (rl *ruleLinkage) matchTrigger(di *db.DeviceInstance, msg *v1.ReportEventMsg) *v1.RuleLinkageTrigger {
+	for _, t := range rl.triggers {
+		if t == nil {
+			continue
+		}
+
+		switch t.Type {
+		case v1.EVENTTRIGGER:
+			if t.RuleLinkageEvent == nil {
+				continue
+			}
+			model := t.DeviceModel
+			if model == "" {
+				model = rl.rule.DeviceModelName
+			}
+			if model == di.DeviceModelRef && t.ServiceName == msg.ServiceName &&
+				t.EventName == msg.EventName {
+				return t
+			}
+		case v1.PARTICULAREVENTTRIGGER:
+			if t.RuleLinkageParticularEvent == nil {
+				continue
+			}
+			if t.DeviceId == msg.DeviceID && t.ParticularServiceName == msg.ServiceName &&
+				t.ParticularEventName == msg.EventName {
+				return t
+			}
+		}
+	}
+
+	return nil
+}
+
+func (rl *ruleLinkage) passFilters(now time.Time) bool {
+	for _, f := range rl.filters {
+		if f == nil || f.Type != v1.FilterEffectiveTime || f.EffectiveTime == nil {
+			continue
+		}
+
+		if !isInEffectiveTime(f.EffectiveTime, now) {
+			return false
+		}
+	}
+
+	return true
+}
+
+var (
+	timeOfDayLayouts = []string{"15:04:05", "15:04"}
+	dateTimeLayouts  = []string{"2006-01-02 15:04:05", "2006-01-02 15:04", time.RFC3339}
+)
+
+func parseTime(s string, layouts []string) (time.Time, bool) {
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+/*
+* isInEffectiveTime
+* the effective time is a time window of the day such as 08:00 ~ 18:00,
+* which may cross the midnight, or an absolute date time range.
+ */
+func isInEffectiveTime(et *v1.EffectiveTime, now time.Time) bool {
+	start := strings.TrimSpace(et.StartTime)
+	end := strings.TrimSpace(et.EndTime)
+	if start == "" && end == "" {
+		return true
+	}
+
+	st, ok1 := parseTime(start, dateTimeLayouts)
+	ed, ok2 := parseTime(end, dateTimeLayouts)
+	if ok1 || ok2 {
+		if ok1 && now.Before(st) {
+			return false
+		}
+		if ok2 && now.After(ed) {
+			return false
+		}
+		return true
+	}
+
+	st, ok1 = parseTime(start, timeOfDayLayouts)
+	ed, ok2 = parseTime(end, timeOfDayLayouts)
+	if !ok1 || !ok2 {
+		klog.Warningf("invalid effective time %s ~ %s", start, end)
+		return false
+	}
+
+	secOfDay := func(t time.Time) int {
+		return t.Hour()*3600 + t.Minute()*60 + t.Second()
+	}
+	n, s, e := secOfDay(now), secOfDay(st), secOfDay(ed)
+	if s <= e {
+		return n >= s && n <= e
+	}
+
+	//cross the midnight.
+	return n >= s || n <= e
+}
+
+// Reload reload the rules of the default manager.
+func Reload() {
+	getManager().Reload()
+}
+
+// HandleEvent handle the event reported by the edge.
+func HandleEvent(msg *v1.ReportEventMsg) {
+	getManager().HandleEvent(msg, v1.AlertLogTypeEvent)
+}
+
+// HandleMonitorEvent handle the event detected on server side, the alerts
+// raised by it are resolved automatically once the condition clears.
+func HandleMonitorEvent(msg *v1.ReportEventMsg) {
+	getManager().HandleEvent(msg, v1.AlertLogTypeMonitor)
+}
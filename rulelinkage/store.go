@@ -0,0 +1,51 @@
+package rulelinkage
+
+import (
+	"encoding/json"
+	"strings"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/common/utils"
+)
+
+func toJSON(v interface{}) string {
+	d, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+
+	return string(d)
+}
+
+// startRuleLinkageLog record the rule is running.
+func startRuleLinkageLog(rl *ruleLinkage, trigger *v1.RuleLinkageTrigger, msg *v1.ReportEventMsg) string {
+	log := &db.RuleLinkageLog{
+		ID:             utils.NewUUID(),
+		Name:           rl.rule.Name,
+		Trigger:        toJSON(trigger),
+		TriggerDetails: toJSON(msg),
+		Status:         v1.RULELOGSTATUSRUNNING,
+		Action:         rl.rule.Action,
+	}
+
+	if err := db.AddRuleLinkageLog(log); err != nil {
+		return ""
+	}
+
+	return log.ID
+}
+
+// finishRuleLinkageLog record the result of the actions.
+func finishRuleLinkageLog(id string, details []string, errs []string) {
+	if id == "" {
+		return
+	}
+
+	status := v1.RULELOGSTATUSSUCCESS
+	if len(errs) > 0 {
+		status = v1.RULELOGSTATUSERROR
+	}
+
+	db.SaveRuleLinkageLogResult(id, strings.Join(details, "\n"), strings.Join(errs, "\n"), status)
+}
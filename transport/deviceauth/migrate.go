@@ -0,0 +1,82 @@
+package deviceauth
+
+import (
+	"github.com/edgehook/ithings/common/crypto"
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"k8s.io/klog/v2"
+)
+
+// reEncrypt return the secret encrypted by the current key, changed is false if it's up to date.
+func reEncrypt(s string) (string, bool, error) {
+	if s == "" || !crypto.NeedsReEncrypt(s) {
+		return s, false, nil
+	}
+
+	encrypted, err := crypto.ReEncrypt(s)
+	if err != nil {
+		return s, false, err
+	}
+	return encrypted, true, nil
+}
+
+func reEncryptPair(secret, previous string) (string, string, bool, error) {
+	secret, changed, err := reEncrypt(secret)
+	if err != nil {
+		return "", "", false, err
+	}
+	previous, previousChanged, err := reEncrypt(previous)
+	if err != nil {
+		return "", "", false, err
+	}
+
+	return secret, previous, changed || previousChanged, nil
+}
+
+/*
+* MigrateSecrets
+* re-encrypt the secrets of the devices and the edges with the current
+* key, they are encrypted by the legacy DES or by the retired keys.
+ */
+func MigrateSecrets() {
+	count := 0
+
+	devices, err := db.GetDeviceInstancesWithSecret()
+	if err != nil {
+		return
+	}
+	for _, di := range devices {
+		secret, previous, changed, err := reEncryptPair(di.Secret, di.PreviousSecret)
+		if err != nil {
+			klog.Errorf("re-encrypt the secret of device %s with err: %v", di.DeviceID, err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+		if err := db.UpdateDeviceInstanceEncryptedSecret(di.DeviceID, secret, previous); err == nil {
+			count++
+		}
+	}
+
+	creds, err := db.GetEdgeCredentials()
+	if err != nil {
+		return
+	}
+	for _, cred := range creds {
+		secret, previous, changed, err := reEncryptPair(cred.Secret, cred.PreviousSecret)
+		if err != nil {
+			klog.Errorf("re-encrypt the secret of edge %s with err: %v", cred.EdgeID, err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+		if err := db.UpdateEdgeCredentialEncryptedSecret(cred.EdgeID, secret, previous); err == nil {
+			count++
+		}
+	}
+
+	if count > 0 {
+		klog.Infof("re-encrypt %d secrets with the current key", count)
+	}
+}
@@ -0,0 +1,275 @@
+package deviceauth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/edgehook/ithings/common/config"
+	"github.com/edgehook/ithings/common/crypto"
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/types"
+	"github.com/edgehook/ithings/common/types/v1"
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+)
+
+const (
+	//the edge or device has no secret, its frames are accepted unsigned.
+	AuthTypeNone = ""
+	//the frames are signed by HMAC-SHA256 of the secret.
+	AuthTypeHMAC = "hmac"
+	//the secret is revoked, the frames are rejected until a new secret is generated.
+	AuthTypeRevoked = "revoked"
+
+	secretSize      = 32
+	minSecretLength = 16
+	mqttSignPrefix  = "mqtt|"
+)
+
+var (
+	ErrSecretTooShort = errors.New("the secret should be at least 16 characters")
+)
+
+// credential is the stored secret of the edge or the device.
+type credential struct {
+	authType       string
+	secret         string
+	previous       string
+	previousExpire int64
+	updateTime     int64
+}
+
+// GenerateSecret return a random secret in hex.
+func GenerateSecret() (string, error) {
+	buf := make([]byte, secretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func hmacHex(secret, data string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign return the signature of the request with the secret.
+func Sign(secret string, req *types.Request) string {
+	return hmacHex(secret, req.SigningString())
+}
+
+// MqttPassword return the MQTT password derived from the secret.
+func MqttPassword(secret, username string) string {
+	return hmacHex(secret, mqttSignPrefix+username)
+}
+
+// EncryptSecret encrypt the secret to store it.
+func EncryptSecret(secret string) (string, error) {
+	if len(secret) < minSecretLength {
+		return "", ErrSecretTooShort
+	}
+	return crypto.Encrypt([]byte(secret))
+}
+
+/*
+* secrets
+* return the secrets accepted now, the current one and the previous
+* one in the grace period.
+ */
+func (c *credential) secrets(now int64) []string {
+	var list []string
+
+	if c.authType != AuthTypeHMAC {
+		return list
+	}
+	candidates := []string{c.secret}
+	if c.previous != "" && c.previousExpire > now {
+		candidates = append(candidates, c.previous)
+	}
+	for _, s := range candidates {
+		if s == "" {
+			continue
+		}
+		secret, err := crypto.Decrypt(s)
+		if err != nil {
+			klog.Errorf("decrypt secret with err: %v", err)
+			continue
+		}
+		list = append(list, string(secret))
+	}
+
+	return list
+}
+
+/*
+* rotate
+* replace the secret with a new one, the current secret is kept as the
+* previous one in the grace period unless immediate.
+ */
+func (c *credential) rotate(secret string, immediate bool) (string, error) {
+	var err error
+
+	if secret == "" {
+		secret, err = GenerateSecret()
+		if err != nil {
+			return "", err
+		}
+	}
+	encrypted, err := EncryptSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	c.previous, c.previousExpire = "", 0
+	if c.authType == AuthTypeHMAC && !immediate {
+		grace := config.GetDeviceAuthConfig().RotationGrace
+		c.previous = c.secret
+		c.previousExpire = (time.Now().UnixNano() + int64(grace)) / 1e6
+	}
+	c.authType = AuthTypeHMAC
+	c.secret = encrypted
+	c.updateTime = time.Now().UnixNano() / 1e6
+
+	return secret, nil
+}
+
+func (c *credential) revoke() {
+	c.authType = AuthTypeRevoked
+	c.secret, c.previous, c.previousExpire = "", "", 0
+	c.updateTime = time.Now().UnixNano() / 1e6
+}
+
+func newDeviceSecret(id string, c *credential, secret string) *v1.DeviceSecret {
+	ds := &v1.DeviceSecret{
+		ID:              id,
+		AuthType:        c.authType,
+		UpdateTimeStamp: c.updateTime,
+	}
+	if c.previousExpire > time.Now().UnixNano()/1e6 {
+		ds.PreviousSecretExpireTimeStamp = c.previousExpire
+	}
+	if secret != "" {
+		ds.Secret = secret
+		ds.MqttUsername = id
+		ds.MqttPassword = MqttPassword(secret, id)
+	}
+	return ds
+}
+
+func deviceCredential(di *db.DeviceInstance) *credential {
+	return &credential{
+		authType:       di.DeviceAuthType,
+		secret:         di.Secret,
+		previous:       di.PreviousSecret,
+		previousExpire: di.PreviousSecretExpireTimeStamp,
+		updateTime:     di.UpdateTimeStamp,
+	}
+}
+
+/*
+* getEdgeCredential
+* get the credential of the edge, it's empty if the edge has no secret.
+ */
+func getEdgeCredential(edgeID string) (*credential, error) {
+	cred, err := db.GetEdgeCredentialByEdgeId(edgeID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &credential{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &credential{
+		authType:       cred.AuthType,
+		secret:         cred.Secret,
+		previous:       cred.PreviousSecret,
+		previousExpire: cred.PreviousSecretExpireTimeStamp,
+		updateTime:     cred.UpdateTimeStamp,
+	}, nil
+}
+
+func saveEdgeCredential(edgeID string, c *credential) error {
+	return db.SaveEdgeCredential(&db.EdgeCredential{
+		EdgeID:                        edgeID,
+		AuthType:                      c.authType,
+		Secret:                        c.secret,
+		PreviousSecret:                c.previous,
+		PreviousSecretExpireTimeStamp: c.previousExpire,
+	})
+}
+
+// GetDeviceSecret return the auth type of the device without the secret.
+func GetDeviceSecret(di *db.DeviceInstance) *v1.DeviceSecret {
+	return newDeviceSecret(di.DeviceID, deviceCredential(di), "")
+}
+
+/*
+* RotateDeviceSecret
+* generate or rotate the secret of the device, the secret is random if it's empty.
+ */
+func RotateDeviceSecret(di *db.DeviceInstance, secret string, immediate bool) (*v1.DeviceSecret, error) {
+	c := deviceCredential(di)
+	secret, err := c.rotate(secret, immediate)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.SaveDeviceInstanceSecret(di.DeviceID, c.authType, c.secret, c.previous, c.previousExpire)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDeviceSecret(di.DeviceID, c, secret), nil
+}
+
+// RevokeDeviceSecret revoke the secret, the frames of the device are rejected.
+func RevokeDeviceSecret(di *db.DeviceInstance) error {
+	c := deviceCredential(di)
+	c.revoke()
+	return db.SaveDeviceInstanceSecret(di.DeviceID, c.authType, c.secret, c.previous, c.previousExpire)
+}
+
+// GetEdgeSecret return the auth type of the edge without the secret.
+func GetEdgeSecret(edgeID string) (*v1.DeviceSecret, error) {
+	c, err := getEdgeCredential(edgeID)
+	if err != nil {
+		return nil, err
+	}
+	return newDeviceSecret(edgeID, c, ""), nil
+}
+
+/*
+* RotateEdgeSecret
+* generate or rotate the secret of the edge, the secret is random if it's empty.
+ */
+func RotateEdgeSecret(edgeID string, secret string, immediate bool) (*v1.DeviceSecret, error) {
+	c, err := getEdgeCredential(edgeID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err = c.rotate(secret, immediate)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := saveEdgeCredential(edgeID, c); err != nil {
+		return nil, err
+	}
+
+	return newDeviceSecret(edgeID, c, secret), nil
+}
+
+// RevokeEdgeSecret revoke the secret, the frames of the edge are rejected.
+func RevokeEdgeSecret(edgeID string) error {
+	c, err := getEdgeCredential(edgeID)
+	if err != nil {
+		return err
+	}
+	c.revoke()
+	return saveEdgeCredential(edgeID, c)
+}
@@ -0,0 +1,263 @@
+package deviceauth
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/edgehook/ithings/common/config"
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/types"
+	"github.com/edgehook/ithings/common/types/v1"
+)
+
+var (
+	ErrUnsigned       = errors.New("the frame should be signed")
+	ErrNoSecret       = errors.New("the signer has no secret")
+	ErrSecretRevoked  = errors.New("the secret is revoked")
+	ErrBadSignature   = errors.New("invalid signature")
+	ErrStaleFrame     = errors.New("the timestamp of the frame is out of range")
+	ErrReplayedFrame  = errors.New("the frame is replayed")
+	ErrSignerMismatch = errors.New("the signer can't send this frame")
+)
+
+var authErrors = []error{ErrUnsigned, ErrNoSecret, ErrSecretRevoked, ErrBadSignature,
+	ErrStaleFrame, ErrReplayedFrame, ErrSignerMismatch}
+
+// IsAuthError indicates whether the frame is rejected by the verification.
+func IsAuthError(err error) bool {
+	for _, e := range authErrors {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+* Verifier
+* verify the register/report frames from the edges, the frame is signed by
+* the secret of the edge, or of the device if it only concerns this device.
+* The message ids are remembered in the time window to reject the replays.
+ */
+type Verifier struct {
+	cfg *config.DeviceAuthConfig
+
+	sync.Mutex
+	seen      map[string]int64
+	lastSweep int64
+}
+
+var (
+	defaultVerifier *Verifier
+	once            sync.Once
+)
+
+func NewVerifier(cfg *config.DeviceAuthConfig) *Verifier {
+	return &Verifier{
+		cfg:  cfg,
+		seen: make(map[string]int64),
+	}
+}
+
+func getVerifier() *Verifier {
+	once.Do(func() {
+		defaultVerifier = NewVerifier(config.GetDeviceAuthConfig())
+	})
+	return defaultVerifier
+}
+
+// VerifyRequest verify the request with the default verifier.
+func VerifyRequest(req *types.Request) error {
+	return getVerifier().Verify(req)
+}
+
+/*
+* reportedDeviceIDs
+* return the devices in the report, nil if the content is invalid,
+* the core replies the invalid frames.
+ */
+func reportedDeviceIDs(req *types.Request) []string {
+	var ids []string
+
+	content := []byte(req.GetContent())
+	switch req.Resource {
+	case types.MSG_RESOURCE_TWINS:
+		msg := &v1.ReportDevicesMessage{}
+		if err := json.Unmarshal(content, msg); err != nil {
+			return nil
+		}
+		for _, dev := range msg.Devices {
+			if dev != nil && dev.DeviceID != "" {
+				ids = append(ids, dev.DeviceID)
+			}
+		}
+	case types.MSG_RESOURCE_STATUS:
+		msg := &v1.DevicesStatusMessage{}
+		if err := json.Unmarshal(content, msg); err != nil {
+			return nil
+		}
+		for _, ds := range msg.DevicesStatus {
+			if ds != nil && ds.DeviceID != "" {
+				ids = append(ids, ds.DeviceID)
+			}
+		}
+	case types.MSG_RESOURCE_EVENT:
+		msg := &v1.ReportEventMsg{}
+		if err := json.Unmarshal(content, msg); err != nil {
+			return nil
+		}
+		if msg.DeviceID != "" {
+			ids = append(ids, msg.DeviceID)
+		}
+	}
+
+	return ids
+}
+
+/*
+* checkReplay
+* remember the message id of the signer until the timestamp is out of
+* the window, the expired ids are swept at most once a window.
+ */
+func (v *Verifier) checkReplay(key string, ts, now int64) error {
+	window := int64(v.cfg.MaxSkew / time.Millisecond)
+
+	v.Lock()
+	defer v.Unlock()
+
+	if now-v.lastSweep > window {
+		for k, expire := range v.seen {
+			if expire < now {
+				delete(v.seen, k)
+			}
+		}
+		v.lastSweep = now
+	}
+
+	if _, exist := v.seen[key]; exist {
+		return ErrReplayedFrame
+	}
+	v.seen[key] = ts + window
+
+	return nil
+}
+
+/*
+* checkSignature
+* verify the signature with the secrets of the signer, the timestamp and
+* the message id.
+ */
+func (v *Verifier) checkSignature(req *types.Request, c *credential, now int64) error {
+	p := &req.Payload
+
+	switch c.authType {
+	case AuthTypeHMAC:
+	case AuthTypeRevoked:
+		return ErrSecretRevoked
+	default:
+		return ErrNoSecret
+	}
+
+	window := int64(v.cfg.MaxSkew / time.Millisecond)
+	if p.Timestamp < now-window || p.Timestamp > now+window {
+		return ErrStaleFrame
+	}
+
+	valid := false
+	for _, secret := range c.secrets(now) {
+		if hmac.Equal([]byte(Sign(secret, req)), []byte(p.Sign)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return ErrBadSignature
+	}
+
+	return v.checkReplay(req.EdgeID+"/"+p.Signer+"/"+p.ID, p.Timestamp, now)
+}
+
+// checkUnsigned reject the unsigned frame if the edge or any device has a secret.
+func (v *Verifier) checkUnsigned(edge *credential, devices []*db.DeviceInstance) error {
+	if v.cfg.Required {
+		return ErrUnsigned
+	}
+
+	authTypes := []string{edge.authType}
+	for _, di := range devices {
+		authTypes = append(authTypes, di.DeviceAuthType)
+	}
+	for _, authType := range authTypes {
+		switch authType {
+		case AuthTypeNone:
+		case AuthTypeRevoked:
+			return ErrSecretRevoked
+		default:
+			return ErrUnsigned
+		}
+	}
+
+	return nil
+}
+
+/*
+* Verify
+* verify the register/report frames, the other frames are not checked.
+* The frame signed by the device can only report this device, and the
+* reported devices should belong to the edge of the topic.
+ */
+func (v *Verifier) Verify(req *types.Request) error {
+	var devices []*db.DeviceInstance
+	var err error
+
+	if req.Operation != types.MSG_OPS_REGISTER && req.Operation != types.MSG_OPS_REPORT {
+		return nil
+	}
+
+	now := time.Now().UnixNano() / 1e6
+	signer := req.Payload.Signer
+	if req.Operation == types.MSG_OPS_REPORT {
+		ids := reportedDeviceIDs(req)
+		if signer != "" {
+			for _, id := range ids {
+				if id != signer {
+					return ErrSignerMismatch
+				}
+			}
+			ids = []string{signer}
+		}
+		if len(ids) > 0 {
+			devices, err = db.GetDeviceInstanceCredentials(ids)
+			if err != nil {
+				return err
+			}
+		}
+		for _, di := range devices {
+			if di.EdgeID != req.EdgeID {
+				return ErrSignerMismatch
+			}
+		}
+	} else if signer != "" {
+		//only the edge can register.
+		return ErrSignerMismatch
+	}
+
+	edge, err := getEdgeCredential(req.EdgeID)
+	if err != nil {
+		return err
+	}
+
+	if req.Payload.Sign == "" {
+		return v.checkUnsigned(edge, devices)
+	}
+	if signer == "" {
+		return v.checkSignature(req, edge, now)
+	}
+	if len(devices) == 0 {
+		return ErrNoSecret
+	}
+	return v.checkSignature(req, deviceCredential(devices[0]), now)
+}
@@ -0,0 +1,202 @@
+package deviceauth
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edgehook/ithings/common/config"
+	"github.com/edgehook/ithings/common/crypto"
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/global"
+	"github.com/edgehook/ithings/common/types"
+	"github.com/edgehook/ithings/common/types/v1"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	edgeSecret   = "edge-secret-0123456789"
+	deviceSecret = "device-secret-0123456789"
+	maxSkew      = time.Minute
+)
+
+/*
+* setupCredentials
+* store the credentials in a memory database:
+* edge-1 is signed with dev-1 signed and dev-2 revoked,
+* edge-2 is unsigned with dev-3 signed, dev-4 unsigned and dev-5 revoked,
+* edge-3 is revoked.
+ */
+func setupCredentials(t *testing.T) {
+	crypto.SetKeyProvider(mustEnvKeys(t))
+	t.Cleanup(func() { crypto.SetKeyProvider(nil) })
+
+	gdb, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, _ := gdb.DB()
+	//every connection has its own memory database.
+	sqlDB.SetMaxOpenConns(1)
+	if err := gdb.AutoMigrate(&db.EdgeCredential{}, &db.DeviceInstance{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	old := global.DBAccess
+	global.DBAccess = gdb
+	t.Cleanup(func() {
+		global.DBAccess = old
+		sqlDB.Close()
+	})
+
+	edge, device := mustEncrypt(t, edgeSecret), mustEncrypt(t, deviceSecret)
+	for _, cred := range []*db.EdgeCredential{
+		{EdgeID: "edge-1", AuthType: AuthTypeHMAC, Secret: edge},
+		{EdgeID: "edge-3", AuthType: AuthTypeRevoked},
+	} {
+		if err := db.SaveEdgeCredential(cred); err != nil {
+			t.Fatalf("save edge credential: %v", err)
+		}
+	}
+	for _, di := range []*db.DeviceInstance{
+		{DeviceID: "dev-1", Name: "dev-1", EdgeID: "edge-1", DeviceAuthType: AuthTypeHMAC, Secret: device},
+		{DeviceID: "dev-2", Name: "dev-2", EdgeID: "edge-1", DeviceAuthType: AuthTypeRevoked},
+		{DeviceID: "dev-3", Name: "dev-3", EdgeID: "edge-2", DeviceAuthType: AuthTypeHMAC, Secret: device},
+		{DeviceID: "dev-4", Name: "dev-4", EdgeID: "edge-2"},
+		{DeviceID: "dev-5", Name: "dev-5", EdgeID: "edge-2", DeviceAuthType: AuthTypeRevoked},
+	} {
+		if err := gdb.Create(di).Error; err != nil {
+			t.Fatalf("create device: %v", err)
+		}
+	}
+}
+
+func mustEnvKeys(t *testing.T) crypto.KeyProvider {
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	p, err := crypto.NewEnvKeyProvider("test:" + key)
+	if err != nil {
+		t.Fatalf("NewEnvKeyProvider() = %v", err)
+	}
+	return p
+}
+
+func mustEncrypt(t *testing.T, secret string) string {
+	encrypted, err := EncryptSecret(secret)
+	if err != nil {
+		t.Fatalf("EncryptSecret() = %v", err)
+	}
+	return encrypted
+}
+
+// newFrame build the frame of the edge reporting the devices, it's unsigned if the secret is empty.
+func newFrame(edgeID, operation, signer, secret string, ts int64, deviceIDs ...string) *types.Request {
+	msg := &v1.ReportDevicesMessage{}
+	for _, id := range deviceIDs {
+		msg.Devices = append(msg.Devices, &v1.ReportDeviceMessage{DeviceID: id})
+	}
+
+	req := types.BuildRequest(edgeID, "mapper-1", types.MSG_RESOURCE_TWINS, operation)
+	req.SetContent(msg)
+	req.Payload.Timestamp = ts
+	req.Payload.Signer = signer
+	if secret != "" {
+		req.Payload.Sign = Sign(secret, req)
+	}
+
+	return req
+}
+
+func TestVerify(t *testing.T) {
+	setupCredentials(t)
+
+	now := time.Now().UnixNano() / 1e6
+	skew := int64(maxSkew / time.Millisecond)
+	report := types.MSG_OPS_REPORT
+
+	tests := []struct {
+		name     string
+		required bool
+		req      *types.Request
+		want     error
+	}{
+		{"signed by edge", false, newFrame("edge-1", report, "", edgeSecret, now, "dev-1", "dev-2"), nil},
+		{"signed by device", false, newFrame("edge-1", report, "dev-1", deviceSecret, now, "dev-1"), nil},
+		{"register signed by edge", false, newFrame("edge-1", types.MSG_OPS_REGISTER, "", edgeSecret, now), nil},
+		{"unsigned without secrets", false, newFrame("edge-2", report, "", "", now, "dev-4"), nil},
+		{"unsigned reply", false, newFrame("edge-1", types.MSG_OPS_REPLY, "", "", now, "dev-1"), nil},
+
+		{"device signs another device", false, newFrame("edge-1", report, "dev-1", deviceSecret, now, "dev-1", "dev-2"), ErrSignerMismatch},
+		{"device of another edge", false, newFrame("edge-1", report, "", edgeSecret, now, "dev-3"), ErrSignerMismatch},
+		{"signer of another edge", false, newFrame("edge-1", report, "dev-3", deviceSecret, now, "dev-3"), ErrSignerMismatch},
+		{"register signed by device", false, newFrame("edge-1", types.MSG_OPS_REGISTER, "dev-1", deviceSecret, now), ErrSignerMismatch},
+
+		{"stale timestamp", false, newFrame("edge-1", report, "", edgeSecret, now-skew-1000, "dev-1"), ErrStaleFrame},
+		{"future timestamp", false, newFrame("edge-1", report, "", edgeSecret, now+skew+1000, "dev-1"), ErrStaleFrame},
+		{"wrong secret", false, newFrame("edge-1", report, "", deviceSecret, now, "dev-1"), ErrBadSignature},
+		{"signed without secret", false, newFrame("edge-2", report, "", edgeSecret, now, "dev-4"), ErrNoSecret},
+		{"signed by unknown device", false, newFrame("edge-1", report, "dev-9", deviceSecret, now, "dev-9"), ErrNoSecret},
+
+		{"revoked edge signed", false, newFrame("edge-3", report, "", edgeSecret, now), ErrSecretRevoked},
+		{"revoked edge unsigned", false, newFrame("edge-3", report, "", "", now), ErrSecretRevoked},
+		{"revoked device signed", false, newFrame("edge-1", report, "dev-2", deviceSecret, now, "dev-2"), ErrSecretRevoked},
+		{"revoked device unsigned", false, newFrame("edge-2", report, "", "", now, "dev-4", "dev-5"), ErrSecretRevoked},
+
+		{"unsigned with edge secret", false, newFrame("edge-1", report, "", "", now, "dev-1"), ErrUnsigned},
+		{"unsigned with device secret", false, newFrame("edge-2", report, "", "", now, "dev-4", "dev-3"), ErrUnsigned},
+		{"unsigned register with edge secret", false, newFrame("edge-1", types.MSG_OPS_REGISTER, "", "", now), ErrUnsigned},
+		{"unsigned but required", true, newFrame("edge-2", report, "", "", now, "dev-4"), ErrUnsigned},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewVerifier(&config.DeviceAuthConfig{Required: tt.required, MaxSkew: maxSkew})
+			err := v.Verify(tt.req)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify() = %v, want %v", err, tt.want)
+			}
+			if tt.want != nil && !IsAuthError(err) {
+				t.Errorf("IsAuthError(%v) = false", err)
+			}
+		})
+	}
+}
+
+func TestVerifyReplay(t *testing.T) {
+	setupCredentials(t)
+
+	now := time.Now().UnixNano() / 1e6
+	v := NewVerifier(&config.DeviceAuthConfig{MaxSkew: maxSkew})
+
+	req := newFrame("edge-1", types.MSG_OPS_REPORT, "", edgeSecret, now, "dev-1")
+	if err := v.Verify(req); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+	if err := v.Verify(req); err != ErrReplayedFrame {
+		t.Fatalf("Verify() of the replay = %v, want %v", err, ErrReplayedFrame)
+	}
+
+	//the replay with a new timestamp is signed again, the message id is remembered.
+	req.Payload.Timestamp = now + 1
+	req.Payload.Sign = Sign(edgeSecret, req)
+	if err := v.Verify(req); err != ErrReplayedFrame {
+		t.Errorf("Verify() of the replay with new timestamp = %v, want %v", err, ErrReplayedFrame)
+	}
+
+	//the device has its own message ids.
+	req = newFrame("edge-1", types.MSG_OPS_REPORT, "dev-1", deviceSecret, now, "dev-1")
+	req.Payload.ID = "same-id"
+	req.Payload.Sign = Sign(deviceSecret, req)
+	if err := v.Verify(req); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+	edgeReq := newFrame("edge-1", types.MSG_OPS_REPORT, "", edgeSecret, now, "dev-1")
+	edgeReq.Payload.ID = "same-id"
+	edgeReq.Payload.Sign = Sign(edgeSecret, edgeReq)
+	if err := v.Verify(edgeReq); err != nil {
+		t.Errorf("Verify() of the edge with the message id of the device = %v", err)
+	}
+}
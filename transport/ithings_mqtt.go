@@ -0,0 +1,291 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/edgehook/ithings/common/config"
+	"github.com/edgehook/ithings/common/global"
+	"github.com/edgehook/ithings/common/grp"
+	"github.com/edgehook/ithings/common/types"
+	"github.com/edgehook/ithings/common/utils"
+	"github.com/edgehook/ithings/transport/deviceauth"
+	"github.com/jwzl/beehive/pkg/core"
+	beehiveCtx "github.com/jwzl/beehive/pkg/core/context"
+	"github.com/jwzl/wssocket/model"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultMqttPort    = "1883"
+	defaultMqttSSLPort = "8883"
+)
+
+type IthingsMqtt struct {
+	cfg    *config.MqttConfig
+	client mqtt.Client
+	pool   *grp.GoRoutinePool
+}
+
+// Register this module.
+func Register() {
+	im := &IthingsMqtt{
+		cfg: config.GetMqttConfig(),
+	}
+
+	core.Register(im)
+}
+
+// Name
+func (im *IthingsMqtt) Name() string {
+	return global.IMODULE_TRANSPORT
+}
+
+// Group
+func (im *IthingsMqtt) Group() string {
+	return global.IMODULE_TRANSPORT
+}
+
+// Enable indicates whether this module is enabled
+func (im *IthingsMqtt) Enable() bool {
+	//disable this module if there is no broker.
+	return im.cfg != nil
+}
+
+// Start this module.
+func (im *IthingsMqtt) Start() {
+	im.pool = grp.NewGoRoutinePool(im.cfg.MaxGoRoutine)
+	if im.pool == nil {
+		klog.Errorf("Create go routine pool failed, transport exit!")
+		return
+	}
+	defer im.pool.Close()
+
+	opts, err := im.buildClientOptions()
+	if err != nil {
+		klog.Errorf("Build mqtt client options with err: %v", err)
+		return
+	}
+
+	im.client = mqtt.NewClient(opts)
+	token := im.client.Connect()
+	if token.Wait() && token.Error() != nil {
+		klog.Errorf("Connect to mqtt broker with err: %v", token.Error())
+		return
+	}
+	defer im.client.Disconnect(250)
+
+	for {
+		select {
+		case <-beehiveCtx.Done():
+			klog.Warningf("transport module exit!")
+			return
+		default:
+		}
+
+		v, err := beehiveCtx.Receive(im.Name())
+		if err != nil {
+			klog.Errorf("transport receive message with err: %v", err)
+			continue
+		}
+
+		msg, isMsg := v.(*model.Message)
+		if !isMsg {
+			continue
+		}
+
+		im.handleServerMessage(msg)
+	}
+}
+
+func (im *IthingsMqtt) buildClientOptions() (*mqtt.ClientOptions, error) {
+	cfg := im.cfg
+	opts := mqtt.NewClientOptions()
+
+	opts.AddBroker(buildBrokerURL(cfg.Broker, cfg.TSLEnable))
+	opts.SetClientID(cfg.ClientID)
+	opts.SetUsername(cfg.User)
+	opts.SetPassword(cfg.Passwd)
+	opts.SetCleanSession(true)
+	opts.SetAutoReconnect(true)
+	opts.SetConnectRetry(true)
+	opts.SetConnectRetryInterval(5 * time.Second)
+	opts.SetMaxReconnectInterval(30 * time.Second)
+	opts.SetKeepAlive(30 * time.Second)
+	opts.SetOrderMatters(false)
+
+	if cfg.TSLEnable {
+		tlsConfig, err := buildTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	//subscribe the edge topics every time we connect to the broker,
+	//since the session is clean.
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		topic := types.EDGE_TOPIC_PREFIX + "/#"
+		token := c.Subscribe(topic, byte(cfg.QOS), im.onEdgeMessage)
+		if token.Wait() && token.Error() != nil {
+			klog.Errorf("Subscribe %s with err: %v", topic, token.Error())
+			return
+		}
+		klog.Infof("mqtt connected to %s, subscribe %s", cfg.Broker, topic)
+	})
+	opts.SetConnectionLostHandler(func(c mqtt.Client, err error) {
+		klog.Warningf("mqtt connection lost: %v", err)
+	})
+
+	return opts, nil
+}
+
+/*
+* onEdgeMessage
+* parse the edge message and forward it to core.
+ */
+func (im *IthingsMqtt) onEdgeMessage(c mqtt.Client, m mqtt.Message) {
+	topic := m.Topic()
+	payload := m.Payload()
+
+	err := im.pool.Run(func() {
+		levels := strings.Split(topic, "/")
+		if len(levels) < 7 {
+			klog.Warningf("Ignore the invalid topic %s", topic)
+			return
+		}
+
+		msg := &types.IMessage{}
+		if levels[6] == types.MSG_OPS_REPLY {
+			msg.Resp = types.ParseResponse(levels, payload)
+			if msg.Resp == nil {
+				return
+			}
+		} else {
+			msg.Req = types.ParseRequest(levels, payload)
+			if msg.Req == nil {
+				return
+			}
+			if !im.verifyRequest(msg.Req) {
+				return
+			}
+		}
+
+		beehiveCtx.Send(global.IMODULE_CORE, utils.BuildTrans2ICoreMessage(msg))
+	})
+	if err != nil {
+		klog.Errorf("Drop the message on %s, err: %v", topic, err)
+	}
+}
+
+/*
+* verifyRequest
+* check the signature of the register/report frames, the rejected
+* frames are replied and dropped.
+ */
+func (im *IthingsMqtt) verifyRequest(req *types.Request) bool {
+	err := deviceauth.VerifyRequest(req)
+	if err == nil {
+		return true
+	}
+
+	klog.Warningf("reject the %s frame from edge %s(%s): %v", req.Operation, req.EdgeID, req.Payload.Signer, err)
+	resp := req.BuildResponse(global.IRespCodeUnauthorized, err.Error())
+	if !deviceauth.IsAuthError(err) {
+		resp = req.BuildResponse(global.IRespCodeInternalError, global.IRespInternalErrString)
+	}
+	im.publish(resp.BuildTopic(), resp.BuildPayload())
+
+	return false
+}
+
+/*
+* handleServerMessage
+* publish the request/response to the edge.
+ */
+func (im *IthingsMqtt) handleServerMessage(msg *model.Message) {
+	if msg.GetOperation() != "send_edge_msg" {
+		klog.Warningf("transport ignore the operation %s", msg.GetOperation())
+		return
+	}
+
+	imsg, ok := msg.GetContent().(*types.IMessage)
+	if !ok || imsg == nil {
+		klog.Warningf("transport ignore the invalid message content")
+		return
+	}
+
+	if imsg.Req != nil {
+		im.publish(imsg.Req.BuildTopic(), imsg.Req.BuildPayload())
+	}
+	if imsg.Resp != nil {
+		im.publish(imsg.Resp.BuildTopic(), imsg.Resp.BuildPayload())
+	}
+}
+
+func (im *IthingsMqtt) publish(topic, payload string) {
+	if !im.client.IsConnectionOpen() {
+		klog.Warningf("mqtt is not connected, drop the message to %s", topic)
+		return
+	}
+
+	token := im.client.Publish(topic, byte(im.cfg.QOS), false, payload)
+	if token.WaitTimeout(global.DefaultEdgeMaxResponseTime) && token.Error() != nil {
+		klog.Errorf("Publish to %s with err: %v", topic, token.Error())
+	}
+}
+
+// buildBrokerURL fill the scheme and port of the broker address.
+func buildBrokerURL(broker string, ssl bool) string {
+	url := broker
+	scheme := "tcp://"
+	port := defaultMqttPort
+	if ssl {
+		scheme = "ssl://"
+		port = defaultMqttSSLPort
+	}
+
+	if !strings.Contains(url, "://") {
+		url = scheme + url
+	}
+	host := url[strings.Index(url, "://")+3:]
+	if !strings.Contains(host, ":") {
+		url = url + ":" + port
+	}
+
+	return url
+}
+
+func buildTLSConfig(cfg *config.MqttConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CaFilePath != "" {
+		ca, err := ioutil.ReadFile(cfg.CaFilePath)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("invalid ca file %s", cfg.CaFilePath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFilePath != "" && cfg.KeyFilePath != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFilePath, cfg.KeyFilePath)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -1,46 +1,130 @@
 package api
 
 import (
+	"net/http"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	v1types "github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/webserver/auth"
+	"github.com/edgehook/ithings/webserver/middlewares"
 	responce "github.com/edgehook/ithings/webserver/types"
 	"github.com/gin-gonic/gin"
 )
 
 func Login(c *gin.Context) {
-	// type auth struct {
-	// 	Username string `form:"username" json:"username"`
-	// 	Password string `form:"password" json:"password"`
-	// }
-
-	type resp struct {
-		AccessToken string `form:"accessToken" json:"accessToken"`
-	}
-	// var authInfo auth
-	// if err := c.Bind(&authInfo); err != nil {
-	// 	responce.FailWithMessage("Parameter error", c)
-	// 	return
-	// }
-
-	// body, err := json.Marshal(authInfo)
-	// if err != nil {
-	// 	responce.FailWithMessage("Json Marshal with  err %v", c)
-	// 	return
-	// }
-	// appHubResponse, err := isync.SendMsgToAppHub("login", string(body))
-
-	// if err != nil {
-	// 	responce.FailWithMessage(fmt.Sprintf("Send msg to AppHub with  err %v", err), c)
-	// 	return
-	// }
-
-	// if appHubResponse.StatusCode != "200" {
-	// 	responce.FailWithMessage(fmt.Sprintf("Send msg to AppHub with  err %v", appHubResponse.Msg), c)
-	// 	return
-	// }
-
-	// responce.OkWithData(&resp{
-	// 	AccessToken: appHubResponse.Msg,
-	// }, c)
-	responce.OkWithData(&resp{
-		AccessToken: "",
-	}, c)
+	var req v1types.LoginRequest
+	if err := c.ShouldBind(&req); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+
+	pair, err := auth.Login(req.Username, req.Password, c.ClientIP())
+	if err == auth.ErrTooManyAttempts {
+		responce.FailWithCodeAndMessage(http.StatusTooManyRequests, err.Error(), c)
+		return
+	}
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusUnauthorized, err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(pair, c)
+}
+
+func RefreshToken(c *gin.Context) {
+	var req v1types.RefreshTokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+
+	pair, err := auth.Refresh(req.RefreshToken)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusUnauthorized, err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(pair, c)
+}
+
+// Logout revoke the access token of this request and the refresh token in the body.
+func Logout(c *gin.Context) {
+	claims := middlewares.GetClaims(c)
+	if claims == nil {
+		responce.Ok(c)
+		return
+	}
+
+	var req v1types.LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBind(&req); err != nil {
+			responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+			return
+		}
+	}
+
+	if err := auth.Logout(claims, req.RefreshToken); err != nil {
+		if err == auth.ErrInvalidToken {
+			responce.FailWithCodeAndMessage(http.StatusBadRequest, err.Error(), c)
+			return
+		}
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
+
+func currentUser(c *gin.Context) (*db.User, bool) {
+	subject := responce.GetTokenSubject(c)
+	if subject == nil {
+		responce.FailWithCodeAndMessage(http.StatusUnauthorized, "not authenticated", c)
+		return nil, false
+	}
+
+	user, err := db.GetUserById(subject.UserID)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "user not found", c)
+		return nil, false
+	}
+	return user, true
+}
+
+func GetCurrentUser(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	responce.OkWithData(user, c)
+}
+
+// ChangePassword change the password of the current user, all its tokens are revoked.
+func ChangePassword(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req v1types.PasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	if !auth.CheckPassword(user, req.OldPassword) {
+		responce.FailWithCodeAndMessage(http.StatusForbidden, "old password is wrong", c)
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, err.Error(), c)
+		return
+	}
+	if err := db.SaveUserPassword(user.ID, hash); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
 }
@@ -0,0 +1,146 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/edgehook/ithings/alert"
+	db "github.com/edgehook/ithings/common/dbm/model"
+	v1types "github.com/edgehook/ithings/common/types/v1"
+	responce "github.com/edgehook/ithings/webserver/types"
+	"github.com/gin-gonic/gin"
+)
+
+func GetAlerts(c *gin.Context) {
+	var pageInfo responce.PageInfo
+	if err := c.ShouldBindQuery(&pageInfo); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	pageInfo.Normalize()
+
+	alerts, err := db.GetAlertByPageAndKeywords(pageInfo.Page, pageInfo.Limit, pageInfo.Keywords)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+	total, err := db.GetAlertCountByKeywords(pageInfo.Keywords)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(&responce.PageResult{
+		List:  alerts,
+		Total: total,
+	}, c)
+}
+
+func GetAlert(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	alertConfig, err := db.GetAlertById(id)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "alert not found", c)
+		return
+	}
+
+	responce.OkWithData(alertConfig, c)
+}
+
+func AddAlert(c *gin.Context) {
+	var req v1types.Alert
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	if err := alert.ValidateNotification(req.Notification); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "invalid notification: "+err.Error(), c)
+		return
+	}
+	if err := alert.ValidateEscalation(req.Escalation); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "invalid escalation: "+err.Error(), c)
+		return
+	}
+	if _, err := db.GetAlertByName(req.Name); err == nil {
+		responce.FailWithCodeAndMessage(http.StatusConflict, "alert name already exists", c)
+		return
+	}
+
+	alertConfig := &db.AlertConfig{
+		Name:         req.Name,
+		Description:  req.Description,
+		Level:        *req.Level,
+		Notification: req.Notification,
+		Escalation:   req.Escalation,
+	}
+	if err := db.AddAlert(alertConfig); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(alertConfig, c)
+}
+
+func UpdateAlert(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req v1types.Alert
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	if err := alert.ValidateNotification(req.Notification); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "invalid notification: "+err.Error(), c)
+		return
+	}
+	if err := alert.ValidateEscalation(req.Escalation); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "invalid escalation: "+err.Error(), c)
+		return
+	}
+	if _, err := db.GetAlertById(id); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "alert not found", c)
+		return
+	}
+	if exist, err := db.GetAlertByName(req.Name); err == nil && exist.ID != id {
+		responce.FailWithCodeAndMessage(http.StatusConflict, "alert name already exists", c)
+		return
+	}
+
+	err := db.SaveAlert(id, &db.AlertConfig{
+		Name:         req.Name,
+		Description:  req.Description,
+		Level:        *req.Level,
+		Notification: req.Notification,
+		Escalation:   req.Escalation,
+	})
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
+
+func DeleteAlert(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if _, err := db.GetAlertById(id); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "alert not found", c)
+		return
+	}
+	if err := db.DeleteAlert(id); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
@@ -0,0 +1,183 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/edgehook/ithings/alert"
+	db "github.com/edgehook/ithings/common/dbm/model"
+	v1types "github.com/edgehook/ithings/common/types/v1"
+	responce "github.com/edgehook/ithings/webserver/types"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// failWithTransitError response the error of the alert status transition.
+func failWithTransitError(err error, c *gin.Context) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "alert log not found", c)
+	case errors.Is(err, alert.ErrIllegalTransition), errors.Is(err, alert.ErrStatusChanged):
+		responce.FailWithCodeAndMessage(http.StatusConflict, err.Error(), c)
+	default:
+		responce.FailWithMessage(err.Error(), c)
+	}
+}
+
+type AlertLogQuery struct {
+	responce.PageInfo
+	Name    string `form:"name" json:"name"`
+	EdgeID  string `form:"edgeId" json:"edgeId"`
+	Status  *int32 `form:"status" json:"status"`
+	Level   *int64 `form:"level" json:"level"`
+	LogType string `form:"type" json:"type"`
+	BeginTs *int64 `form:"beginTs" json:"beginTs"`
+	EndTs   *int64 `form:"endTs" json:"endTs"`
+}
+
+func GetAlertLogs(c *gin.Context) {
+	var query AlertLogQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	query.Normalize()
+
+	scope := responce.GetDeviceScope(c)
+	alertLogs, err := db.GetAlertLogByPageAndCondition(query.Page, query.Limit, query.Name, query.EdgeID,
+		query.Status, query.Level, query.BeginTs, query.EndTs, query.LogType, scope)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+	total, err := db.GetAlertLogCountByCondition(query.Name, query.EdgeID, query.Status, query.Level,
+		query.BeginTs, query.EndTs, query.LogType, scope)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(&responce.PageResult{
+		List:  alertLogs,
+		Total: total,
+	}, c)
+}
+
+func GetAlertLog(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	alertLog, err := alert.GetAlertLog(id)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "alert log not found", c)
+		return
+	}
+
+	responce.OkWithData(alertLog, c)
+}
+
+func UpdateAlertLog(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req v1types.AlertLog
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	if _, err := alert.GetAlertLog(id); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "alert log not found", c)
+		return
+	}
+
+	if req.Record != "" {
+		if err := db.SaveAlertLog(id, "", req.Record, nil, nil, ""); err != nil {
+			responce.FailWithMessage(err.Error(), c)
+			return
+		}
+	}
+	//the status is changed by the lifecycle.
+	if req.Status != nil {
+		if _, err := alert.Transit(id, *req.Status, getOperator(c, req.Operator), req.Comment); err != nil {
+			failWithTransitError(err, c)
+			return
+		}
+	}
+	//notify the alert when its level is changed.
+	if req.Level != nil {
+		if err := alert.UpdateLevel(id, *req.Level); err != nil {
+			responce.FailWithMessage(err.Error(), c)
+			return
+		}
+	}
+
+	responce.Ok(c)
+}
+
+// getOperator return the authenticated user as the operator, otherwise the operator in the request.
+func getOperator(c *gin.Context, operator string) string {
+	if subject := responce.GetTokenSubject(c); subject != nil {
+		return subject.Username
+	}
+	return operator
+}
+
+// transitAlertLog change the status of the alert log by the transition.
+func transitAlertLog(c *gin.Context, transit func(id int64, operator, comment string) (*db.AlertLog, error)) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req v1types.AlertTransition
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+			return
+		}
+	}
+
+	alertLog, err := transit(id, getOperator(c, req.Operator), req.Comment)
+	if err != nil {
+		failWithTransitError(err, c)
+		return
+	}
+
+	responce.OkWithData(alertLog, c)
+}
+
+func AcknowledgeAlertLog(c *gin.Context) {
+	transitAlertLog(c, alert.Acknowledge)
+}
+
+func ResolveAlertLog(c *gin.Context) {
+	transitAlertLog(c, alert.Resolve)
+}
+
+func InvalidateAlertLog(c *gin.Context) {
+	transitAlertLog(c, alert.Invalidate)
+}
+
+// GetAlertLogHistory get all status transitions of the alert log.
+func GetAlertLogHistory(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if _, err := alert.GetAlertLog(id); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "alert log not found", c)
+		return
+	}
+	histories, err := db.GetAlertHistoryByAlertLogId(id)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(histories, c)
+}
@@ -0,0 +1,150 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/edgehook/ithings/alert"
+	db "github.com/edgehook/ithings/common/dbm/model"
+	v1types "github.com/edgehook/ithings/common/types/v1"
+	responce "github.com/edgehook/ithings/webserver/types"
+	"github.com/gin-gonic/gin"
+)
+
+// toAlertSilence convert the request into the silence, it's enabled by default.
+func toAlertSilence(req *v1types.AlertSilence) *db.AlertSilence {
+	enable := true
+	if req.Enable != nil {
+		enable = *req.Enable
+	}
+
+	return &db.AlertSilence{
+		Name:           req.Name,
+		Description:    req.Description,
+		Enable:         enable,
+		EdgeId:         req.EdgeId,
+		DeviceId:       req.DeviceId,
+		DeviceModelId:  req.DeviceModelId,
+		Level:          req.Level,
+		StartTimeStamp: req.StartTimeStamp,
+		EndTimeStamp:   req.EndTimeStamp,
+		DailyStartTime: req.DailyStartTime,
+		DailyEndTime:   req.DailyEndTime,
+	}
+}
+
+func GetAlertSilences(c *gin.Context) {
+	var pageInfo responce.PageInfo
+	if err := c.ShouldBindQuery(&pageInfo); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	pageInfo.Normalize()
+
+	silences, err := db.GetAlertSilenceByPageAndKeywords(pageInfo.Page, pageInfo.Limit, pageInfo.Keywords)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+	total, err := db.GetAlertSilenceCountByKeywords(pageInfo.Keywords)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(&responce.PageResult{
+		List:  silences,
+		Total: total,
+	}, c)
+}
+
+func GetAlertSilence(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	silence, err := db.GetAlertSilenceById(id)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "alert silence not found", c)
+		return
+	}
+
+	responce.OkWithData(silence, c)
+}
+
+func AddAlertSilence(c *gin.Context) {
+	var req v1types.AlertSilence
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+
+	silence := toAlertSilence(&req)
+	if err := alert.ValidateSilence(silence); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, err.Error(), c)
+		return
+	}
+	if _, err := db.GetAlertSilenceByName(req.Name); err == nil {
+		responce.FailWithCodeAndMessage(http.StatusConflict, "alert silence name already exists", c)
+		return
+	}
+
+	if err := db.AddAlertSilence(silence); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(silence, c)
+}
+
+func UpdateAlertSilence(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req v1types.AlertSilence
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+
+	silence := toAlertSilence(&req)
+	if err := alert.ValidateSilence(silence); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, err.Error(), c)
+		return
+	}
+	if _, err := db.GetAlertSilenceById(id); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "alert silence not found", c)
+		return
+	}
+	if exist, err := db.GetAlertSilenceByName(req.Name); err == nil && exist.ID != id {
+		responce.FailWithCodeAndMessage(http.StatusConflict, "alert silence name already exists", c)
+		return
+	}
+
+	if err := db.SaveAlertSilence(id, silence); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
+
+func DeleteAlertSilence(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if _, err := db.GetAlertSilenceById(id); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "alert silence not found", c)
+		return
+	}
+	if err := db.DeleteAlertSilence(id); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
@@ -0,0 +1,184 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	v1types "github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/webserver/auth"
+	responce "github.com/edgehook/ithings/webserver/types"
+	"github.com/gin-gonic/gin"
+)
+
+// checkAPIKeyRequest validate the request and encode the scopes.
+func checkAPIKeyRequest(req *v1types.APIKeyRequest) (string, bool, string) {
+	if req.RateLimit < 0 || req.Burst < 0 {
+		return "", false, "invalid rate limit"
+	}
+	if req.ExpireTimeStamp < 0 {
+		return "", false, "invalid expireTimeStamp"
+	}
+
+	scopes, err := auth.ValidateScopes(req.Scopes)
+	if err != nil {
+		return "", false, err.Error()
+	}
+	return scopes, true, ""
+}
+
+func GetAPIKeys(c *gin.Context) {
+	var pageInfo responce.PageInfo
+	if err := c.ShouldBindQuery(&pageInfo); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	pageInfo.Normalize()
+
+	keys, err := db.GetAPIKeyByPageAndKeywords(pageInfo.Page, pageInfo.Limit, pageInfo.Keywords)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+	total, err := db.GetAPIKeyCountByKeywords(pageInfo.Keywords)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(&responce.PageResult{
+		List:  keys,
+		Total: total,
+	}, c)
+}
+
+func GetAPIKey(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	key, err := db.GetAPIKeyById(id)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "api key not found", c)
+		return
+	}
+
+	responce.OkWithData(key, c)
+}
+
+/*
+* AddAPIKey
+* create the API key owned by the current user, the key is returned only
+* once in the response.
+ */
+func AddAPIKey(c *gin.Context) {
+	subject := responce.GetTokenSubject(c)
+	if subject == nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "the api key needs an authenticated owner", c)
+		return
+	}
+
+	var req v1types.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == "" {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	scopes, ok, msg := checkAPIKeyRequest(&req)
+	if !ok {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, msg, c)
+		return
+	}
+	if req.ExpireTimeStamp > 0 && req.ExpireTimeStamp <= time.Now().UnixNano()/1e6 {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "expireTimeStamp is in the past", c)
+		return
+	}
+	if _, err := db.GetAPIKeyByName(req.Name); err == nil {
+		responce.FailWithCodeAndMessage(http.StatusConflict, "api key name already exists", c)
+		return
+	}
+
+	key, prefix, hash := auth.GenerateAPIKey()
+	apiKey := &db.APIKey{
+		Name:            req.Name,
+		Description:     req.Description,
+		Prefix:          prefix,
+		KeyHash:         hash,
+		Scopes:          scopes,
+		OwnerId:         subject.UserID,
+		RateLimit:       req.RateLimit,
+		Burst:           req.Burst,
+		ExpireTimeStamp: req.ExpireTimeStamp,
+	}
+	if req.Disabled != nil {
+		apiKey.Disabled = *req.Disabled
+	}
+	if err := db.AddAPIKey(apiKey); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(&v1types.APIKeyCreated{
+		APIKey: apiKey,
+		Key:    key,
+	}, c)
+}
+
+// UpdateAPIKey update the scopes, status, rate limit and expiration, the name can't be changed.
+func UpdateAPIKey(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req v1types.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	scopes, ok, msg := checkAPIKeyRequest(&req)
+	if !ok {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, msg, c)
+		return
+	}
+
+	apiKey, err := db.GetAPIKeyById(id)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "api key not found", c)
+		return
+	}
+	apiKey.Description = req.Description
+	apiKey.Scopes = scopes
+	apiKey.RateLimit = req.RateLimit
+	apiKey.Burst = req.Burst
+	apiKey.ExpireTimeStamp = req.ExpireTimeStamp
+	if req.Disabled != nil {
+		apiKey.Disabled = *req.Disabled
+	}
+
+	if err := db.SaveAPIKey(id, apiKey); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
+
+// DeleteAPIKey revoke the API key.
+func DeleteAPIKey(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if _, err := db.GetAPIKeyById(id); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "api key not found", c)
+		return
+	}
+	if err := db.DeleteAPIKey(id); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
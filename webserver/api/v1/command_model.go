@@ -0,0 +1,129 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	v1types "github.com/edgehook/ithings/common/types/v1"
+	responce "github.com/edgehook/ithings/webserver/types"
+	"github.com/gin-gonic/gin"
+)
+
+func toCommandModel(cmd *v1types.DeviceCommandModel, serviceModelID int64) *db.CommandModel {
+	cm := &db.CommandModel{
+		Name:           cmd.Name,
+		Description:    cmd.Description,
+		ServiceModelId: serviceModelID,
+	}
+	if len(cmd.RequestParam) > 0 {
+		if d, err := json.Marshal(cmd.RequestParam); err == nil {
+			cm.RequestParam = string(d)
+		}
+	}
+
+	return cm
+}
+
+func GetCommandModels(c *gin.Context) {
+	sm, ok := getServiceModel(c)
+	if !ok {
+		return
+	}
+
+	cms, err := db.GetCommandModelByServiceModelId(sm.ID)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(cms, c)
+}
+
+func AddCommandModel(c *gin.Context) {
+	sm, ok := getServiceModel(c)
+	if !ok {
+		return
+	}
+
+	var command v1types.DeviceCommandModel
+	if err := c.ShouldBindJSON(&command); err != nil || command.Name == "" {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	if db.IsExistCommandModel(sm.ID, command.Name) {
+		responce.FailWithCodeAndMessage(http.StatusConflict, "command model already exists", c)
+		return
+	}
+
+	cm := toCommandModel(&command, sm.ID)
+	if err := db.AddCommandModel(cm); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(cm, c)
+}
+
+func UpdateCommandModel(c *gin.Context) {
+	sm, ok := getServiceModel(c)
+	if !ok {
+		return
+	}
+	cid, ok := getIDParam(c, "cid")
+	if !ok {
+		return
+	}
+
+	var command v1types.DeviceCommandModel
+	if err := c.ShouldBindJSON(&command); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+
+	old, err := db.GetCommandModelByCommandId(cid)
+	if err != nil || old.ServiceModelId != sm.ID {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "command model not found", c)
+		return
+	}
+
+	//the command name can't be changed.
+	cm := toCommandModel(&command, sm.ID)
+	cm.ID = cid
+	cm.Name = old.Name
+	cm.ResponseParam = old.ResponseParam
+	if err := db.SaveCommandModel(cid, cm); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
+
+func DeleteCommandModel(c *gin.Context) {
+	sm, ok := getServiceModel(c)
+	if !ok {
+		return
+	}
+	cid, ok := getIDParam(c, "cid")
+	if !ok {
+		return
+	}
+
+	cm, err := db.GetCommandModelByCommandId(cid)
+	if err != nil || cm.ServiceModelId != sm.ID {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "command model not found", c)
+		return
+	}
+	if isDeviceModelInUse(sm.DeviceModelId) {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "there are devices using this model", c)
+		return
+	}
+
+	if err := db.DeleteCommandModel(cid); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
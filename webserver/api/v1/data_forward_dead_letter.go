@@ -0,0 +1,94 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/dataforward"
+	responce "github.com/edgehook/ithings/webserver/types"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type DeadLetterQuery struct {
+	responce.PageInfo
+	DataForwardId string `form:"dataForwardId" json:"dataForwardId"`
+	DeviceId      string `form:"deviceId" json:"deviceId"`
+}
+
+func GetDataForwardDeadLetters(c *gin.Context) {
+	var query DeadLetterQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	query.Normalize()
+
+	deadLetters, err := db.GetDataForwardDeadLetterByPageAndCondition(query.Page, query.Limit,
+		query.DataForwardId, query.DeviceId)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+	total, err := db.GetDataForwardDeadLetterCountByCondition(query.DataForwardId, query.DeviceId)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(&responce.PageResult{
+		List:  deadLetters,
+		Total: total,
+	}, c)
+}
+
+func GetDataForwardDeadLetter(c *gin.Context) {
+	deadLetter, err := db.GetDataForwardDeadLetterById(c.Param("id"))
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "dead letter not found", c)
+		return
+	}
+
+	responce.OkWithData(deadLetter, c)
+}
+
+func ReplayDataForwardDeadLetter(c *gin.Context) {
+	err := dataforward.ReplayDeadLetter(c.Param("id"))
+	switch {
+	case err == nil:
+		responce.Ok(c)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "dead letter not found", c)
+	case errors.Is(err, dataforward.ErrDataForwardNotEnabled), errors.Is(err, dataforward.ErrDeadLetterReplaying):
+		responce.FailWithCodeAndMessage(http.StatusConflict, err.Error(), c)
+	default:
+		responce.FailWithMessage(err.Error(), c)
+	}
+}
+
+func DeleteDataForwardDeadLetter(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := db.GetDataForwardDeadLetterById(id); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "dead letter not found", c)
+		return
+	}
+
+	if err := db.DeleteDataForwardDeadLetter(id); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
+
+// PurgeDataForwardDeadLetters delete the dead letters of the data forward, or all.
+func PurgeDataForwardDeadLetters(c *gin.Context) {
+	count, err := db.PurgeDataForwardDeadLetter(c.Query("dataForwardId"))
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(map[string]int64{"deleted": count}, c)
+}
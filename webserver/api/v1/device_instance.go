@@ -0,0 +1,346 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/global"
+	"github.com/edgehook/ithings/common/tsdbm"
+	"github.com/edgehook/ithings/common/types"
+	v1types "github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/common/utils"
+	"github.com/edgehook/ithings/core/devicetwin"
+	"github.com/edgehook/ithings/core/eventdetector"
+	"github.com/edgehook/ithings/core/syncreq"
+	"github.com/edgehook/ithings/core/twincache"
+	"github.com/edgehook/ithings/transport/deviceauth"
+	responce "github.com/edgehook/ithings/webserver/types"
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+)
+
+type DeviceQuery struct {
+	responce.PageInfo
+	ProtocolType string `form:"protocolType" json:"protocolType"`
+	EdgeID       string `form:"edgeId" json:"edgeId"`
+	ModelID      string `form:"modelId" json:"modelId"`
+	DeviceStatus string `form:"status" json:"status"`
+}
+
+type CopyDeviceRequest struct {
+	EdgeID string `form:"edgeId" json:"edgeId" binding:"required"`
+}
+
+func buildDeviceLifeControl(di *db.DeviceInstance, action int, spec *v1types.DeviceSpecMeta) *types.Request {
+	req := types.BuildRequest(di.EdgeID, di.ProtocolType, types.MSG_RESOURCE_DEVICE, types.MSG_OPS_LIFE_CONTROL)
+	req.SetContent(&v1types.DeviceLifeControlMsg{
+		DeviceID: di.DeviceID,
+		Action:   action,
+		Spec:     spec,
+	})
+
+	return req
+}
+
+/*
+* sendDeviceLifeControl
+* notify the edge to start/stop this device, and wait for the response.
+ */
+func sendDeviceLifeControl(di *db.DeviceInstance, action int, spec *v1types.DeviceSpecMeta) error {
+	_, err := syncreq.SendRequest(buildDeviceLifeControl(di, action, spec), global.DefaultEdgeMaxResponseTime)
+	return err
+}
+
+/*
+* notifyDeviceLifeControl
+* notify the edge to create/update/delete this device without waiting,
+* the edge gets the devices when it registers if it's offline now.
+ */
+func notifyDeviceLifeControl(di *db.DeviceInstance, action int, spec *v1types.DeviceSpecMeta) {
+	deviceID := di.DeviceID
+	syncreq.SendRequestAsync(buildDeviceLifeControl(di, action, spec), global.DefaultEdgeMaxResponseTime,
+		func(resp *types.Response, err error) {
+			if err != nil {
+				klog.Warningf("notify edge of device %s with action %d, err: %v", deviceID, action, err)
+			}
+		})
+}
+
+func GetDeviceInstances(c *gin.Context) {
+	var query DeviceQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	query.Normalize()
+
+	var modelID *int64
+	if query.ModelID != "" {
+		id, err := strconv.ParseInt(query.ModelID, 10, 64)
+		if err != nil {
+			responce.FailWithCodeAndMessage(http.StatusBadRequest, "invalid modelId", c)
+			return
+		}
+		modelID = &id
+	}
+
+	scope := responce.GetDeviceScope(c)
+	dis, err := db.GetDeviceInstanceByPageAndCondition(query.Page, query.Limit, query.Keywords,
+		query.ProtocolType, query.EdgeID, modelID, query.DeviceStatus, scope)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+	total, err := db.GetDeviceInstanceCountByCondition(query.Keywords, query.ProtocolType,
+		query.EdgeID, modelID, query.DeviceStatus, scope)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(&responce.PageResult{
+		List:  dis,
+		Total: total,
+	}, c)
+}
+
+func GetDeviceInstance(c *gin.Context) {
+	di, err := db.GetDeviceInstanceAllInfo(c.Param("id"))
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "device not found", c)
+		return
+	}
+
+	responce.OkWithData(di, c)
+}
+
+func AddDeviceInstance(c *gin.Context) {
+	var spec v1types.DeviceSpec
+	if err := c.ShouldBindJSON(&spec); err != nil || spec.Name == "" || spec.EdgeID == "" {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+
+	if db.IsExistDeviceInstanceByNameAndEdgeId(spec.Name, spec.EdgeID) {
+		responce.FailWithCodeAndMessage(http.StatusConflict, "device name already exists in this edge", c)
+		return
+	}
+	dm, err := db.GetDeviceModelAllInfoByName(spec.DeviceModelRef)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, global.ErrNoSuchDeviceModel.Error(), c)
+		return
+	}
+
+	di := &db.DeviceInstance{
+		DeviceID:                 utils.NewUUID(),
+		Name:                     spec.Name,
+		EdgeID:                   spec.EdgeID,
+		DeviceOS:                 spec.DeviceOS,
+		DeviceCategory:           spec.DeviceCatagory,
+		DeviceVersion:            spec.DeviceVersion,
+		DeviceIdentificationCode: spec.DeviceIdentificationCode,
+		Description:              &spec.Description,
+		GroupName:                spec.GroupName,
+		GroupID:                  spec.GroupID,
+		Creator:                  spec.Creator,
+		DeviceType:               spec.DeviceType,
+		GatewayID:                spec.GatewayID,
+		GatewayName:              spec.GatewayName,
+		LifeTimeOfDesiredValue:   spec.LifeTimeOfDesiredValue,
+		DeviceModelRef:           dm.Name,
+		ProtocolType:             spec.ProtocolType,
+		Protocol:                 &spec.Protocol,
+		DeviceStatus:             global.DeviceStatusInactive,
+		State:                    global.DeviceStateStopped,
+		DeviceModelId:            dm.ID,
+	}
+	if len(spec.Tags) > 0 {
+		tags, _ := json.Marshal(spec.Tags)
+		di.Tags = string(tags)
+	}
+	if !responce.GetDeviceScope(c).Contains(di) {
+		responce.FailWithCodeAndMessage(http.StatusForbidden, "device is out of your scope", c)
+		return
+	}
+	if spec.Secret != "" {
+		secret, err := deviceauth.EncryptSecret(spec.Secret)
+		if err != nil {
+			responce.FailWithCodeAndMessage(http.StatusBadRequest, err.Error(), c)
+			return
+		}
+		di.DeviceAuthType = deviceauth.AuthTypeHMAC
+		di.Secret = secret
+	}
+
+	if err := db.AddDeviceInstance(di); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	ec := spec.ExtensionConfig
+	if ec == nil {
+		ec = &v1types.ExtensionConfig{}
+	}
+	if err := ec.StoreExtensionConfig(dm, di.DeviceID); err != nil {
+		db.DeleteDeviceInstance(di.DeviceID)
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	//the edge will get this device when it registers if it's offline now.
+	if all, err := db.GetDeviceInstanceAllInfo(di.DeviceID); err == nil {
+		notifyDeviceLifeControl(&all, global.DeviceCreate, v1types.NewDeviceSpecMeta(&all, dm))
+	}
+
+	responce.OkWithData(di, c)
+}
+
+func DeleteDeviceInstance(c *gin.Context) {
+	di, err := db.GetDeviceInstanceByDeviceId(c.Param("id"))
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "device not found", c)
+		return
+	}
+
+	if err := db.DeleteDeviceInstance(di.DeviceID); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+	devicetwin.RemoveDevice(di.DeviceID)
+	eventdetector.RemoveDevice(di.DeviceID)
+	twincache.RemoveDevice(di.DeviceID)
+	if err := tsdbm.DeleteTwin(di.DeviceID, "", ""); err != nil {
+		klog.Warningf("delete the twins of device %s with err: %v", di.DeviceID, err)
+	}
+	if err := tsdbm.DeleteEvent(di.DeviceID, "", ""); err != nil {
+		klog.Warningf("delete the events of device %s with err: %v", di.DeviceID, err)
+	}
+
+	notifyDeviceLifeControl(&di, global.DeviceDelete, nil)
+
+	responce.Ok(c)
+}
+
+/*
+* UpdateDeviceInstanceConfig
+* update the protocol of device or the access config of property/event/command.
+ */
+func UpdateDeviceInstanceConfig(c *gin.Context) {
+	var cfg v1types.InstanceConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	cfg.DeviceId = c.Param("id")
+
+	di, err := db.GetDeviceInstanceByDeviceId(cfg.DeviceId)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "device not found", c)
+		return
+	}
+
+	if cfg.InstanceType == v1types.InstanceTypeDevice {
+		err = db.UpdateDeviceInstanceProtocol(di.DeviceID, cfg.AccessConfig)
+	} else {
+		si, serr := db.GetServiceInstanceByDeviceIDAndName(di.DeviceID, cfg.ServiceName)
+		if serr != nil {
+			responce.FailWithCodeAndMessage(http.StatusNotFound, "service not found", c)
+			return
+		}
+
+		switch cfg.InstanceType {
+		case v1types.InstanceTypeProperty:
+			if !db.IsExistPropertyInstance(si.ID, cfg.Name) {
+				responce.FailWithCodeAndMessage(http.StatusNotFound, "property not found", c)
+				return
+			}
+			err = db.UpdatePropertyAccessConfig(si.ID, cfg.Name, cfg.AccessConfig)
+		case v1types.InstanceTypeEvent:
+			if !db.IsExistEventInstance(si.ID, cfg.Name) {
+				responce.FailWithCodeAndMessage(http.StatusNotFound, "event not found", c)
+				return
+			}
+			err = db.UpdateEventAccessConfig(si.ID, cfg.Name, cfg.AccessConfig)
+		case v1types.InstanceTypeCommand:
+			if !db.IsExistCommandInstance(si.ID, cfg.Name) {
+				responce.FailWithCodeAndMessage(http.StatusNotFound, "command not found", c)
+				return
+			}
+			err = db.UpdateCommandAccessConfig(si.ID, cfg.Name, cfg.AccessConfig)
+		default:
+			responce.FailWithCodeAndMessage(http.StatusBadRequest, "invalid instance type", c)
+			return
+		}
+	}
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	//push the new spec to edge.
+	if all, err := db.GetDeviceInstanceAllInfo(di.DeviceID); err == nil {
+		dm, _ := db.GetDeviceModelAllInfoByID(all.DeviceModelId)
+		notifyDeviceLifeControl(&all, global.DeviceUpdate, v1types.NewDeviceSpecMeta(&all, dm))
+	}
+
+	responce.Ok(c)
+}
+
+func StartDeviceInstance(c *gin.Context) {
+	controlDeviceInstance(c, global.DeviceStart, global.DeviceStateStarted)
+}
+
+func StopDeviceInstance(c *gin.Context) {
+	controlDeviceInstance(c, global.DeviceStop, global.DeviceStateStopped)
+}
+
+func controlDeviceInstance(c *gin.Context, action int, state string) {
+	di, err := db.GetDeviceInstanceByDeviceId(c.Param("id"))
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "device not found", c)
+		return
+	}
+
+	if err := sendDeviceLifeControl(&di, action, nil); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	if err := db.UpdateDeviceInstance(di.DeviceID, &db.DeviceInstance{State: state}); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
+
+func CopyDeviceInstance(c *gin.Context) {
+	var copyReq CopyDeviceRequest
+	if err := c.ShouldBindJSON(&copyReq); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+
+	di, err := db.GetDeviceInstanceAllInfo(c.Param("id"))
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "device not found", c)
+		return
+	}
+	if !responce.GetDeviceScope(c).Contains(&db.DeviceInstance{EdgeID: copyReq.EdgeID, GroupID: di.GroupID}) {
+		responce.FailWithCodeAndMessage(http.StatusForbidden, "edge is out of your scope", c)
+		return
+	}
+	if db.IsExistDeviceInstanceByNameAndEdgeId(di.Name, copyReq.EdgeID) {
+		responce.FailWithCodeAndMessage(http.StatusConflict, "device name already exists in this edge", c)
+		return
+	}
+
+	if err := v1types.CopyDeviceInstance(&di, copyReq.EdgeID); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
@@ -0,0 +1,147 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	v1types "github.com/edgehook/ithings/common/types/v1"
+	responce "github.com/edgehook/ithings/webserver/types"
+	"github.com/gin-gonic/gin"
+)
+
+// getIDParam parse the int64 id in the path.
+func getIDParam(c *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "invalid "+name, c)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// isDeviceModelInUse check whether there are devices created from this model.
+func isDeviceModelInUse(modelID int64) bool {
+	dis, err := db.GetDeviceInstancesByDeviceModelId(modelID)
+	return err == nil && len(dis) > 0
+}
+
+func GetDeviceModels(c *gin.Context) {
+	var pageInfo responce.PageInfo
+	if err := c.ShouldBindQuery(&pageInfo); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	pageInfo.Normalize()
+
+	models, err := db.GetModelByPageAndKeywords(pageInfo.Page, pageInfo.Limit, pageInfo.Keywords)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+	total, err := db.GetDeviceModelCountByKeywords(pageInfo.Keywords)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(&responce.PageResult{
+		List:  models,
+		Total: total,
+	}, c)
+}
+
+func GetDeviceModel(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	dm, err := db.GetDeviceModelAllInfoByID(id)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "device model not found", c)
+		return
+	}
+
+	responce.OkWithData(dm, c)
+}
+
+func AddDeviceModel(c *gin.Context) {
+	var deviceModel v1types.DeviceModel
+	if err := c.ShouldBindJSON(&deviceModel); err != nil || deviceModel.Name == "" {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	if db.IsExistDeviceModelByName(deviceModel.Name) {
+		responce.FailWithCodeAndMessage(http.StatusConflict, "device model name already exists", c)
+		return
+	}
+
+	if err := v1types.AddAllDeviceModel(&deviceModel); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
+
+func UpdateDeviceModel(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var deviceModel v1types.DeviceModel
+	if err := c.ShouldBindJSON(&deviceModel); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+
+	dm, err := db.GetDeviceModelById(id)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "device model not found", c)
+		return
+	}
+	if dm.Name != deviceModel.Name && db.IsExistDeviceModelByName(deviceModel.Name) {
+		responce.FailWithCodeAndMessage(http.StatusConflict, "device model name already exists", c)
+		return
+	}
+
+	err = db.SaveDeviceModel(id, &db.DeviceModel{
+		Name:         deviceModel.Name,
+		Manufacturer: deviceModel.Manufacturer,
+		Industry:     deviceModel.Industry,
+		TagNumber:    deviceModel.TagNumber,
+		GroupID:      deviceModel.GroupID,
+		DataFormat:   deviceModel.DataFormat,
+		Description:  deviceModel.Description,
+		Creator:      deviceModel.Creator,
+		DeviceNumber: deviceModel.DeviceNumber,
+	})
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
+
+func DeleteDeviceModel(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if isDeviceModelInUse(id) {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "there are devices using this model", c)
+		return
+	}
+
+	if err := db.DeleteDeviceModel(id); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
@@ -0,0 +1,126 @@
+package v1
+
+import (
+	"net/http"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	v1types "github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/transport/deviceauth"
+	responce "github.com/edgehook/ithings/webserver/types"
+	"github.com/gin-gonic/gin"
+)
+
+func getDeviceParam(c *gin.Context) (*db.DeviceInstance, bool) {
+	di, err := db.GetDeviceInstanceByDeviceId(c.Param("id"))
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "device not found", c)
+		return nil, false
+	}
+
+	return &di, true
+}
+
+// GetDeviceSecret return the auth type of the device, the secret isn't shown.
+func GetDeviceSecret(c *gin.Context) {
+	di, ok := getDeviceParam(c)
+	if !ok {
+		return
+	}
+
+	responce.OkWithData(deviceauth.GetDeviceSecret(di), c)
+}
+
+/*
+* RotateDeviceSecret
+* generate or rotate the secret of the device, the previous secret is
+* accepted in the grace period unless immediate. The secret and the MQTT
+* password are returned only once in the response.
+ */
+func RotateDeviceSecret(c *gin.Context) {
+	var req v1types.DeviceSecretRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+			return
+		}
+	}
+
+	di, ok := getDeviceParam(c)
+	if !ok {
+		return
+	}
+
+	secret, err := deviceauth.RotateDeviceSecret(di, req.Secret, req.Immediate)
+	if err == deviceauth.ErrSecretTooShort {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, err.Error(), c)
+		return
+	}
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(secret, c)
+}
+
+// RevokeDeviceSecret revoke the secret, the frames of the device are rejected.
+func RevokeDeviceSecret(c *gin.Context) {
+	di, ok := getDeviceParam(c)
+	if !ok {
+		return
+	}
+
+	if err := deviceauth.RevokeDeviceSecret(di); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
+
+// GetEdgeSecret return the auth type of the edge, the secret isn't shown.
+func GetEdgeSecret(c *gin.Context) {
+	secret, err := deviceauth.GetEdgeSecret(c.Param("id"))
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(secret, c)
+}
+
+/*
+* RotateEdgeSecret
+* generate or rotate the secret of the edge, it's the same as the device.
+ */
+func RotateEdgeSecret(c *gin.Context) {
+	var req v1types.DeviceSecretRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+			return
+		}
+	}
+
+	secret, err := deviceauth.RotateEdgeSecret(c.Param("id"), req.Secret, req.Immediate)
+	if err == deviceauth.ErrSecretTooShort {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, err.Error(), c)
+		return
+	}
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(secret, c)
+}
+
+// RevokeEdgeSecret revoke the secret, the frames of the edge are rejected.
+func RevokeEdgeSecret(c *gin.Context) {
+	if err := deviceauth.RevokeEdgeSecret(c.Param("id")); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
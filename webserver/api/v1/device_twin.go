@@ -0,0 +1,138 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/global"
+	"github.com/edgehook/ithings/common/influxdbm/influx_store"
+	"github.com/edgehook/ithings/common/tsdbm"
+	v1types "github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/core/devicetwin"
+	"github.com/edgehook/ithings/core/twincache"
+	responce "github.com/edgehook/ithings/webserver/types"
+	"github.com/gin-gonic/gin"
+)
+
+func GetDeviceTwins(c *gin.Context) {
+	deviceID := c.Param("id")
+	if _, err := db.GetDeviceInstanceByDeviceId(deviceID); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "device not found", c)
+		return
+	}
+
+	responce.OkWithData(devicetwin.GetDeviceTwin(deviceID), c)
+}
+
+// GetTwinSubscribers return the queued and dropped notifications of the twin subscribers.
+func GetTwinSubscribers(c *gin.Context) {
+	responce.OkWithData(twincache.Stats(), c)
+}
+
+const (
+	defaultSeriesAggregate = influx_store.AggMean
+	defaultSeriesInterval  = "1m"
+	defaultSeriesRange     = 24 * time.Hour
+)
+
+/*
+* GetDeviceTwinSeries
+* aggregate the reported values of the property over the time buckets.
+ */
+func GetDeviceTwinSeries(c *gin.Context) {
+	var query v1types.TwinSeriesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	if query.Aggregate == "" {
+		query.Aggregate = defaultSeriesAggregate
+	}
+	if !influx_store.IsSeriesAggregate(query.Aggregate) {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "invalid agg", c)
+		return
+	}
+	if query.Fill != "" && !influx_store.IsSeriesFill(query.Fill) {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "invalid fill", c)
+		return
+	}
+	if query.Interval == "" {
+		query.Interval = defaultSeriesInterval
+	}
+	interval, err := influx_store.ParseDuration(query.Interval)
+	if err != nil || interval < time.Second {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "invalid interval", c)
+		return
+	}
+
+	end := time.Now().UnixNano() / 1e6
+	if query.End != nil {
+		end = *query.End
+	}
+	start := end - defaultSeriesRange.Milliseconds()
+	if query.Start != nil {
+		start = *query.Start
+	}
+	if start > end || (end-start)/interval.Milliseconds() > influx_store.MaxSeriesBuckets {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "invalid time range", c)
+		return
+	}
+
+	deviceID := c.Param("id")
+	if _, err := db.GetDeviceInstanceByDeviceId(deviceID); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "device not found", c)
+		return
+	}
+
+	series := &v1types.TwinSeries{
+		DeviceId:  deviceID,
+		Service:   c.Param("svc"),
+		Property:  c.Param("prop"),
+		Aggregate: query.Aggregate,
+		Interval:  query.Interval,
+	}
+	series.Points, err = tsdbm.QueryTwinSeries(deviceID, series.Service, series.Property,
+		query.Aggregate, interval, start, end, query.Fill)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(series, c)
+}
+
+func SetDesiredTwin(c *gin.Context) {
+	var msg v1types.DesiredPropertyMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	msg.DeviceId = c.Param("id")
+
+	di, err := db.GetDeviceInstanceByDeviceId(msg.DeviceId)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "device not found", c)
+		return
+	}
+	pm, err := db.GetPropertyModelByDeviceModelIdAndServiceNameAndPropertyName(di.DeviceModelId, msg.ServiceName, msg.PropertyName)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "property not found", c)
+		return
+	}
+	if !pm.WriteAble {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "property is read only", c)
+		return
+	}
+
+	if err := devicetwin.SetDesired(&msg); err != nil {
+		if err == global.ErrInvalidParms {
+			responce.FailWithCodeAndMessage(http.StatusBadRequest, err.Error(), c)
+			return
+		}
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
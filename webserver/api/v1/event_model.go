@@ -0,0 +1,121 @@
+package v1
+
+import (
+	"net/http"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	v1types "github.com/edgehook/ithings/common/types/v1"
+	responce "github.com/edgehook/ithings/webserver/types"
+	"github.com/gin-gonic/gin"
+)
+
+func toEventModel(e *v1types.DeviceEventModel, serviceModelID int64) *db.EventModel {
+	return &db.EventModel{
+		Name:           e.Name,
+		EventType:      e.EventType,
+		MaxValue:       e.MaxValue,
+		MinValue:       e.MinValue,
+		Unit:           e.Unit,
+		DataType:       e.DataType,
+		Description:    e.Description,
+		ServiceModelId: serviceModelID,
+	}
+}
+
+func GetEventModels(c *gin.Context) {
+	sm, ok := getServiceModel(c)
+	if !ok {
+		return
+	}
+
+	ems, err := db.GetEventModelByServiceModelId(sm.ID)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(ems, c)
+}
+
+func AddEventModel(c *gin.Context) {
+	sm, ok := getServiceModel(c)
+	if !ok {
+		return
+	}
+
+	var event v1types.DeviceEventModel
+	if err := c.ShouldBindJSON(&event); err != nil || event.Name == "" {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	if db.IsExistEventModel(sm.ID, event.Name) {
+		responce.FailWithCodeAndMessage(http.StatusConflict, "event model already exists", c)
+		return
+	}
+
+	em := toEventModel(&event, sm.ID)
+	if err := db.AddEventModel(em); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(em, c)
+}
+
+func UpdateEventModel(c *gin.Context) {
+	sm, ok := getServiceModel(c)
+	if !ok {
+		return
+	}
+	eid, ok := getIDParam(c, "eid")
+	if !ok {
+		return
+	}
+
+	var event v1types.DeviceEventModel
+	if err := c.ShouldBindJSON(&event); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+
+	em, err := db.GetEventModelByEventId(eid)
+	if err != nil || em.ServiceModelId != sm.ID {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "event model not found", c)
+		return
+	}
+
+	if err := db.SaveEventModel(eid, toEventModel(&event, sm.ID)); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
+
+func DeleteEventModel(c *gin.Context) {
+	sm, ok := getServiceModel(c)
+	if !ok {
+		return
+	}
+	eid, ok := getIDParam(c, "eid")
+	if !ok {
+		return
+	}
+
+	em, err := db.GetEventModelByEventId(eid)
+	if err != nil || em.ServiceModelId != sm.ID {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "event model not found", c)
+		return
+	}
+	if inUse, _ := db.IsExistEventInstanceByEventModelId(eid); inUse {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "the event is used by devices", c)
+		return
+	}
+
+	if err := db.DeleteEventModel(eid); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
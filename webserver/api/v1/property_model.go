@@ -0,0 +1,136 @@
+package v1
+
+import (
+	"net/http"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	v1types "github.com/edgehook/ithings/common/types/v1"
+	responce "github.com/edgehook/ithings/webserver/types"
+	"github.com/gin-gonic/gin"
+)
+
+func toPropertyModel(p *v1types.DevicePropertyModel, serviceModelID int64) *db.PropertyModel {
+	return &db.PropertyModel{
+		Name:           p.Name,
+		WriteAble:      p.WriteAble,
+		Report:         p.Report,
+		MaxValue:       p.MaxValue,
+		MinValue:       p.MinValue,
+		Unit:           p.Unit,
+		DataType:       p.DataType,
+		Description:    p.Description,
+		ServiceModelId: serviceModelID,
+	}
+}
+
+func GetPropertyModels(c *gin.Context) {
+	sm, ok := getServiceModel(c)
+	if !ok {
+		return
+	}
+
+	var pageInfo responce.PageInfo
+	if err := c.ShouldBindQuery(&pageInfo); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+
+	var (
+		pms []*db.PropertyModel
+		err error
+	)
+	if pageInfo.Page > 0 && pageInfo.Limit > 0 {
+		pms, err = db.GetPropertyModelByPageAndServiceId(sm.ID, pageInfo.Page, pageInfo.Limit)
+	} else {
+		pms, err = db.GetPropertyModelByServiceId(sm.ID)
+	}
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(pms, c)
+}
+
+func AddPropertyModel(c *gin.Context) {
+	sm, ok := getServiceModel(c)
+	if !ok {
+		return
+	}
+
+	var property v1types.DevicePropertyModel
+	if err := c.ShouldBindJSON(&property); err != nil || property.Name == "" {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	if db.IsExistPropertyModel(sm.ID, property.Name) {
+		responce.FailWithCodeAndMessage(http.StatusConflict, "property model already exists", c)
+		return
+	}
+
+	pm := toPropertyModel(&property, sm.ID)
+	if err := db.AddPropertyModel(pm); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(pm, c)
+}
+
+func UpdatePropertyModel(c *gin.Context) {
+	sm, ok := getServiceModel(c)
+	if !ok {
+		return
+	}
+	pid, ok := getIDParam(c, "pid")
+	if !ok {
+		return
+	}
+
+	var property v1types.DevicePropertyModel
+	if err := c.ShouldBindJSON(&property); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+
+	pm, err := db.GetPropertyModelByPropertyId(pid)
+	if err != nil || pm.ServiceModelId != sm.ID {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "property model not found", c)
+		return
+	}
+
+	if err := db.SavePropertyModel(pid, toPropertyModel(&property, sm.ID)); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
+
+func DeletePropertyModel(c *gin.Context) {
+	sm, ok := getServiceModel(c)
+	if !ok {
+		return
+	}
+	pid, ok := getIDParam(c, "pid")
+	if !ok {
+		return
+	}
+
+	pm, err := db.GetPropertyModelByPropertyId(pid)
+	if err != nil || pm.ServiceModelId != sm.ID {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "property model not found", c)
+		return
+	}
+	if db.IsExistPropertyInstanceByPropertyModelId(pid) {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "the property is used by devices", c)
+		return
+	}
+
+	if err := db.DeletePropertyModel(pid); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
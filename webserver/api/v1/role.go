@@ -0,0 +1,150 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	v1types "github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/webserver/auth"
+	responce "github.com/edgehook/ithings/webserver/types"
+	"github.com/gin-gonic/gin"
+)
+
+// toPermissions validate and encode the permissions of the role.
+func toPermissions(permissions []string) (string, error) {
+	if permissions == nil {
+		permissions = []string{}
+	}
+	b, err := json.Marshal(permissions)
+	if err != nil {
+		return "", err
+	}
+	if _, err := auth.ParsePermissions(string(b)); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func GetRoles(c *gin.Context) {
+	roles, err := db.GetRoles()
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(roles, c)
+}
+
+func GetRole(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	role, err := db.GetRoleById(id)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "role not found", c)
+		return
+	}
+
+	responce.OkWithData(role, c)
+}
+
+func AddRole(c *gin.Context) {
+	var req v1types.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == "" {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	permissions, err := toPermissions(req.Permissions)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, err.Error(), c)
+		return
+	}
+	if _, err := db.GetRoleByName(req.Name); err == nil {
+		responce.FailWithCodeAndMessage(http.StatusConflict, "role name already exists", c)
+		return
+	}
+
+	role := &db.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: permissions,
+	}
+	if err := db.AddRole(role); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(role, c)
+}
+
+// UpdateRole update the description and permissions, the name can't be changed.
+func UpdateRole(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req v1types.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	permissions, err := toPermissions(req.Permissions)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, err.Error(), c)
+		return
+	}
+
+	role, err := db.GetRoleById(id)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "role not found", c)
+		return
+	}
+	//the admin must keep all permissions to manage the users.
+	if role.Name == auth.RoleAdmin {
+		responce.FailWithCodeAndMessage(http.StatusForbidden, "the admin role can't be changed", c)
+		return
+	}
+
+	role.Description = req.Description
+	role.Permissions = permissions
+	if err := db.SaveRole(id, role); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+	auth.InvalidateRole(role.Name)
+
+	responce.Ok(c)
+}
+
+func DeleteRole(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	role, err := db.GetRoleById(id)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "role not found", c)
+		return
+	}
+	if role.BuiltIn {
+		responce.FailWithCodeAndMessage(http.StatusForbidden, "the built-in role can't be deleted", c)
+		return
+	}
+	if count, err := db.GetUserCountByRole(role.Name); err != nil || count > 0 {
+		responce.FailWithCodeAndMessage(http.StatusConflict, "role is in use", c)
+		return
+	}
+
+	if err := db.DeleteRole(id); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+	auth.InvalidateRole(role.Name)
+
+	responce.Ok(c)
+}
@@ -0,0 +1,114 @@
+package v1
+
+import (
+	"net/http"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	v1types "github.com/edgehook/ithings/common/types/v1"
+	responce "github.com/edgehook/ithings/webserver/types"
+	"github.com/gin-gonic/gin"
+)
+
+// getServiceModel get the service model and check it belongs to the device model.
+func getServiceModel(c *gin.Context) (*db.ServiceModel, bool) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return nil, false
+	}
+	sid, ok := getIDParam(c, "sid")
+	if !ok {
+		return nil, false
+	}
+
+	sm, err := db.GetServiceModelByServiceModelId(sid)
+	if err != nil || sm.DeviceModelId != id {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "service model not found", c)
+		return nil, false
+	}
+
+	return sm, true
+}
+
+func GetServiceModels(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	sms, err := db.GetServiceModelByDeviceModelId(id)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(sms, c)
+}
+
+func AddServiceModel(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var service v1types.DeviceServiceModel
+	if err := c.ShouldBindJSON(&service); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+
+	if _, err := db.GetDeviceModelById(id); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "device model not found", c)
+		return
+	}
+	if db.IsExistServiceModel(id, service.Name) {
+		responce.FailWithCodeAndMessage(http.StatusConflict, "service model already exists", c)
+		return
+	}
+
+	sm := &db.ServiceModel{
+		Name:          service.Name,
+		Description:   service.Description,
+		DeviceModelId: id,
+	}
+	for _, p := range service.PropertyModels {
+		if p != nil {
+			sm.PropertyModels = append(sm.PropertyModels, toPropertyModel(p, 0))
+		}
+	}
+	for _, e := range service.EventModels {
+		if e != nil {
+			sm.EventModels = append(sm.EventModels, toEventModel(e, 0))
+		}
+	}
+	for _, cmd := range service.CommandModels {
+		if cmd != nil {
+			sm.CommandModels = append(sm.CommandModels, toCommandModel(cmd, 0))
+		}
+	}
+
+	if err := db.AddServiceModel(sm); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(sm, c)
+}
+
+func DeleteServiceModel(c *gin.Context) {
+	sm, ok := getServiceModel(c)
+	if !ok {
+		return
+	}
+
+	if isDeviceModelInUse(sm.DeviceModelId) {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "there are devices using this model", c)
+		return
+	}
+
+	if err := db.DeleteServiceModelAll(sm.ID); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
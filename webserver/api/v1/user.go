@@ -0,0 +1,236 @@
+package v1
+
+import (
+	"net/http"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	v1types "github.com/edgehook/ithings/common/types/v1"
+	"github.com/edgehook/ithings/webserver/auth"
+	responce "github.com/edgehook/ithings/webserver/types"
+	"github.com/gin-gonic/gin"
+)
+
+// checkRole check the role exists, the empty role is viewer.
+func checkRole(role string) (string, bool) {
+	if role == "" {
+		role = auth.RoleViewer
+	}
+	_, err := db.GetRoleByName(role)
+	return role, err == nil
+}
+
+// isLastAdmin check whether the user is the only one with the admin role.
+func isLastAdmin(user *db.User) bool {
+	if user.Role != auth.RoleAdmin {
+		return false
+	}
+	count, err := db.GetUserCountByRole(auth.RoleAdmin)
+	return err == nil && count <= 1
+}
+
+func GetUsers(c *gin.Context) {
+	var pageInfo responce.PageInfo
+	if err := c.ShouldBindQuery(&pageInfo); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	pageInfo.Normalize()
+
+	users, err := db.GetUserByPageAndKeywords(pageInfo.Page, pageInfo.Limit, pageInfo.Keywords)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+	total, err := db.GetUserCountByKeywords(pageInfo.Keywords)
+	if err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(&responce.PageResult{
+		List:  users,
+		Total: total,
+	}, c)
+}
+
+func GetUser(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	user, err := db.GetUserById(id)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "user not found", c)
+		return
+	}
+
+	responce.OkWithData(user, c)
+}
+
+func AddUser(c *gin.Context) {
+	var req v1types.UserRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Username == "" {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	if _, err := db.GetUserByUsername(req.Username); err == nil {
+		responce.FailWithCodeAndMessage(http.StatusConflict, "username already exists", c)
+		return
+	}
+
+	role, ok := checkRole(req.Role)
+	if !ok {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "role not found", c)
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, err.Error(), c)
+		return
+	}
+	user := &db.User{
+		Username:     req.Username,
+		PasswordHash: hash,
+		Description:  req.Description,
+		Role:         role,
+		GroupIDs:     req.GroupIDs,
+		EdgeIDs:      req.EdgeIDs,
+	}
+	if req.Disabled != nil {
+		user.Disabled = *req.Disabled
+	}
+
+	if err := db.AddUser(user); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.OkWithData(user, c)
+}
+
+// UpdateUser update the description, status, role and scope, the username can't be changed.
+func UpdateUser(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req v1types.UserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+
+	user, err := db.GetUserById(id)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "user not found", c)
+		return
+	}
+	role, ok := checkRole(req.Role)
+	if !ok {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "role not found", c)
+		return
+	}
+	disabled := user.Disabled
+	if req.Disabled != nil {
+		disabled = *req.Disabled
+	}
+	//keep at least one admin to manage the users.
+	if (role != user.Role || disabled) && isLastAdmin(user) {
+		responce.FailWithCodeAndMessage(http.StatusConflict, "can't change the last admin", c)
+		return
+	}
+
+	user.Description = req.Description
+	user.Disabled = disabled
+	user.Role = role
+	user.GroupIDs = req.GroupIDs
+	user.EdgeIDs = req.EdgeIDs
+
+	if err := db.SaveUser(id, user); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
+
+func DeleteUser(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if subject := responce.GetTokenSubject(c); subject != nil && subject.UserID == id {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "can't delete the current user", c)
+		return
+	}
+	user, err := db.GetUserById(id)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "user not found", c)
+		return
+	}
+	if isLastAdmin(user) {
+		responce.FailWithCodeAndMessage(http.StatusConflict, "can't delete the last admin", c)
+		return
+	}
+
+	if err := db.DeleteUser(id); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+	db.DeleteAPIKeyByOwnerId(id)
+
+	responce.Ok(c)
+}
+
+// ResetUserPassword set the password of the user without the old one, all its tokens are revoked.
+func ResetUserPassword(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req v1types.PasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, "Parameter error", c)
+		return
+	}
+	if _, err := db.GetUserById(id); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "user not found", c)
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		responce.FailWithCodeAndMessage(http.StatusBadRequest, err.Error(), c)
+		return
+	}
+	if err := db.SaveUserPassword(id, hash); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
+
+// RevokeUserTokens revoke all the tokens issued to the user.
+func RevokeUserTokens(c *gin.Context) {
+	id, ok := getIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if _, err := db.GetUserById(id); err != nil {
+		responce.FailWithCodeAndMessage(http.StatusNotFound, "user not found", c)
+		return
+	}
+	if err := db.RevokeUserTokens(id); err != nil {
+		responce.FailWithMessage(err.Error(), c)
+		return
+	}
+
+	responce.Ok(c)
+}
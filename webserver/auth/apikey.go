@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+)
+
+const (
+	APIKeyPrefix = "itk_"
+
+	apiKeyPrefixLength = 6
+	apiKeySecretLength = 24
+	//the last used time is written into db at most once in this interval.
+	lastUsedInterval = time.Minute
+)
+
+var (
+	ErrAPIKeyExpired    = errors.New("api key expired")
+	ErrAPIKeyDisabled   = errors.New("api key disabled")
+	ErrAPIKeyScopeUsers = errors.New("api key can't manage the users")
+)
+
+// lastUsedRecorder throttle the writes of the last used time of the keys.
+type lastUsedRecorder struct {
+	sync.Mutex
+	written map[int64]time.Time
+}
+
+var defaultLastUsed = &lastUsedRecorder{
+	written: make(map[int64]time.Time),
+}
+
+func (r *lastUsedRecorder) record(id int64, address string, now time.Time) {
+	r.Lock()
+	if now.Sub(r.written[id]) < lastUsedInterval {
+		r.Unlock()
+		return
+	}
+	r.written[id] = now
+	r.Unlock()
+
+	db.SaveAPIKeyLastUsed(id, now.UnixNano()/1e6, address)
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey indicates whether the token is an API key rather than a JWT.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+/*
+* GenerateAPIKey
+* return the key "itk_<prefix>_<secret>", its prefix and sha256 hash,
+* the key is shown only once and never stored.
+ */
+func GenerateAPIKey() (key, prefix, hash string) {
+	prefix = randomHex(apiKeyPrefixLength)
+	key = APIKeyPrefix + prefix + "_" + randomHex(apiKeySecretLength)
+	return key, prefix, hashAPIKey(key)
+}
+
+/*
+* ValidateScopes
+* check and encode the scopes of the API key, the users can't be
+* managed by the key.
+ */
+func ValidateScopes(scopes []string) (string, error) {
+	if scopes == nil {
+		scopes = []string{}
+	}
+	for _, scope := range scopes {
+		if scope == wildcard || strings.HasPrefix(scope, ResourceUsers+":") {
+			return "", ErrAPIKeyScopeUsers
+		}
+	}
+
+	b, err := json.Marshal(scopes)
+	if err != nil {
+		return "", err
+	}
+	if _, err := ParsePermissions(string(b)); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// AuthorizeScopes check whether the scopes of the API key allow the action on the resource.
+func AuthorizeScopes(scopes, resource, action string) bool {
+	if resource == ResourceUsers {
+		return false
+	}
+
+	list, err := ParsePermissions(scopes)
+	if err != nil {
+		return false
+	}
+	for _, p := range list {
+		if p == resource+":"+action || p == resource+":"+wildcard || p == wildcard+":"+action {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+* VerifyAPIKey
+* check the hash, status and expiration of the key, and its owner,
+* the last used time and address are recorded.
+ */
+func (a *Authenticator) VerifyAPIKey(key, address string) (*db.APIKey, *db.User, error) {
+	parts := strings.SplitN(strings.TrimPrefix(key, APIKeyPrefix), "_", 2)
+	if len(parts) != 2 {
+		return nil, nil, ErrInvalidToken
+	}
+
+	apiKey, err := db.GetAPIKeyByPrefix(parts[0])
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(key)), []byte(apiKey.KeyHash)) != 1 {
+		return nil, nil, ErrInvalidToken
+	}
+	if apiKey.Disabled {
+		return nil, nil, ErrAPIKeyDisabled
+	}
+	now := time.Now()
+	if apiKey.ExpireTimeStamp > 0 && apiKey.ExpireTimeStamp <= now.UnixNano()/1e6 {
+		return nil, nil, ErrAPIKeyExpired
+	}
+
+	user, err := db.GetUserById(apiKey.OwnerId)
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+	if user.Disabled {
+		return nil, nil, ErrUserDisabled
+	}
+
+	defaultLastUsed.record(apiKey.ID, address, now)
+	return apiKey, user, nil
+}
+
+// APIKeyLimit return the token bucket of the key, the default is from config.
+func (a *Authenticator) APIKeyLimit(apiKey *db.APIKey) (float64, int) {
+	rate, burst := apiKey.RateLimit, apiKey.Burst
+	if rate <= 0 {
+		rate = a.cfg.APIKeyRateLimit
+	}
+	if burst <= 0 {
+		burst = a.cfg.APIKeyBurst
+	}
+	return rate, burst
+}
+
+// VerifyAPIKey verify the API key by the default authenticator.
+func VerifyAPIKey(key, address string) (*db.APIKey, *db.User, error) {
+	return getAuthenticator().VerifyAPIKey(key, address)
+}
+
+// APIKeyLimit return the token bucket of the key by the default authenticator.
+func APIKeyLimit(apiKey *db.APIKey) (float64, int) {
+	return getAuthenticator().APIKeyLimit(apiKey)
+}
@@ -0,0 +1,400 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgehook/ithings/common/config"
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/common/types/v1"
+	"golang.org/x/crypto/bcrypt"
+	"k8s.io/klog/v2"
+)
+
+const (
+	minPasswordLength = 8
+	secretLength      = 32
+	//prune the expired revoked tokens periodically.
+	defaultPruneInterval = time.Hour
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrTokenExpired       = errors.New("token expired")
+	ErrTokenRevoked       = errors.New("token revoked")
+	ErrUserDisabled       = errors.New("user disabled")
+	ErrPasswordTooShort   = errors.New("password must have at least 8 characters")
+	ErrTooManyAttempts    = errors.New("too many failed logins, try again later")
+)
+
+/*
+* Authenticator
+* issue and verify the HS256 signed tokens with the key from config,
+* the revoked tokens are kept in db until they expire.
+ */
+type Authenticator struct {
+	cfg      *config.AuthConfig
+	key      []byte
+	throttle *loginThrottle
+	once     sync.Once
+}
+
+var (
+	defaultAuthenticator *Authenticator
+	defaultOnce          sync.Once
+)
+
+func NewAuthenticator(cfg *config.AuthConfig) *Authenticator {
+	return &Authenticator{
+		cfg:      cfg,
+		key:      loadSecret(cfg),
+		throttle: newLoginThrottle(cfg.LoginMaxFailures, cfg.LoginLockout),
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		klog.Errorf("err: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+/*
+* loadSecret
+* the key is from config or env, otherwise from the secret file,
+* a random key is generated into the file if it doesn't exist.
+ */
+func loadSecret(cfg *config.AuthConfig) []byte {
+	if cfg.JwtSecret != "" {
+		return []byte(cfg.JwtSecret)
+	}
+
+	if data, err := os.ReadFile(cfg.SecretFile); err == nil {
+		if secret := strings.TrimSpace(string(data)); secret != "" {
+			return []byte(secret)
+		}
+	}
+
+	secret := randomHex(secretLength)
+	if err := os.WriteFile(cfg.SecretFile, []byte(secret), 0600); err != nil {
+		klog.Warningf("save the jwt secret into %s with err: %v, the tokens are invalid after restart",
+			cfg.SecretFile, err)
+	} else {
+		klog.Infof("generate the jwt secret into %s", cfg.SecretFile)
+	}
+
+	return []byte(secret)
+}
+
+// HashPassword hash the password by bcrypt.
+func HashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", ErrPasswordTooShort
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword compare the password with the hash of the user.
+func CheckPassword(user *db.User, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil
+}
+
+// Enabled indicates whether the APIs require the token.
+func (a *Authenticator) Enabled() bool {
+	return a.cfg.Enable
+}
+
+/*
+* Start
+* create the built-in roles and the admin user if there is no user,
+* and prune the expired revoked tokens periodically.
+ */
+func (a *Authenticator) Start() {
+	a.once.Do(func() {
+		if !a.cfg.Enable {
+			klog.Warningf("the authentication is disabled, all the APIs are open")
+			return
+		}
+
+		ensureRoles()
+		a.ensureAdmin()
+		go func() {
+			ticker := time.NewTicker(defaultPruneInterval)
+			defer ticker.Stop()
+			throttleTicker := time.NewTicker(a.cfg.LoginLockout)
+			defer throttleTicker.Stop()
+
+			for {
+				select {
+				case now := <-ticker.C:
+					if n, err := db.PruneRevokedToken(now.UnixNano() / 1e6); err == nil && n > 0 {
+						klog.V(4).Infof("prune %d expired revoked tokens", n)
+					}
+				case now := <-throttleTicker.C:
+					a.throttle.prune(now)
+				}
+			}
+		}()
+	})
+}
+
+func (a *Authenticator) ensureAdmin() {
+	count, err := db.GetUserCount()
+	if err != nil {
+		return
+	}
+	if count > 0 {
+		//the admin user created before the roles.
+		db.SaveUserRoleIfEmpty(a.cfg.AdminUsername, RoleAdmin)
+		return
+	}
+
+	password := a.cfg.AdminPassword
+	generated := password == ""
+	if generated {
+		//never print the password, it's only readable by the owner.
+		password = randomHex(8)
+		if err := os.WriteFile(a.cfg.AdminPasswordFile, []byte(password+"\n"), 0600); err != nil {
+			klog.Errorf("save the admin password into %s with err: %v, please set %s",
+				a.cfg.AdminPasswordFile, err, config.EnvAdminPassword)
+			return
+		}
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		klog.Errorf("create the admin user with err: %v", err)
+		return
+	}
+
+	if err := db.AddUser(&db.User{
+		Username:     a.cfg.AdminUsername,
+		PasswordHash: hash,
+		Description:  "initial administrator",
+		Role:         RoleAdmin,
+	}); err != nil {
+		return
+	}
+	if generated {
+		klog.Warningf("create the admin user %s with the password in %s, please change it and remove the file",
+			a.cfg.AdminUsername, a.cfg.AdminPasswordFile)
+	} else {
+		klog.Infof("create the admin user %s", a.cfg.AdminUsername)
+	}
+}
+
+func (a *Authenticator) newClaims(user *db.User, typ string, now time.Time, ttl time.Duration) *Claims {
+	return &Claims{
+		Issuer:     a.cfg.Issuer,
+		Subject:    user.Username,
+		ID:         randomHex(16),
+		IssuedAt:   now.Unix(),
+		ExpiresAt:  now.Add(ttl).Unix(),
+		UserID:     user.ID,
+		Type:       typ,
+		IssuedAtMs: now.UnixNano() / 1e6,
+	}
+}
+
+// issue a new access and refresh token pair for this user.
+func (a *Authenticator) issue(user *db.User) (*v1.TokenPair, error) {
+	now := time.Now()
+
+	access, err := signToken(a.newClaims(user, TokenAccess, now, a.cfg.AccessTokenTTL), a.key)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := signToken(a.newClaims(user, TokenRefresh, now, a.cfg.RefreshTokenTTL), a.key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    v1.TokenTypeBearer,
+		ExpiresIn:    int64(a.cfg.AccessTokenTTL / time.Second),
+	}, nil
+}
+
+/*
+* Login
+* check the username and password, and issue a token pair.
+* the username and the client ip are locked after too many failures.
+ */
+func (a *Authenticator) Login(username, password, clientIP string) (*v1.TokenPair, error) {
+	now := time.Now()
+	keys := []string{"user:" + username, "ip:" + clientIP}
+	if a.throttle.locked(now, keys...) {
+		return nil, ErrTooManyAttempts
+	}
+
+	user, err := db.GetUserByUsername(username)
+	if err != nil || !CheckPassword(user, password) {
+		a.throttle.fail(now, keys...)
+		if a.throttle.locked(now, keys...) {
+			klog.Warningf("lock the login of %s from %s for %v", username, clientIP, a.cfg.LoginLockout)
+		}
+		return nil, ErrInvalidCredentials
+	}
+	a.throttle.reset(keys[0])
+	if user.Disabled {
+		return nil, ErrUserDisabled
+	}
+
+	pair, err := a.issue(user)
+	if err != nil {
+		return nil, err
+	}
+	db.SaveUserLastLogin(user.ID, time.Now().UnixNano()/1e6)
+
+	return pair, nil
+}
+
+/*
+* verify
+* check the signature, type, revocation and the user of the token.
+ */
+func (a *Authenticator) verify(token, typ string) (*Claims, *db.User, error) {
+	claims, err := parseToken(token, a.key, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	if claims.Type != typ || claims.Issuer != a.cfg.Issuer {
+		return nil, nil, ErrInvalidToken
+	}
+
+	revoked, err := db.IsTokenRevoked(claims.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if revoked {
+		return nil, nil, ErrTokenRevoked
+	}
+
+	user, err := db.GetUserById(claims.UserID)
+	if err != nil || user.Username != claims.Subject {
+		return nil, nil, ErrInvalidToken
+	}
+	if user.Disabled {
+		return nil, nil, ErrUserDisabled
+	}
+	if claims.IssuedAtMs < user.TokensValidAfter {
+		return nil, nil, ErrTokenRevoked
+	}
+
+	return claims, user, nil
+}
+
+// Verify verify the access token, and return the claims and the user of it.
+func (a *Authenticator) Verify(token string) (*Claims, *db.User, error) {
+	return a.verify(token, TokenAccess)
+}
+
+/*
+* Refresh
+* issue a new token pair by the refresh token, the old refresh token
+* is revoked so that it can be used only once.
+ */
+func (a *Authenticator) Refresh(refreshToken string) (*v1.TokenPair, error) {
+	claims, user, err := a.verify(refreshToken, TokenRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	//the concurrent refreshes by the same token, only the first one wins.
+	revoked, err := a.revoke(claims)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked {
+		return nil, ErrTokenRevoked
+	}
+	return a.issue(user)
+}
+
+// Revoke revoke the token until it expires.
+func (a *Authenticator) Revoke(claims *Claims) error {
+	_, err := a.revoke(claims)
+	return err
+}
+
+// revoke the token, return false if it's revoked before.
+func (a *Authenticator) revoke(claims *Claims) (bool, error) {
+	return db.AddRevokedToken(&db.RevokedToken{
+		TokenId:         claims.ID,
+		UserId:          claims.UserID,
+		ExpireTimeStamp: claims.ExpiresAt * 1000,
+		CreateTimeStamp: time.Now().UnixNano() / 1e6,
+	})
+}
+
+/*
+* Logout
+* revoke the access token and the refresh token of the same user.
+ */
+func (a *Authenticator) Logout(access *Claims, refreshToken string) error {
+	if err := a.Revoke(access); err != nil {
+		return err
+	}
+	if refreshToken == "" {
+		return nil
+	}
+
+	refresh, err := parseToken(refreshToken, a.key, time.Now())
+	if err == ErrTokenExpired {
+		return nil
+	}
+	if err != nil || refresh.Type != TokenRefresh || refresh.UserID != access.UserID {
+		return ErrInvalidToken
+	}
+	return a.Revoke(refresh)
+}
+
+func getAuthenticator() *Authenticator {
+	defaultOnce.Do(func() {
+		defaultAuthenticator = NewAuthenticator(config.GetAuthConfig())
+	})
+	return defaultAuthenticator
+}
+
+// Start the default authenticator.
+func Start() {
+	getAuthenticator().Start()
+}
+
+// Enabled indicates whether the default authenticator is enabled.
+func Enabled() bool {
+	return getAuthenticator().Enabled()
+}
+
+// Login by the default authenticator.
+func Login(username, password, clientIP string) (*v1.TokenPair, error) {
+	return getAuthenticator().Login(username, password, clientIP)
+}
+
+// Verify the access token by the default authenticator.
+func Verify(token string) (*Claims, *db.User, error) {
+	return getAuthenticator().Verify(token)
+}
+
+// Refresh the token pair by the default authenticator.
+func Refresh(refreshToken string) (*v1.TokenPair, error) {
+	return getAuthenticator().Refresh(refreshToken)
+}
+
+// Logout by the default authenticator.
+func Logout(access *Claims, refreshToken string) error {
+	return getAuthenticator().Logout(access, refreshToken)
+}
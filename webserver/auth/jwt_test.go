@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edgehook/ithings/common/config"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func newTestClaims(typ string, now time.Time, ttl time.Duration) *Claims {
+	return &Claims{
+		Issuer:     "ithings",
+		Subject:    "admin",
+		ID:         "jti-1",
+		IssuedAt:   now.Unix(),
+		ExpiresAt:  now.Add(ttl).Unix(),
+		UserID:     1,
+		Type:       typ,
+		IssuedAtMs: now.UnixNano() / 1e6,
+	}
+}
+
+func mustSignToken(t *testing.T, claims *Claims, key []byte) string {
+	token, err := signToken(claims, key)
+	if err != nil {
+		t.Fatalf("signToken() = %v", err)
+	}
+	return token
+}
+
+// withHeader replace the header of the token and sign it again by the key.
+func withHeader(token, header string, key []byte) string {
+	parts := strings.Split(token, ".")
+	signingInput := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + parts[1]
+	return signingInput + "." + sign(signingInput, key)
+}
+
+func TestParseToken(t *testing.T) {
+	now := time.Now()
+	valid := mustSignToken(t, newTestClaims(TokenAccess, now, time.Minute), testKey)
+	parts := strings.Split(valid, ".")
+	tampered := newTestClaims(TokenAccess, now, time.Minute)
+	tampered.UserID = 2
+	tamperedParts := strings.Split(mustSignToken(t, tampered, testKey), ".")
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"valid", valid, nil},
+		{"alg none", withHeader(valid, `{"alg":"none","typ":"JWT"}`, testKey), ErrInvalidToken},
+		{"alg none unsigned", base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + ".", ErrInvalidToken},
+		{"alg HS512", withHeader(valid, `{"alg":"HS512","typ":"JWT"}`, testKey), ErrInvalidToken},
+		{"alg lower case", withHeader(valid, `{"alg":"hs256","typ":"JWT"}`, testKey), ErrInvalidToken},
+		{"bad signature", mustSignToken(t, newTestClaims(TokenAccess, now, time.Minute), []byte("another key")), ErrInvalidToken},
+		{"tampered payload", parts[0] + "." + tamperedParts[1] + "." + parts[2], ErrInvalidToken},
+		{"truncated signature", valid[:len(valid)-2], ErrInvalidToken},
+		{"expired", mustSignToken(t, newTestClaims(TokenAccess, now, -time.Second), testKey), ErrTokenExpired},
+		{"expires now", mustSignToken(t, newTestClaims(TokenAccess, now, 0), testKey), ErrTokenExpired},
+		{"two parts", parts[0] + "." + parts[1], ErrInvalidToken},
+		{"bad header encoding", "!!." + parts[1] + "." + parts[2], ErrInvalidToken},
+		{"empty", "", ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := parseToken(tt.token, testKey, now)
+			if err != tt.want {
+				t.Fatalf("parseToken() = %v, want %v", err, tt.want)
+			}
+			if err == nil && (claims.UserID != 1 || claims.Type != TokenAccess) {
+				t.Errorf("parseToken() claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestVerifyTokenType(t *testing.T) {
+	now := time.Now()
+	a := &Authenticator{
+		cfg: &config.AuthConfig{Issuer: "ithings"},
+		key: testKey,
+	}
+
+	wrongIssuer := newTestClaims(TokenAccess, now, time.Minute)
+	wrongIssuer.Issuer = "other"
+
+	tests := []struct {
+		name  string
+		token string
+		typ   string
+	}{
+		{"refresh as access", mustSignToken(t, newTestClaims(TokenRefresh, now, time.Minute), testKey), TokenAccess},
+		{"access as refresh", mustSignToken(t, newTestClaims(TokenAccess, now, time.Minute), testKey), TokenRefresh},
+		{"unknown type", mustSignToken(t, newTestClaims("id", now, time.Minute), testKey), TokenAccess},
+		{"wrong issuer", mustSignToken(t, wrongIssuer, testKey), TokenAccess},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := a.verify(tt.token, tt.typ); err != ErrInvalidToken {
+				t.Errorf("verify() = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
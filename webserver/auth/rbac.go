@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"k8s.io/klog/v2"
+)
+
+const (
+	//the resources of the route groups.
+	ResourceModels      = "models"
+	ResourceDevices     = "devices"
+	ResourceTwins       = "twins"
+	ResourceAlerts      = "alerts"
+	ResourceDataForward = "dataforward"
+	ResourceUsers       = "users"
+
+	ActionRead   = "read"
+	ActionWrite  = "write"
+	ActionDelete = "delete"
+
+	wildcard = "*"
+
+	//the built-in roles
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleViewer   = "viewer"
+)
+
+var (
+	resources = []string{ResourceModels, ResourceDevices, ResourceTwins,
+		ResourceAlerts, ResourceDataForward, ResourceUsers}
+	actions = []string{ActionRead, ActionWrite, ActionDelete}
+
+	builtInRoles = []*db.Role{
+		{
+			Name:        RoleAdmin,
+			Description: "full access",
+			Permissions: `["*"]`,
+		},
+		{
+			Name:        RoleOperator,
+			Description: "operate the devices and alerts, can't delete the models or change the data forwarding",
+			Permissions: `["models:read","models:write","devices:*","twins:*","alerts:*","dataforward:read"]`,
+		},
+		{
+			Name:        RoleViewer,
+			Description: "read only",
+			Permissions: `["models:read","devices:read","twins:read","alerts:read","dataforward:read"]`,
+		},
+	}
+)
+
+/*
+* roleCache
+* keep the permissions of the roles, it's invalidated when the role is changed.
+ */
+type roleCache struct {
+	sync.RWMutex
+	roles map[string]map[string]bool
+}
+
+var defaultRoleCache = &roleCache{
+	roles: make(map[string]map[string]bool),
+}
+
+// ActionOf map the http method to the action.
+func ActionOf(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ActionRead
+	case http.MethodDelete:
+		return ActionDelete
+	default:
+		return ActionWrite
+	}
+}
+
+func isValidName(list []string, name string) bool {
+	if name == wildcard {
+		return true
+	}
+	for _, v := range list {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+* ParsePermissions
+* parse the json list of the permissions, every permission is "*" or
+* "resource:action", and the resource or action can be "*".
+ */
+func ParsePermissions(permissions string) ([]string, error) {
+	var list []string
+	if permissions == "" {
+		return list, nil
+	}
+	if err := json.Unmarshal([]byte(permissions), &list); err != nil {
+		return nil, err
+	}
+
+	for _, p := range list {
+		if p == wildcard {
+			continue
+		}
+		parts := strings.Split(p, ":")
+		if len(parts) != 2 || !isValidName(resources, parts[0]) || !isValidName(actions, parts[1]) {
+			return nil, fmt.Errorf("invalid permission %s", p)
+		}
+	}
+
+	return list, nil
+}
+
+func (rc *roleCache) get(name string) (map[string]bool, error) {
+	rc.RLock()
+	perms, exist := rc.roles[name]
+	rc.RUnlock()
+	if exist {
+		return perms, nil
+	}
+
+	role, err := db.GetRoleByName(name)
+	if err != nil {
+		return nil, err
+	}
+	list, err := ParsePermissions(role.Permissions)
+	if err != nil {
+		klog.Warningf("role %s has invalid permissions: %v", name, err)
+		return nil, err
+	}
+
+	perms = make(map[string]bool)
+	for _, p := range list {
+		perms[p] = true
+	}
+	rc.Lock()
+	rc.roles[name] = perms
+	rc.Unlock()
+
+	return perms, nil
+}
+
+func (rc *roleCache) invalidate(name string) {
+	rc.Lock()
+	delete(rc.roles, name)
+	rc.Unlock()
+}
+
+// InvalidateRole drop the cached permissions after the role is changed.
+func InvalidateRole(name string) {
+	defaultRoleCache.invalidate(name)
+}
+
+/*
+* Authorize
+* check whether the role has the permission of the action on the resource.
+ */
+func Authorize(role, resource, action string) bool {
+	if role == "" {
+		return false
+	}
+
+	perms, err := defaultRoleCache.get(role)
+	if err != nil {
+		return false
+	}
+
+	return perms[wildcard] || perms[resource+":"+wildcard] ||
+		perms[wildcard+":"+action] || perms[resource+":"+action]
+}
+
+// IsAdmin indicates whether the role has all permissions, it's not restricted by the scope.
+func IsAdmin(role string) bool {
+	perms, err := defaultRoleCache.get(role)
+	return err == nil && perms[wildcard]
+}
+
+/*
+* ensureRoles
+* create the built-in roles if they don't exist.
+ */
+func ensureRoles() {
+	for _, role := range builtInRoles {
+		if _, err := db.GetRoleByName(role.Name); err == nil {
+			continue
+		}
+
+		r := *role
+		r.BuiltIn = true
+		if err := db.AddRole(&r); err != nil {
+			klog.Errorf("create the role %s with err: %v", role.Name, err)
+		}
+	}
+}
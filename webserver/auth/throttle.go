@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+* loginThrottle
+* count the failed logins of each username and client ip, the key is
+* locked for a while once the failures reach the limit.
+ */
+type loginThrottle struct {
+	sync.Mutex
+	maxFailures int
+	lockout     time.Duration
+	attempts    map[string]*loginAttempt
+}
+
+type loginAttempt struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+func newLoginThrottle(maxFailures int, lockout time.Duration) *loginThrottle {
+	return &loginThrottle{
+		maxFailures: maxFailures,
+		lockout:     lockout,
+		attempts:    make(map[string]*loginAttempt),
+	}
+}
+
+// locked indicates whether any of the keys is locked now.
+func (t *loginThrottle) locked(now time.Time, keys ...string) bool {
+	t.Lock()
+	defer t.Unlock()
+
+	for _, key := range keys {
+		if at, exist := t.attempts[key]; exist && now.Before(at.lockedUntil) {
+			return true
+		}
+	}
+	return false
+}
+
+// fail record a failed login for the keys.
+func (t *loginThrottle) fail(now time.Time, keys ...string) {
+	t.Lock()
+	defer t.Unlock()
+
+	for _, key := range keys {
+		at, exist := t.attempts[key]
+		//the failures are forgotten after the lockout.
+		if !exist || now.Sub(at.lastFailure) > t.lockout {
+			at = &loginAttempt{}
+			t.attempts[key] = at
+		}
+
+		at.failures++
+		at.lastFailure = now
+		if at.failures >= t.maxFailures {
+			at.failures = 0
+			at.lockedUntil = now.Add(t.lockout)
+		}
+	}
+}
+
+// reset forget the failures of the keys after a successful login.
+func (t *loginThrottle) reset(keys ...string) {
+	t.Lock()
+	defer t.Unlock()
+
+	for _, key := range keys {
+		delete(t.attempts, key)
+	}
+}
+
+// prune drop the keys which are neither locked nor failed recently.
+func (t *loginThrottle) prune(now time.Time) {
+	t.Lock()
+	defer t.Unlock()
+
+	for key, at := range t.attempts {
+		if now.After(at.lockedUntil) && now.Sub(at.lastFailure) > t.lockout {
+			delete(t.attempts, key)
+		}
+	}
+}
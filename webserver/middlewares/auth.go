@@ -0,0 +1,108 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/webserver/auth"
+	responce "github.com/edgehook/ithings/webserver/types"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	//the key of the token claims in the gin context.
+	ClaimsKey = "tokenClaims"
+	//the key of the API key in the gin context.
+	APIKeyKey = "apiKey"
+)
+
+// getToken get the token from the X-API-Key, Authorization or accesstoken header.
+func getToken(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	if authorization := c.GetHeader("Authorization"); authorization != "" {
+		if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+			return strings.TrimSpace(authorization[7:])
+		}
+		return ""
+	}
+
+	return c.GetHeader("accesstoken")
+}
+
+/*
+* Auth
+* verify the access token or the API key, and keep the claims or the key
+* and the subject in the context. It passes all requests if the
+* authentication is disabled.
+ */
+func Auth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.Enabled() {
+			c.Next()
+			return
+		}
+
+		token := getToken(c)
+		if token == "" {
+			responce.FailWithCodeAndMessage(http.StatusUnauthorized, "missing access token", c)
+			c.Abort()
+			return
+		}
+
+		var user *db.User
+		var err error
+		subject := &responce.TokenSubject{}
+		if auth.IsAPIKey(token) {
+			var apiKey *db.APIKey
+			apiKey, user, err = auth.VerifyAPIKey(token, c.ClientIP())
+			if err == nil {
+				subject.APIKeyID = apiKey.ID
+				c.Set(APIKeyKey, apiKey)
+			}
+		} else {
+			var claims *auth.Claims
+			claims, user, err = auth.Verify(token)
+			if err == nil {
+				c.Set(ClaimsKey, claims)
+			}
+		}
+		if err != nil {
+			responce.FailWithCodeAndMessage(http.StatusUnauthorized, err.Error(), c)
+			c.Abort()
+			return
+		}
+
+		subject.UserID = user.ID
+		subject.Username = user.Username
+		subject.Role = user.Role
+		//the admin is never restricted by the scope.
+		if !auth.IsAdmin(user.Role) {
+			subject.Scope = db.NewDeviceScope(user.GroupIDs, user.EdgeIDs)
+		}
+		c.Set(responce.TokenSubjectKey, subject)
+		c.Next()
+	}
+}
+
+// GetClaims return the token claims set by Auth, nil if it's not authenticated by the token.
+func GetClaims(c *gin.Context) *auth.Claims {
+	if v, exist := c.Get(ClaimsKey); exist {
+		if claims, ok := v.(*auth.Claims); ok {
+			return claims
+		}
+	}
+	return nil
+}
+
+// GetAPIKey return the API key set by Auth, nil if it's not authenticated by the key.
+func GetAPIKey(c *gin.Context) *db.APIKey {
+	if v, exist := c.Get(APIKeyKey); exist {
+		if apiKey, ok := v.(*db.APIKey); ok {
+			return apiKey
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/edgehook/ithings/webserver/auth"
+	responce "github.com/edgehook/ithings/webserver/types"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+* Permit
+* check the permission of the route group, the action is mapped from
+* the http method. The API key needs both the role of its owner and
+* its own scopes. It must be used after Auth.
+ */
+func Permit(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.Enabled() {
+			c.Next()
+			return
+		}
+
+		subject := responce.GetTokenSubject(c)
+		if subject == nil {
+			responce.FailWithCodeAndMessage(http.StatusUnauthorized, "not authenticated", c)
+			c.Abort()
+			return
+		}
+
+		action := auth.ActionOf(c.Request.Method)
+		apiKey := GetAPIKey(c)
+		if !auth.Authorize(subject.Role, resource, action) ||
+			(apiKey != nil && !auth.AuthorizeScopes(apiKey.Scopes, resource, action)) {
+			responce.FailWithCodeAndMessage(http.StatusForbidden,
+				"permission denied: "+resource+":"+action, c)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func failOutOfScope(c *gin.Context) {
+	responce.FailWithCodeAndMessage(http.StatusForbidden, "device is out of your scope", c)
+	c.Abort()
+}
+
+/*
+* DeviceScope
+* restrict the request on the device of the path param to the scope
+* of the user, the unknown device is left to the handler.
+ */
+func DeviceScope(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope := responce.GetDeviceScope(c)
+		if scope == nil || c.Param(param) == "" {
+			c.Next()
+			return
+		}
+
+		di, err := db.GetDeviceInstanceByDeviceId(c.Param(param))
+		if err == nil && !scope.Contains(&di) {
+			failOutOfScope(c)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// EdgeScope restrict the request on the edge of the path param to the edges of the user.
+func EdgeScope(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope := responce.GetDeviceScope(c)
+		if scope != nil && !scope.Contains(&db.DeviceInstance{EdgeID: c.Param(param)}) {
+			failOutOfScope(c)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+/*
+* AlertLogScope
+* restrict the request on the alert log of the path param to the scope
+* of the user by the device or edge of the alert log.
+ */
+func AlertLogScope(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope := responce.GetDeviceScope(c)
+		if scope == nil || c.Param(param) == "" {
+			c.Next()
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param(param), 10, 64)
+		if err != nil {
+			c.Next()
+			return
+		}
+		alertLog, err := db.GetAlertLogById(id)
+		if err != nil || alertLog == nil || alertLog.ID == 0 {
+			c.Next()
+			return
+		}
+
+		di := &db.DeviceInstance{EdgeID: alertLog.EdgeId}
+		if alertLog.DeviceId != "" {
+			if device, err := db.GetDeviceInstanceByDeviceId(alertLog.DeviceId); err == nil {
+				di = &device
+			}
+		}
+		if !scope.Contains(di) {
+			failOutOfScope(c)
+			return
+		}
+
+		c.Next()
+	}
+}
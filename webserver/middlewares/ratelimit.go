@@ -0,0 +1,79 @@
+package middlewares
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/edgehook/ithings/webserver/auth"
+	responce "github.com/edgehook/ithings/webserver/types"
+	"github.com/gin-gonic/gin"
+)
+
+// tokenBucket refill rate tokens per second up to burst, every request takes one.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+/*
+* rateLimiter
+* keep the token buckets of the API keys in memory.
+ */
+type rateLimiter struct {
+	sync.Mutex
+	buckets map[int64]*tokenBucket
+}
+
+var defaultRateLimiter = &rateLimiter{
+	buckets: make(map[int64]*tokenBucket),
+}
+
+// allow take a token of the key, or return the time to wait for the next token.
+func (rl *rateLimiter) allow(id int64, rate float64, burst int, now time.Time) (bool, time.Duration) {
+	rl.Lock()
+	defer rl.Unlock()
+
+	b, exist := rl.buckets[id]
+	if !exist {
+		b = &tokenBucket{tokens: float64(burst), last: now}
+		rl.buckets[id] = b
+	}
+
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+/*
+* RateLimit
+* limit the requests of every API key by its token bucket, the exceeded
+* request gets 429. The requests with the user token are not limited.
+ */
+func RateLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := GetAPIKey(c)
+		if apiKey == nil {
+			c.Next()
+			return
+		}
+
+		rate, burst := auth.APIKeyLimit(apiKey)
+		ok, wait := defaultRateLimiter.allow(apiKey.ID, rate, burst, time.Now())
+		if !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			responce.FailWithCodeAndMessage(http.StatusTooManyRequests, "rate limit exceeded", c)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
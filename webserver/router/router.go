@@ -1,15 +1,146 @@
 package router
 
 import (
+	"github.com/edgehook/ithings/webserver/api"
 	v1 "github.com/edgehook/ithings/webserver/api/v1"
+	"github.com/edgehook/ithings/webserver/auth"
+	"github.com/edgehook/ithings/webserver/middlewares"
 	"github.com/gin-gonic/gin"
 )
 
 func InitRouter() *gin.Engine {
 	r := gin.Default()
+	r.Use(middlewares.Cors())
+
+	//the public APIs to get the tokens.
+	public := r.Group("/v1/auth")
+	{
+		public.POST("/login", api.Login)
+		public.POST("/refresh", api.RefreshToken)
+	}
+
 	apiv1 := r.Group("/v1")
+	apiv1.Use(middlewares.Auth(), middlewares.RateLimit())
+	{
+		apiv1.POST("/auth/logout", api.Logout)
+		apiv1.GET("/auth/user", api.GetCurrentUser)
+		apiv1.PUT("/auth/password", api.ChangePassword)
+	}
+
+	//users, roles and API keys
+	users := apiv1.Group("", middlewares.Permit(auth.ResourceUsers))
+	{
+		users.GET("/users", v1.GetUsers)
+		users.POST("/users", v1.AddUser)
+		users.GET("/users/:id", v1.GetUser)
+		users.PUT("/users/:id", v1.UpdateUser)
+		users.DELETE("/users/:id", v1.DeleteUser)
+		users.PUT("/users/:id/password", v1.ResetUserPassword)
+		users.POST("/users/:id/revoke", v1.RevokeUserTokens)
+		users.GET("/roles", v1.GetRoles)
+		users.POST("/roles", v1.AddRole)
+		users.GET("/roles/:id", v1.GetRole)
+		users.PUT("/roles/:id", v1.UpdateRole)
+		users.DELETE("/roles/:id", v1.DeleteRole)
+		users.GET("/apikeys", v1.GetAPIKeys)
+		users.POST("/apikeys", v1.AddAPIKey)
+		users.GET("/apikeys/:id", v1.GetAPIKey)
+		users.PUT("/apikeys/:id", v1.UpdateAPIKey)
+		users.DELETE("/apikeys/:id", v1.DeleteAPIKey)
+	}
+
+	//device models
+	models := apiv1.Group("/models", middlewares.Permit(auth.ResourceModels))
+	{
+		models.GET("", v1.GetDeviceModels)
+		models.POST("", v1.AddDeviceModel)
+		models.GET("/:id", v1.GetDeviceModel)
+		models.PUT("/:id", v1.UpdateDeviceModel)
+		models.DELETE("/:id", v1.DeleteDeviceModel)
+		models.GET("/:id/services", v1.GetServiceModels)
+		models.POST("/:id/services", v1.AddServiceModel)
+		models.DELETE("/:id/services/:sid", v1.DeleteServiceModel)
+		models.GET("/:id/services/:sid/properties", v1.GetPropertyModels)
+		models.POST("/:id/services/:sid/properties", v1.AddPropertyModel)
+		models.PUT("/:id/services/:sid/properties/:pid", v1.UpdatePropertyModel)
+		models.DELETE("/:id/services/:sid/properties/:pid", v1.DeletePropertyModel)
+		models.GET("/:id/services/:sid/events", v1.GetEventModels)
+		models.POST("/:id/services/:sid/events", v1.AddEventModel)
+		models.PUT("/:id/services/:sid/events/:eid", v1.UpdateEventModel)
+		models.DELETE("/:id/services/:sid/events/:eid", v1.DeleteEventModel)
+		models.GET("/:id/services/:sid/commands", v1.GetCommandModels)
+		models.POST("/:id/services/:sid/commands", v1.AddCommandModel)
+		models.PUT("/:id/services/:sid/commands/:cid", v1.UpdateCommandModel)
+		models.DELETE("/:id/services/:sid/commands/:cid", v1.DeleteCommandModel)
+	}
+
+	//device instances, restricted to the groups and edges of the user.
+	devices := apiv1.Group("", middlewares.Permit(auth.ResourceDevices), middlewares.DeviceScope("id"))
+	{
+		devices.POST("/awake/:mac", v1.AwakeDevice)
+		devices.GET("/devices", v1.GetDeviceInstances)
+		devices.POST("/devices", v1.AddDeviceInstance)
+		devices.GET("/devices/:id", v1.GetDeviceInstance)
+		devices.DELETE("/devices/:id", v1.DeleteDeviceInstance)
+		devices.PUT("/devices/:id/config", v1.UpdateDeviceInstanceConfig)
+		devices.POST("/devices/:id/start", v1.StartDeviceInstance)
+		devices.POST("/devices/:id/stop", v1.StopDeviceInstance)
+		devices.POST("/devices/:id/copy", v1.CopyDeviceInstance)
+		devices.GET("/devices/:id/secret", v1.GetDeviceSecret)
+		devices.POST("/devices/:id/secret", v1.RotateDeviceSecret)
+		devices.DELETE("/devices/:id/secret", v1.RevokeDeviceSecret)
+	}
+
+	//the secrets of the edges to sign the frames.
+	edges := apiv1.Group("/edges/:id", middlewares.Permit(auth.ResourceDevices), middlewares.EdgeScope("id"))
+	{
+		edges.GET("/secret", v1.GetEdgeSecret)
+		edges.POST("/secret", v1.RotateEdgeSecret)
+		edges.DELETE("/secret", v1.RevokeEdgeSecret)
+	}
+
+	//device twins
+	twins := apiv1.Group("/devices/:id/twins", middlewares.Permit(auth.ResourceTwins), middlewares.DeviceScope("id"))
+	{
+		twins.GET("", v1.GetDeviceTwins)
+		twins.GET("/:svc/:prop/series", v1.GetDeviceTwinSeries)
+		twins.POST("/desired", v1.SetDesiredTwin)
+	}
+	apiv1.GET("/twins/subscribers", middlewares.Permit(auth.ResourceTwins), v1.GetTwinSubscribers)
+
+	//alerts
+	alerts := apiv1.Group("", middlewares.Permit(auth.ResourceAlerts))
+	{
+		alerts.GET("/alerts", v1.GetAlerts)
+		alerts.POST("/alerts", v1.AddAlert)
+		alerts.GET("/alerts/:id", v1.GetAlert)
+		alerts.PUT("/alerts/:id", v1.UpdateAlert)
+		alerts.DELETE("/alerts/:id", v1.DeleteAlert)
+		alerts.GET("/alertsilences", v1.GetAlertSilences)
+		alerts.POST("/alertsilences", v1.AddAlertSilence)
+		alerts.GET("/alertsilences/:id", v1.GetAlertSilence)
+		alerts.PUT("/alertsilences/:id", v1.UpdateAlertSilence)
+		alerts.DELETE("/alertsilences/:id", v1.DeleteAlertSilence)
+	}
+	alertLogs := apiv1.Group("/alertlogs", middlewares.Permit(auth.ResourceAlerts), middlewares.AlertLogScope("id"))
+	{
+		alertLogs.GET("", v1.GetAlertLogs)
+		alertLogs.GET("/:id", v1.GetAlertLog)
+		alertLogs.PUT("/:id", v1.UpdateAlertLog)
+		alertLogs.POST("/:id/acknowledge", v1.AcknowledgeAlertLog)
+		alertLogs.POST("/:id/resolve", v1.ResolveAlertLog)
+		alertLogs.POST("/:id/invalidate", v1.InvalidateAlertLog)
+		alertLogs.GET("/:id/history", v1.GetAlertLogHistory)
+	}
+
+	//data forward dead letters
+	dataForward := apiv1.Group("/dataforward", middlewares.Permit(auth.ResourceDataForward))
 	{
-		apiv1.POST("/awake/:mac", v1.AwakeDevice)
+		dataForward.GET("/deadletters", v1.GetDataForwardDeadLetters)
+		dataForward.DELETE("/deadletters", v1.PurgeDataForwardDeadLetters)
+		dataForward.GET("/deadletters/:id", v1.GetDataForwardDeadLetter)
+		dataForward.DELETE("/deadletters/:id", v1.DeleteDataForwardDeadLetter)
+		dataForward.POST("/deadletters/:id/replay", v1.ReplayDataForwardDeadLetter)
 	}
 	return r
 
@@ -1,5 +1,56 @@
 package types
 
+import (
+	db "github.com/edgehook/ithings/common/dbm/model"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	//the key of the token subject in the gin context.
+	TokenSubjectKey = "tokenSubject"
+)
+
 type TokenSubject struct {
+	UserID   int64  `form:"userId" json:"userId"`
 	Username string `form:"username" json:"username"`
+	Role     string `form:"role" json:"role"`
+	//the id of the API key if it's authenticated by the key.
+	APIKeyID int64 `form:"apiKeyId" json:"apiKeyId,omitempty"`
+	//the devices the user can access, nil is unlimited for the admin and
+	//empty denies all devices.
+	Scope *db.DeviceScope `json:"-"`
+}
+
+// GetTokenSubject return the subject set by the auth middleware, nil if it's not authenticated.
+func GetTokenSubject(c *gin.Context) *TokenSubject {
+	if v, exist := c.Get(TokenSubjectKey); exist {
+		if subject, ok := v.(*TokenSubject); ok {
+			return subject
+		}
+	}
+	return nil
+}
+
+// GetDeviceScope return the device scope of the authenticated user, nil is unlimited for the admin.
+func GetDeviceScope(c *gin.Context) *db.DeviceScope {
+	if subject := GetTokenSubject(c); subject != nil {
+		return subject.Scope
+	}
+	return nil
+}
+
+type PageInfo struct {
+	Page     int    `form:"page" json:"page"`
+	Limit    int    `form:"limit" json:"limit"`
+	Keywords string `form:"keywords" json:"keywords"`
+}
+
+// Normalize fill the default page and limit.
+func (p *PageInfo) Normalize() {
+	if p.Page <= 0 {
+		p.Page = 1
+	}
+	if p.Limit <= 0 {
+		p.Limit = 10
+	}
 }
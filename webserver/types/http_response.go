@@ -54,3 +54,8 @@ func FailWithCodeAndMessage(code int, message string, c *gin.Context) {
 func FailWithCodeAndDetailed(code int, data interface{}, message string, c *gin.Context) {
 	Result(code, data, message, c)
 }
+
+type PageResult struct {
+	List  interface{} `json:"list"`
+	Total int64       `json:"total"`
+}
@@ -3,6 +3,7 @@ package webserver
 import (
 	"crypto/tls"
 	"github.com/edgehook/ithings/common/config"
+	"github.com/edgehook/ithings/webserver/auth"
 	"github.com/edgehook/ithings/webserver/router"
 	"github.com/jwzl/beehive/pkg/core"
 	"k8s.io/klog"
@@ -43,6 +44,7 @@ func (ws *WebServer) Enable() bool {
 func (ws *WebServer) Start() {
 	var err error
 
+	auth.Start()
 	initRouter := router.InitRouter()
 	cfg := config.GetWebServerConfig()
 	klog.Infof("Start web server on %s ", cfg.BindAddress)